@@ -2,6 +2,7 @@ package api
 
 import (
 	"github.com/gorilla/mux"
+	"github.com/the-lightning-land/sweetd/lightning"
 	"github.com/the-lightning-land/sweetd/network"
 	"github.com/the-lightning-land/sweetd/nodeman"
 	"github.com/the-lightning-land/sweetd/state"
@@ -11,6 +12,7 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"time"
 )
 
 var localhostOriginPattern = regexp.MustCompile(`^https?://(localhost|192\.168\.\d+\.\d+)(:\d+)?$`)
@@ -58,8 +60,10 @@ func NewHandler(config *Config) http.Handler {
 	router.Handle("/nodes", api.noContent()).Methods(http.MethodOptions)
 	router.Handle("/nodes", api.getNodes()).Methods(http.MethodGet)
 	router.Handle("/nodes", api.postNodes()).Methods(http.MethodPost)
+	router.Handle("/nodes/test", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/nodes/test", api.postNodesTest()).Methods(http.MethodPost)
 	router.Handle("/nodes/{id}", api.noContent()).Methods(http.MethodOptions)
-	router.Handle("/nodes/{id}", api.getNodes()).Methods(http.MethodGet)
+	router.Handle("/nodes/{id}", api.getNode()).Methods(http.MethodGet)
 	router.Handle("/nodes/{id}", api.patchNode()).Methods(http.MethodPatch)
 	router.Handle("/nodes/{id}", api.deleteNode()).Methods(http.MethodDelete)
 	router.Handle("/nodes/{id}/status", api.noContent()).Methods(http.MethodOptions)
@@ -68,6 +72,43 @@ func NewHandler(config *Config) http.Handler {
 	router.Handle("/nodes/{id}/seed", api.handlePostNodeSeed()).Methods(http.MethodPost)
 	router.Handle("/nodes/{id}/connection", api.noContent()).Methods(http.MethodOptions)
 	router.Handle("/nodes/{id}/connection", api.handlePostNodeConnection()).Methods(http.MethodPost)
+	router.Handle("/nodes/{id}/backup", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/nodes/{id}/backup", api.handleGetNodeBackup()).Methods(http.MethodGet)
+	router.Handle("/nodes/{id}/restore", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/nodes/{id}/restore", api.handlePostNodeRestore()).Methods(http.MethodPost)
+	router.Handle("/nodes/{id}/peers", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/nodes/{id}/peers", api.getNodePeers()).Methods(http.MethodGet)
+	router.Handle("/nodes/{id}/peers", api.postNodePeers()).Methods(http.MethodPost)
+	router.Handle("/nodes/{id}/channels", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/nodes/{id}/channels", api.getNodeChannels()).Methods(http.MethodGet)
+	router.Handle("/nodes/{id}/channels", api.postNodeChannels()).Methods(http.MethodPost)
+	router.Handle("/nodes/{id}/channels/{channelPoint}", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/nodes/{id}/channels/{channelPoint}", api.deleteNodeChannel()).Methods(http.MethodDelete)
+	router.Handle("/nodes/{id}/wallet", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/nodes/{id}/wallet", api.getNodeWallet()).Methods(http.MethodGet)
+	router.Handle("/nodes/{id}/wallet/address", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/nodes/{id}/wallet/address", api.postNodeWalletAddress()).Methods(http.MethodPost)
+	router.Handle("/nodes/{id}/wallet/send", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/nodes/{id}/wallet/send", api.postNodeWalletSend()).Methods(http.MethodPost)
+
+	router.Handle("/products", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/products", api.handleGetProducts()).Methods(http.MethodGet)
+	router.Handle("/products", api.handlePostProduct()).Methods(http.MethodPost)
+	router.Handle("/products/{id}", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/products/{id}", api.handleGetProduct()).Methods(http.MethodGet)
+	router.Handle("/products/{id}", api.handlePatchProduct()).Methods(http.MethodPatch)
+	router.Handle("/products/{id}", api.handleDeleteProduct()).Methods(http.MethodDelete)
+
+	router.Handle("/inventory", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/inventory", api.handleGetInventory()).Methods(http.MethodGet)
+	router.Handle("/inventory/{slot}", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/inventory/{slot}", api.handlePatchInventory()).Methods(http.MethodPatch)
+
+	router.Handle("/sales", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/sales", api.handleGetSales()).Methods(http.MethodGet)
+	router.Handle("/sweeps", api.noContent()).Methods(http.MethodOptions)
+	router.Handle("/sweeps", api.handleGetSweeps()).Methods(http.MethodGet)
+	router.Handle("/sweeps", api.handlePostSweeps()).Methods(http.MethodPost)
 
 	router.Handle("/networks", api.noContent()).Methods(http.MethodOptions)
 	router.Handle("/networks", api.handlePostUpdate()).Methods(http.MethodPost)
@@ -137,10 +178,32 @@ type Dispenser interface {
 	GetNodes() []nodeman.LightningNode
 	GetNode(id string) nodeman.LightningNode
 	AddNode(config nodeman.NodeConfig) (nodeman.LightningNode, error)
+	TestNode(config nodeman.NodeConfig) (*lightning.Diagnosis, error)
 	RemoveNode(id string) error
 	EnableNode(id string) error
 	DisableNode(id string) error
 	RenameNode(id string, name string) error
+	SetNodePriority(id string, priority int) error
+	SetNodeBackend(id string, backend *lightning.Backend) error
+	SetAutoUnlock(id string, enabled bool, password string) error
+	GetChannelBackup(id string) ([]byte, time.Time, error)
+	RestoreNode(id string, password string, mnemonic []string, backup []byte) error
+	ConnectPeer(id string, pubKey string, host string) error
+	GetPeers(id string) ([]*lightning.Peer, error)
+	OpenChannel(id string, req *lightning.OpenChannelRequest) (string, error)
+	GetChannels(id string) ([]*lightning.Channel, error)
+	CloseChannel(id string, channelPoint string, force bool) (string, error)
+	BakeMacaroon(id string, permissions []lightning.MacaroonPermission, expiry time.Duration) ([]byte, error)
+	GetWalletBalance(id string) (*lightning.WalletBalance, error)
+	NewAddress(id string) (string, error)
+	SendCoins(id string, req *lightning.SendCoinsRequest) (string, error)
+	GetSweepConfig() *sweetdb.SweepConfig
+	SetSweepConfig(config *sweetdb.SweepConfig) error
+	GetSweeps(limit int) ([]*sweetdb.Sweep, error)
+	Sweep() (*sweetdb.Sweep, error)
+	GetNodeDecision(id string) *nodeman.Decision
+	GetNodeSelection() sweetdb.NodeSelection
+	SetNodeSelection(selection sweetdb.NodeSelection) error
 	GetApiOnionID() string
 	GetPosOnionID() string
 	ToggleDispense(on bool)
@@ -152,6 +215,23 @@ type Dispenser interface {
 	SetName(name string) error
 	SetDispenseOnTouch(dispenseOnTouch bool) error
 	SetBuzzOnDispense(buzzOnDispense bool) error
+	GetDispensePolicy() *sweetdb.DispensePolicy
+	SetDispensePolicy(policy *sweetdb.DispensePolicy) error
+	GetPrice() *sweetdb.Price
+	SetPrice(price *sweetdb.Price) error
+	GetProducts() ([]*sweetdb.Product, error)
+	GetProduct(id string) (*sweetdb.Product, error)
+	AddProduct(product *sweetdb.Product) (*sweetdb.Product, error)
+	UpdateProduct(product *sweetdb.Product) (*sweetdb.Product, error)
+	RemoveProduct(id string) error
+	Dispense(slot int, duration time.Duration) error
+	GetSales(query *sweetdb.SalesQuery) ([]*sweetdb.Sale, int, error)
+	GetInventories() ([]*sweetdb.Inventory, error)
+	CalibrateInventory(calibration *sweetdb.Inventory) (*sweetdb.Inventory, error)
+	RemainingGrams(slot int) (float64, bool)
+	Refill(slot int) error
+	GetSlotStock(slot int) state.Stock
+	GetStock() state.Stock
 	ConnectToWifi(connection network.Connection) error
 	Reboot() error
 	ShutDown() error
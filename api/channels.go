@@ -0,0 +1,199 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/the-lightning-land/sweetd/lightning"
+	"net/http"
+)
+
+type peerResponse struct {
+	PubKey   string `json:"pubKey"`
+	Address  string `json:"address"`
+	Inbound  bool   `json:"inbound"`
+	PingTime int64  `json:"pingTime"`
+}
+
+type postPeerRequest struct {
+	PubKey string `json:"pubKey"`
+	// Host of the peer, like 1.2.3.4:9735
+	Host string `json:"host"`
+}
+
+// channelResponse holds all amounts in satoshis
+type channelResponse struct {
+	ChannelPoint  string `json:"channelPoint"`
+	RemotePubKey  string `json:"remotePubKey"`
+	Capacity      int64  `json:"capacity"`
+	LocalBalance  int64  `json:"localBalance"`
+	RemoteBalance int64  `json:"remoteBalance"`
+	Active        bool   `json:"active"`
+	Private       bool   `json:"private"`
+	Pending       bool   `json:"pending"`
+}
+
+type postChannelRequest struct {
+	PubKey string `json:"pubKey"`
+	// Host is optional and connects to the peer first
+	Host string `json:"host"`
+	// Amount funding the channel in satoshis
+	Amount     int64 `json:"amount"`
+	SatPerByte int64 `json:"satPerByte"`
+	Private    bool  `json:"private"`
+}
+
+type postChannelResponse struct {
+	ChannelPoint string `json:"channelPoint"`
+}
+
+type deleteChannelResponse struct {
+	ClosingTxid string `json:"closingTxid"`
+}
+
+func (a *Handler) getNodePeers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		peers, err := a.dispenser.GetPeers(id)
+		if err != nil {
+			a.jsonError(w, fmt.Sprintf("Unable to list peers: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		res := make([]*peerResponse, 0, len(peers))
+
+		for _, peer := range peers {
+			res = append(res, &peerResponse{
+				PubKey:   peer.PubKey,
+				Address:  peer.Address,
+				Inbound:  peer.Inbound,
+				PingTime: peer.PingTime,
+			})
+		}
+
+		a.jsonResponse(w, res, http.StatusOK)
+	}
+}
+
+func (a *Handler) postNodePeers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		req := postPeerRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.PubKey == "" || req.Host == "" {
+			a.jsonError(w, "Public key and host are required", http.StatusBadRequest)
+			return
+		}
+
+		err = a.dispenser.ConnectPeer(id, req.PubKey, req.Host)
+		if err != nil {
+			a.jsonError(w, fmt.Sprintf("Unable to connect to peer: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		a.emptyResponse(w, http.StatusNoContent)
+	}
+}
+
+func (a *Handler) getNodeChannels() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		channels, err := a.dispenser.GetChannels(id)
+		if err != nil {
+			a.jsonError(w, fmt.Sprintf("Unable to list channels: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		res := make([]*channelResponse, 0, len(channels))
+
+		for _, channel := range channels {
+			res = append(res, &channelResponse{
+				ChannelPoint:  channel.ChannelPoint,
+				RemotePubKey:  channel.RemotePubKey,
+				Capacity:      channel.Capacity,
+				LocalBalance:  channel.LocalBalance,
+				RemoteBalance: channel.RemoteBalance,
+				Active:        channel.Active,
+				Private:       channel.Private,
+				Pending:       channel.Pending,
+			})
+		}
+
+		a.jsonResponse(w, res, http.StatusOK)
+	}
+}
+
+func (a *Handler) postNodeChannels() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		req := postChannelRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.Amount <= 0 || req.SatPerByte < 0 {
+			a.jsonError(w, "Amount must be positive and fee rate must not be negative", http.StatusBadRequest)
+			return
+		}
+
+		if req.Host != "" {
+			err := a.dispenser.ConnectPeer(id, req.PubKey, req.Host)
+			if err != nil {
+				a.jsonError(w, fmt.Sprintf("Unable to connect to peer: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		channelPoint, err := a.dispenser.OpenChannel(id, &lightning.OpenChannelRequest{
+			PubKey:     req.PubKey,
+			Amount:     req.Amount,
+			SatPerByte: req.SatPerByte,
+			Private:    req.Private,
+		})
+		if err != nil {
+			a.jsonError(w, fmt.Sprintf("Unable to open channel: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		a.jsonResponse(w, &postChannelResponse{
+			ChannelPoint: channelPoint,
+		}, http.StatusOK)
+	}
+}
+
+// deleteNodeChannel closes a channel cooperatively, unless ?force=true
+// is given for closing it unilaterally
+func (a *Handler) deleteNodeChannel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+		channelPoint := vars["channelPoint"]
+
+		force := r.URL.Query().Get("force") == "true"
+
+		closingTxid, err := a.dispenser.CloseChannel(id, channelPoint, force)
+		if err != nil {
+			a.jsonError(w, fmt.Sprintf("Unable to close channel: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		a.jsonResponse(w, &deleteChannelResponse{
+			ClosingTxid: closingTxid,
+		}, http.StatusOK)
+	}
+}
@@ -4,20 +4,42 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/the-lightning-land/sweetd/state"
+	"github.com/the-lightning-land/sweetd/sweetdb"
 	"net/http"
+	"time"
 )
 
 type dispenserUpdateResponse struct {
 	Id string `json:"id"`
 }
 
+// dispensePolicy is the api representation of a dispense policy with
+// all durations in milliseconds
+type dispensePolicy struct {
+	SatsPerSecond float64 `json:"satsPerSecond"`
+	MinDuration   int64   `json:"minDuration"`
+	MaxDuration   int64   `json:"maxDuration"`
+	Rounding      string  `json:"rounding"`
+	RoundingStep  int64   `json:"roundingStep"`
+}
+
+type price struct {
+	Currency string `json:"currency"`
+	Amount   int64  `json:"amount"`
+}
+
 type dispenserResponse struct {
 	Name            string                   `json:"name"`
 	Api             string                   `json:"api"`
 	Pos             string                   `json:"pos"`
 	Version         string                   `json:"version"`
 	State           string                   `json:"state"`
+	Stock           string                   `json:"stock"`
 	DispenseOnTouch bool                     `json:"dispenseOnTouch"`
+	DispensePolicy  *dispensePolicy          `json:"dispensePolicy"`
+	Price           *price                   `json:"price"`
+	NodeSelection   string                   `json:"nodeSelection"`
+	Sweep           *sweepConfig             `json:"sweep"`
 	Update          *dispenserUpdateResponse `json:"update"`
 }
 
@@ -29,6 +51,64 @@ type patchDispenserOp struct {
 
 type patchDispenserRequest []patchDispenserOp
 
+// dispenseOp is the value of a dispense operation with the duration
+// in milliseconds
+type dispenseOp struct {
+	Slot     int   `json:"slot"`
+	Duration int64 `json:"duration"`
+}
+
+// refillOp is the value of a refill operation
+type refillOp struct {
+	Slot int `json:"slot"`
+}
+
+func toDispensePolicy(policy *sweetdb.DispensePolicy) *dispensePolicy {
+	if policy == nil {
+		return nil
+	}
+
+	return &dispensePolicy{
+		SatsPerSecond: policy.SatsPerSecond,
+		MinDuration:   int64(policy.MinDuration / time.Millisecond),
+		MaxDuration:   int64(policy.MaxDuration / time.Millisecond),
+		Rounding:      string(policy.Rounding),
+		RoundingStep:  int64(policy.RoundingStep / time.Millisecond),
+	}
+}
+
+func fromDispensePolicy(policy *dispensePolicy) *sweetdb.DispensePolicy {
+	return &sweetdb.DispensePolicy{
+		SatsPerSecond: policy.SatsPerSecond,
+		MinDuration:   time.Duration(policy.MinDuration) * time.Millisecond,
+		MaxDuration:   time.Duration(policy.MaxDuration) * time.Millisecond,
+		Rounding:      sweetdb.Rounding(policy.Rounding),
+		RoundingStep:  time.Duration(policy.RoundingStep) * time.Millisecond,
+	}
+}
+
+func toPrice(p *sweetdb.Price) *price {
+	if p == nil {
+		return nil
+	}
+
+	return &price{
+		Currency: p.Currency,
+		Amount:   p.Amount,
+	}
+}
+
+// decodeOpValue decodes the loosely typed value of a patch operation
+// into the given struct
+func decodeOpValue(value interface{}, v interface{}) error {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(payload, v)
+}
+
 func (a *Handler) getDispenser() *dispenserResponse {
 	var currentUpdateRes *dispenserUpdateResponse
 	currentUpdate, err := a.dispenser.GetCurrentUpdate()
@@ -48,7 +128,12 @@ func (a *Handler) getDispenser() *dispenserResponse {
 		Api:             a.dispenser.GetApiOnionID(),
 		Pos:             a.dispenser.GetPosOnionID(),
 		State:           state.String(a.dispenser.GetState()),
+		Stock:           string(a.dispenser.GetStock()),
 		DispenseOnTouch: a.dispenser.ShouldDispenseOnTouch(),
+		DispensePolicy:  toDispensePolicy(a.dispenser.GetDispensePolicy()),
+		Price:           toPrice(a.dispenser.GetPrice()),
+		NodeSelection:   string(a.dispenser.GetNodeSelection()),
+		Sweep:           toSweepConfig(a.dispenser.GetSweepConfig()),
 		Update:          currentUpdateRes,
 	}
 }
@@ -101,10 +186,99 @@ func (a *Handler) handlePatchDispenser() http.HandlerFunc {
 						a.jsonError(w, fmt.Sprintf("%s value not a string, but %T", op.Name, op.Value), http.StatusInternalServerError)
 						return
 					}
+				} else if op.Name == "dispensePolicy" {
+					value := dispensePolicy{}
+					err := decodeOpValue(op.Value, &value)
+					if err != nil {
+						a.jsonError(w, fmt.Sprintf("%s value not a dispense policy: %v", op.Name, err), http.StatusBadRequest)
+						return
+					}
+
+					err = a.dispenser.SetDispensePolicy(fromDispensePolicy(&value))
+					if err != nil {
+						a.jsonError(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+
+					res.DispensePolicy = toDispensePolicy(a.dispenser.GetDispensePolicy())
+				} else if op.Name == "price" {
+					value := price{}
+					err := decodeOpValue(op.Value, &value)
+					if err != nil {
+						a.jsonError(w, fmt.Sprintf("%s value not a price: %v", op.Name, err), http.StatusBadRequest)
+						return
+					}
+
+					err = a.dispenser.SetPrice(&sweetdb.Price{
+						Currency: value.Currency,
+						Amount:   value.Amount,
+					})
+					if err != nil {
+						a.jsonError(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+
+					res.Price = toPrice(a.dispenser.GetPrice())
+				} else if op.Name == "nodeSelection" {
+					if value, ok := op.Value.(string); ok {
+						err := a.dispenser.SetNodeSelection(sweetdb.NodeSelection(value))
+						if err != nil {
+							a.jsonError(w, err.Error(), http.StatusBadRequest)
+							return
+						}
+
+						res.NodeSelection = value
+					} else {
+						a.jsonError(w, fmt.Sprintf("%s value not a string, but %T", op.Name, op.Value), http.StatusBadRequest)
+						return
+					}
+				} else if op.Name == "sweep" {
+					value := sweepConfig{}
+					err := decodeOpValue(op.Value, &value)
+					if err != nil {
+						a.jsonError(w, fmt.Sprintf("%s value not a sweep config: %v", op.Name, err), http.StatusBadRequest)
+						return
+					}
+
+					err = a.dispenser.SetSweepConfig(fromSweepConfig(&value))
+					if err != nil {
+						a.jsonError(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+
+					res.Sweep = toSweepConfig(a.dispenser.GetSweepConfig())
 				} else {
 					a.jsonError(w, fmt.Sprintf("unknown field %s", op.Name), http.StatusBadRequest)
 					return
 				}
+			} else if op.Op == "dispense" {
+				value := dispenseOp{}
+				err := decodeOpValue(op.Value, &value)
+				if err != nil {
+					a.jsonError(w, fmt.Sprintf("%s value not a dispense: %v", op.Op, err), http.StatusBadRequest)
+					return
+				}
+
+				err = a.dispenser.Dispense(value.Slot, time.Duration(value.Duration)*time.Millisecond)
+				if err != nil {
+					a.jsonError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			} else if op.Op == "refill" {
+				value := refillOp{}
+				err := decodeOpValue(op.Value, &value)
+				if err != nil {
+					a.jsonError(w, fmt.Sprintf("%s value not a refill: %v", op.Op, err), http.StatusBadRequest)
+					return
+				}
+
+				err = a.dispenser.Refill(value.Slot)
+				if err != nil {
+					a.jsonError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+
+				res.Stock = string(a.dispenser.GetStock())
 			} else if op.Op == "reboot" {
 				res.State = state.String(state.StateStopping)
 
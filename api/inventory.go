@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type patchInventoryRequest struct {
+	Capacity       *float64 `json:"capacity"`
+	GramsPerSecond *float64 `json:"gramsPerSecond"`
+	LowThreshold   *float64 `json:"lowThreshold"`
+}
+
+type inventoryResponse struct {
+	Slot           int     `json:"slot"`
+	Capacity       float64 `json:"capacity"`
+	GramsPerSecond float64 `json:"gramsPerSecond"`
+	LowThreshold   float64 `json:"lowThreshold"`
+	// RunTime is the motor run time since the last refill in milliseconds
+	RunTime   int64      `json:"runTime"`
+	Refilled  *time.Time `json:"refilled"`
+	Remaining *float64   `json:"remaining"`
+	Stock     string     `json:"stock"`
+}
+
+func (a *Handler) toInventoryResponse(inventory *sweetdb.Inventory) *inventoryResponse {
+	res := &inventoryResponse{
+		Slot:           inventory.Slot,
+		Capacity:       inventory.Capacity,
+		GramsPerSecond: inventory.GramsPerSecond,
+		LowThreshold:   inventory.LowThreshold,
+		RunTime:        int64(inventory.RunTime / time.Millisecond),
+		Stock:          string(a.dispenser.GetSlotStock(inventory.Slot)),
+	}
+
+	if !inventory.Refilled.IsZero() {
+		res.Refilled = &inventory.Refilled
+	}
+
+	if remaining, ok := a.dispenser.RemainingGrams(inventory.Slot); ok {
+		res.Remaining = &remaining
+	}
+
+	return res
+}
+
+func (a *Handler) handleGetInventory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inventories, err := a.dispenser.GetInventories()
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		results := []*inventoryResponse{}
+
+		for _, inventory := range inventories {
+			results = append(results, a.toInventoryResponse(inventory))
+		}
+
+		a.jsonResponse(w, &results, http.StatusOK)
+	}
+}
+
+func (a *Handler) handlePatchInventory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		slot, err := strconv.Atoi(vars["slot"])
+		if err != nil {
+			a.jsonError(w, fmt.Sprintf("Slot %s is not a number", vars["slot"]), http.StatusBadRequest)
+			return
+		}
+
+		req := patchInventoryRequest{}
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		inventories, err := a.dispenser.GetInventories()
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if slot < 0 || slot >= len(inventories) {
+			a.jsonError(w, fmt.Sprintf("No slot %d found", slot), http.StatusNotFound)
+			return
+		}
+
+		inventory := inventories[slot]
+
+		if req.Capacity != nil {
+			inventory.Capacity = *req.Capacity
+		}
+
+		if req.GramsPerSecond != nil {
+			inventory.GramsPerSecond = *req.GramsPerSecond
+		}
+
+		if req.LowThreshold != nil {
+			inventory.LowThreshold = *req.LowThreshold
+		}
+
+		inventory, err = a.dispenser.CalibrateInventory(inventory)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		a.jsonResponse(w, a.toInventoryResponse(inventory), http.StatusOK)
+	}
+}
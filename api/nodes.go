@@ -4,9 +4,11 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"github.com/go-errors/errors"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/the-lightning-land/sweetd/lightning"
+	"github.com/the-lightning-land/sweetd/lndconnect"
 	"github.com/the-lightning-land/sweetd/nodeman"
 	"io/ioutil"
 	"net/http"
@@ -15,58 +17,223 @@ import (
 
 const (
 	postNodesTypeRemoteLnd = "remote-lnd"
+	postNodesTypeRemoteCln = "remote-cln"
+	postNodesTypeLnbits    = "lnbits"
 	postNodesTypeLocal     = "local"
 )
 
+// postNodesRequest is the part of all POST /nodes bodies, the connection
+// of remote nodes is tested before saving unless skipTest is set
 type postNodesRequest struct {
-	Type string `json:"type"`
+	Type     string `json:"type"`
+	SkipTest bool   `json:"skipTest"`
 }
 
+// postNodesRemoteLndRequest either has an lndconnect:// URI or the uri,
+// inline base64 cert and base64 macaroon separately. Tor is used for
+// .onion uris or if tor is set.
 type postNodesRemoteLndRequest struct {
+	SkipTest   bool   `json:"skipTest"`
+	Name       string `json:"name"`
+	Network    string `json:"network"`
+	Lndconnect string `json:"lndconnect"`
+	Uri        string `json:"uri"`
+	Macaroon   string `json:"macaroon"`
+	Cert       string `json:"cert"`
+	Tor        bool   `json:"tor"`
+}
+
+type postNodesRemoteClnRequest struct {
+	SkipTest bool   `json:"skipTest"`
 	Name     string `json:"name"`
+	Network  string `json:"network"`
 	Uri      string `json:"uri"`
-	Macaroon string `json:"macaroon"`
-	Cert     string `json:"cert"`
+}
+
+type postNodesLnbitsRequest struct {
+	SkipTest   bool   `json:"skipTest"`
+	Name       string `json:"name"`
+	Url        string `json:"url"`
+	InvoiceKey string `json:"invoiceKey"`
+}
+
+// nodeBackend is the chain backend of a local node, the rpc password is
+// only accepted but never returned. An empty password keeps the saved one.
+type nodeBackend struct {
+	Kind           string   `json:"kind"`
+	Peers          []string `json:"peers"`
+	RpcHost        string   `json:"rpcHost"`
+	RpcUser        string   `json:"rpcUser"`
+	RpcPass        string   `json:"rpcPass,omitempty"`
+	ZmqPubRawBlock string   `json:"zmqPubRawBlock"`
+	ZmqPubRawTx    string   `json:"zmqPubRawTx"`
 }
 
 type postNodesLocalRequest struct {
-	Name string `json:"name"`
+	Name    string       `json:"name"`
+	Network string       `json:"network"`
+	Backend *nodeBackend `json:"backend"`
 }
 
 type postNodesRemoteLndResponse struct {
-	ID      string `json:"id"`
-	Type    string `json:"type"`
-	Uri     string `json:"uri"`
-	Name    string `json:"name"`
-	Enabled bool   `json:"enabled"`
-	Status  string `json:"status"`
+	ID       string   `json:"id"`
+	Type     string   `json:"type"`
+	Network  string   `json:"network"`
+	Uri      string   `json:"uri"`
+	Name     string   `json:"name"`
+	Enabled  bool     `json:"enabled"`
+	Priority int      `json:"priority"`
+	Status   string   `json:"status"`
+	Tor      bool     `json:"tor"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+type postNodesRemoteClnResponse struct {
+	ID       string   `json:"id"`
+	Type     string   `json:"type"`
+	Network  string   `json:"network"`
+	Uri      string   `json:"uri"`
+	Name     string   `json:"name"`
+	Enabled  bool     `json:"enabled"`
+	Priority int      `json:"priority"`
+	Status   string   `json:"status"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+type postNodesLnbitsResponse struct {
+	ID       string   `json:"id"`
+	Type     string   `json:"type"`
+	Uri      string   `json:"uri"`
+	Name     string   `json:"name"`
+	Enabled  bool     `json:"enabled"`
+	Priority int      `json:"priority"`
+	Status   string   `json:"status"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// nodeDiagnosisResponse is the result of a connection test, problem is
+// one of unreachable, tls, auth, permissions, wrong-chain or unexpected
+type nodeDiagnosisResponse struct {
+	Ok          bool                     `json:"ok"`
+	Problem     string                   `json:"problem,omitempty"`
+	Error       string                   `json:"error,omitempty"`
+	Reachable   bool                     `json:"reachable"`
+	PubKey      string                   `json:"pubKey,omitempty"`
+	Alias       string                   `json:"alias,omitempty"`
+	Network     string                   `json:"network,omitempty"`
+	Permissions *nodePermissionsResponse `json:"permissions,omitempty"`
+	Warnings    []string                 `json:"warnings"`
+}
+
+type nodePermissionsResponse struct {
+	ReadInvoices  bool     `json:"readInvoices"`
+	WriteInvoices bool     `json:"writeInvoices"`
+	Admin         bool     `json:"admin"`
+	Unknown       []string `json:"unknown"`
+}
+
+// nodeTestErrorResponse is returned if a node isn't added because its
+// connection test failed
+type nodeTestErrorResponse struct {
+	Error     string                 `json:"error"`
+	Diagnosis *nodeDiagnosisResponse `json:"diagnosis"`
 }
 
 type postNodesLocalResponse struct {
-	ID      string `json:"id"`
-	Type    string `json:"type"`
-	Uri     string `json:"uri"`
-	Name    string `json:"name"`
-	Enabled bool   `json:"enabled"`
-	Status  string `json:"status"`
+	ID         string       `json:"id"`
+	Type       string       `json:"type"`
+	Network    string       `json:"network"`
+	Uri        string       `json:"uri"`
+	Name       string       `json:"name"`
+	Enabled    bool         `json:"enabled"`
+	Priority   int          `json:"priority"`
+	Status     string       `json:"status"`
+	Backend    *nodeBackend `json:"backend"`
+	AutoUnlock bool         `json:"autoUnlock"`
 }
 
+// getNodesRemoteLndResponse has the state of the grpc connection, which
+// is idle, connecting, ready, transient-failure, shutdown or disconnected
 type getNodesRemoteLndResponse struct {
-	ID      string `json:"id"`
-	Type    string `json:"type"`
-	Uri     string `json:"uri"`
-	Name    string `json:"name"`
-	Enabled bool   `json:"enabled"`
-	Status  string `json:"status"`
+	ID              string                 `json:"id"`
+	Type            string                 `json:"type"`
+	Network         string                 `json:"network"`
+	Uri             string                 `json:"uri"`
+	Name            string                 `json:"name"`
+	Enabled         bool                   `json:"enabled"`
+	Priority        int                    `json:"priority"`
+	Status          string                 `json:"status"`
+	Selection       *nodeSelectionResponse `json:"selection"`
+	Health          *nodeHealthResponse    `json:"health"`
+	Tor             bool                   `json:"tor"`
+	ConnectionState string                 `json:"connectionState"`
+	Warnings        []string               `json:"warnings,omitempty"`
+}
+
+type getNodesRemoteClnResponse struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Network   string                 `json:"network"`
+	Uri       string                 `json:"uri"`
+	Name      string                 `json:"name"`
+	Enabled   bool                   `json:"enabled"`
+	Priority  int                    `json:"priority"`
+	Status    string                 `json:"status"`
+	Selection *nodeSelectionResponse `json:"selection"`
+	Health    *nodeHealthResponse    `json:"health"`
+	Warnings  []string               `json:"warnings,omitempty"`
+}
+
+type getNodesLnbitsResponse struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Uri       string                 `json:"uri"`
+	Name      string                 `json:"name"`
+	Enabled   bool                   `json:"enabled"`
+	Priority  int                    `json:"priority"`
+	Status    string                 `json:"status"`
+	Selection *nodeSelectionResponse `json:"selection"`
+	Health    *nodeHealthResponse    `json:"health"`
+	Warnings  []string               `json:"warnings,omitempty"`
 }
 
 type getNodesLocalLndResponse struct {
-	ID      string `json:"id"`
-	Type    string `json:"type"`
-	Uri     string `json:"uri"`
-	Name    string `json:"name"`
-	Enabled bool   `json:"enabled"`
-	Status  string `json:"status"`
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Network    string                 `json:"network"`
+	Uri        string                 `json:"uri"`
+	Name       string                 `json:"name"`
+	Enabled    bool                   `json:"enabled"`
+	Priority   int                    `json:"priority"`
+	Status     string                 `json:"status"`
+	Selection  *nodeSelectionResponse `json:"selection"`
+	Health     *nodeHealthResponse    `json:"health"`
+	Backend    *nodeBackend           `json:"backend"`
+	AutoUnlock bool                   `json:"autoUnlock"`
+}
+
+// nodeSelectionResponse explains whether the node was considered when
+// the last invoice was issued
+type nodeSelectionResponse struct {
+	Time     time.Time `json:"time"`
+	MSat     int64     `json:"msat"`
+	Inbound  *int64    `json:"inbound"`
+	Eligible bool      `json:"eligible"`
+	Selected bool      `json:"selected"`
+	Reason   string    `json:"reason"`
+}
+
+// nodeHealthResponse is the result of the last health check of a node
+// with the inbound liquidity in millisatoshis
+type nodeHealthResponse struct {
+	Time           time.Time `json:"time"`
+	SyncedToChain  bool      `json:"syncedToChain"`
+	BlockHeight    uint32    `json:"blockHeight"`
+	Peers          uint32    `json:"peers"`
+	ActiveChannels uint32    `json:"activeChannels"`
+	Uris           []string  `json:"uris"`
+	Inbound        int64     `json:"inbound"`
+	Error          string    `json:"error"`
 }
 
 type getNodesResponse []interface{}
@@ -83,6 +250,21 @@ type patchNodeEnableRequest struct {
 	Enabled bool `json:"enabled"`
 }
 
+type patchNodePriorityRequest struct {
+	Priority int `json:"priority"`
+}
+
+type patchNodeBackendRequest struct {
+	Backend nodeBackend `json:"backend"`
+}
+
+// patchNodeAutoUnlockRequest enables unlocking the wallet on start with
+// the given password or disables it
+type patchNodeAutoUnlockRequest struct {
+	Enabled  bool   `json:"enabled"`
+	Password string `json:"password"`
+}
+
 type patchNodeUnlockRequest struct {
 	Password string `json:"password"`
 }
@@ -103,13 +285,37 @@ type nodeSeedResponse struct {
 	Mnemonic []string `json:"mnemonic"`
 }
 
+// postNodeRestoreRequest restores a channel backup, which defaults to
+// the saved one. The wallet is created from the mnemonic if given.
+type postNodeRestoreRequest struct {
+	Password string   `json:"password"`
+	Mnemonic []string `json:"mnemonic"`
+	Backup   []byte   `json:"backup"`
+}
+
+const (
+	nodeConnectionFormatJson = "json"
+	nodeConnectionFormatSvg  = "svg"
+	nodeConnectionFormatPng  = "png"
+)
+
+// postNodeConnectionRequest scopes the macaroon of an exported connection
+// to permissions like invoices:read, which default to the ones of an
+// invoice macaroon, and an optional expiry in milliseconds. The format
+// is json by default, svg or png return the lndconnect uri as qr code.
 type postNodeConnectionRequest struct {
+	Permissions []string `json:"permissions"`
+	Expiry      int64    `json:"expiry"`
+	Format      string   `json:"format"`
 }
 
 type nodeConnectionResponse struct {
-	Uri      string `json:"uri"`
-	Cert     string `json:"cert"`
-	Macaroon string `json:"macaroon"`
+	Uri         string     `json:"uri"`
+	Cert        string     `json:"cert"`
+	Macaroon    string     `json:"macaroon"`
+	Lndconnect  string     `json:"lndconnect"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
 }
 
 func nodeStatusString(status lightning.Status) string {
@@ -124,11 +330,119 @@ func nodeStatusString(status lightning.Status) string {
 		return "started"
 	case lightning.StatusFailed:
 		return "failed"
+	case lightning.StatusSyncing:
+		return "syncing"
+	case lightning.StatusDegraded:
+		return "degraded"
 	default:
 		return ""
 	}
 }
 
+// nodeConfig decodes the body of a POST /nodes request by its type
+func nodeConfig(body []byte) (nodeman.NodeConfig, error) {
+	req := postNodesRequest{}
+	err := json.Unmarshal(body, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Type {
+	case postNodesTypeRemoteLnd:
+		req := postNodesRemoteLndRequest{}
+		err := json.Unmarshal(body, &req)
+		if err != nil {
+			return nil, err
+		}
+
+		if req.Lndconnect != "" {
+			conn, err := lndconnect.Parse(req.Lndconnect)
+			if err != nil {
+				return nil, err
+			}
+
+			req.Uri = conn.Host
+			req.Cert = base64.StdEncoding.EncodeToString(conn.Cert)
+			req.Macaroon = base64.StdEncoding.EncodeToString(conn.Macaroon)
+		}
+
+		macaroonBytes, err := base64.StdEncoding.DecodeString(req.Macaroon)
+		if err != nil {
+			return nil, errors.Errorf("unable to decode macaroon: %v", err)
+		}
+
+		network, err := lightning.ParseNetwork(req.Network)
+		if err != nil {
+			return nil, err
+		}
+
+		var certBytes []byte
+		if req.Cert != "" {
+			certBytes = []byte(req.Cert)
+		}
+
+		return &nodeman.RemoteLndNodeConfig{
+			Name:     req.Name,
+			Network:  network,
+			Uri:      req.Uri,
+			Macaroon: macaroonBytes,
+			Cert:     certBytes,
+			Tor:      req.Tor,
+			SkipTest: req.SkipTest,
+		}, nil
+	case postNodesTypeRemoteCln:
+		req := postNodesRemoteClnRequest{}
+		err := json.Unmarshal(body, &req)
+		if err != nil {
+			return nil, err
+		}
+
+		network, err := lightning.ParseNetwork(req.Network)
+		if err != nil {
+			return nil, err
+		}
+
+		return &nodeman.RemoteClnNodeConfig{
+			Name:     req.Name,
+			Network:  network,
+			Uri:      req.Uri,
+			SkipTest: req.SkipTest,
+		}, nil
+	case postNodesTypeLnbits:
+		req := postNodesLnbitsRequest{}
+		err := json.Unmarshal(body, &req)
+		if err != nil {
+			return nil, err
+		}
+
+		return &nodeman.LnbitsNodeConfig{
+			Name:       req.Name,
+			Url:        req.Url,
+			InvoiceKey: req.InvoiceKey,
+			SkipTest:   req.SkipTest,
+		}, nil
+	case postNodesTypeLocal:
+		req := postNodesLocalRequest{}
+		err := json.Unmarshal(body, &req)
+		if err != nil {
+			return nil, err
+		}
+
+		network, err := lightning.ParseNetwork(req.Network)
+		if err != nil {
+			return nil, err
+		}
+
+		return &nodeman.LocalNodeConfig{
+			Name:    req.Name,
+			Network: network,
+			Backend: fromNodeBackend(req.Backend),
+		}, nil
+	default:
+		return nil, errors.Errorf("unknown type \"%s\"", req.Type)
+	}
+}
+
 func (a *Handler) postNodes() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		body, err := ioutil.ReadAll(r.Body)
@@ -137,79 +451,280 @@ func (a *Handler) postNodes() http.HandlerFunc {
 			return
 		}
 
-		req := postNodesRequest{}
-		err = json.Unmarshal(body, &req)
+		config, err := nodeConfig(body)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		node, err := a.dispenser.AddNode(config)
+		if testErr, ok := err.(*nodeman.TestError); ok {
+			a.jsonResponse(w, &nodeTestErrorResponse{
+				Error:     testErr.Error(),
+				Diagnosis: toNodeDiagnosis(testErr.Diagnosis),
+			}, http.StatusBadRequest)
+			return
+		}
+
 		if err != nil {
 			a.jsonError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		switch req.Type {
-		case postNodesTypeRemoteLnd:
-			req := postNodesRemoteLndRequest{}
-			err := json.Unmarshal(body, &req)
-			if err != nil {
-				a.jsonError(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+		switch node := node.(type) {
+		case *nodeman.RemoteLndNode:
+			a.jsonResponse(w, &postNodesRemoteLndResponse{
+				ID:       node.ID(),
+				Type:     postNodesTypeRemoteLnd,
+				Network:  string(node.Network),
+				Uri:      node.Uri,
+				Name:     node.Name(),
+				Enabled:  node.Enabled(),
+				Priority: node.Priority(),
+				Status:   nodeStatusString(node.Status()),
+				Tor:      node.Connection().Tor,
+				Warnings: node.Warnings(),
+			}, http.StatusOK)
+		case *nodeman.RemoteClnNode:
+			a.jsonResponse(w, &postNodesRemoteClnResponse{
+				ID:       node.ID(),
+				Type:     postNodesTypeRemoteCln,
+				Network:  string(node.Network),
+				Uri:      node.Uri,
+				Name:     node.Name(),
+				Enabled:  node.Enabled(),
+				Priority: node.Priority(),
+				Status:   nodeStatusString(node.Status()),
+				Warnings: node.Warnings(),
+			}, http.StatusOK)
+		case *nodeman.LnbitsNode:
+			a.jsonResponse(w, &postNodesLnbitsResponse{
+				ID:       node.ID(),
+				Type:     postNodesTypeLnbits,
+				Uri:      node.Url,
+				Name:     node.Name(),
+				Enabled:  node.Enabled(),
+				Priority: node.Priority(),
+				Status:   nodeStatusString(node.Status()),
+				Warnings: node.Warnings(),
+			}, http.StatusOK)
+		case *nodeman.LocalNode:
+			a.jsonResponse(w, &postNodesLocalResponse{
+				ID:         node.ID(),
+				Type:       postNodesTypeLocal,
+				Network:    string(node.Network),
+				Uri:        node.Uri(),
+				Backend:    toNodeBackend(node.Backend()),
+				AutoUnlock: node.AutoUnlock(),
+				Name:       node.Name(),
+				Enabled:    node.Enabled(),
+				Priority:   node.Priority(),
+				Status:     nodeStatusString(node.Status()),
+			}, http.StatusOK)
+		default:
+			a.jsonError(w, fmt.Sprintf("unknown node type %T", node), http.StatusInternalServerError)
+		}
+	}
+}
 
-			macaroonBytes, err := base64.StdEncoding.DecodeString(req.Macaroon)
-			if err != nil {
-				a.jsonError(w, fmt.Sprintf("unable to decode macaroon: %v", err), http.StatusBadRequest)
-				return
-			}
+// postNodesTest tests the connection to a node without saving it, taking
+// the same body as POST /nodes
+func (a *Handler) postNodesTest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			a.jsonError(w, fmt.Sprintf("unable to read body: %v", err), http.StatusInternalServerError)
+			return
+		}
 
-			node, err := a.dispenser.AddNode(&nodeman.RemoteLndNodeConfig{
-				Name:     req.Name,
-				Uri:      req.Uri,
-				Macaroon: macaroonBytes,
-				Cert:     []byte(req.Cert),
-			})
-			if err != nil {
-				a.jsonError(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+		config, err := nodeConfig(body)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-			remoteLndNode := node.(*nodeman.RemoteLndNode)
+		diagnosis, err := a.dispenser.TestNode(config)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-			a.jsonResponse(w, &postNodesRemoteLndResponse{
-				ID:      remoteLndNode.ID(),
-				Type:    postNodesTypeRemoteLnd,
-				Uri:     remoteLndNode.Uri,
-				Name:    remoteLndNode.Name(),
-				Enabled: remoteLndNode.Enabled(),
-				Status:  nodeStatusString(remoteLndNode.Status()),
-			}, http.StatusOK)
-		case postNodesTypeLocal:
-			req := postNodesLocalRequest{}
-			err := json.Unmarshal(body, &req)
-			if err != nil {
-				a.jsonError(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+		a.jsonResponse(w, toNodeDiagnosis(diagnosis), http.StatusOK)
+	}
+}
 
-			node, err := a.dispenser.AddNode(&nodeman.LocalNodeConfig{
-				Name: req.Name,
-			})
-			if err != nil {
-				a.jsonError(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+func toNodeDiagnosis(diagnosis *lightning.Diagnosis) *nodeDiagnosisResponse {
+	res := &nodeDiagnosisResponse{
+		Ok:        diagnosis.Ok(),
+		Problem:   string(diagnosis.Problem),
+		Error:     diagnosis.Error,
+		Reachable: diagnosis.Reachable,
+		PubKey:    diagnosis.PubKey,
+		Alias:     diagnosis.Alias,
+		Network:   diagnosis.Network,
+		Warnings:  diagnosis.Warnings,
+	}
 
-			localNode := node.(*nodeman.LocalNode)
+	if diagnosis.Permissions != nil {
+		res.Permissions = &nodePermissionsResponse{
+			ReadInvoices:  diagnosis.Permissions.ReadInvoices,
+			WriteInvoices: diagnosis.Permissions.WriteInvoices,
+			Admin:         diagnosis.Permissions.Admin,
+			Unknown:       diagnosis.Permissions.Unknown,
+		}
 
-			a.jsonResponse(w, &postNodesLocalResponse{
-				ID:      localNode.ID(),
-				Type:    postNodesTypeLocal,
-				Uri:     localNode.Uri(),
-				Name:    localNode.Name(),
-				Enabled: localNode.Enabled(),
-				Status:  nodeStatusString(localNode.Status()),
-			}, http.StatusOK)
-		default:
-			a.jsonError(w, fmt.Sprintf("unknown type \"%s\"", req.Type), http.StatusBadRequest)
+		if res.Permissions.Unknown == nil {
+			res.Permissions.Unknown = []string{}
 		}
 	}
+
+	if res.Warnings == nil {
+		res.Warnings = []string{}
+	}
+
+	return res
+}
+
+func toNodeSelection(decision *nodeman.Decision) *nodeSelectionResponse {
+	if decision == nil {
+		return nil
+	}
+
+	res := &nodeSelectionResponse{
+		Time:     decision.Time,
+		MSat:     decision.MSat,
+		Eligible: decision.Eligible,
+		Selected: decision.Selected,
+		Reason:   decision.Reason,
+	}
+
+	if decision.Inbound >= 0 {
+		inbound := decision.Inbound
+		res.Inbound = &inbound
+	}
+
+	return res
+}
+
+func toNodeBackend(backend *lightning.Backend) *nodeBackend {
+	if backend == nil {
+		return nil
+	}
+
+	return &nodeBackend{
+		Kind:           string(backend.Kind),
+		Peers:          backend.Peers,
+		RpcHost:        backend.RpcHost,
+		RpcUser:        backend.RpcUser,
+		ZmqPubRawBlock: backend.ZmqPubRawBlock,
+		ZmqPubRawTx:    backend.ZmqPubRawTx,
+	}
+}
+
+func fromNodeBackend(backend *nodeBackend) *lightning.Backend {
+	if backend == nil {
+		return nil
+	}
+
+	return &lightning.Backend{
+		Kind:           lightning.BackendKind(backend.Kind),
+		Peers:          backend.Peers,
+		RpcHost:        backend.RpcHost,
+		RpcUser:        backend.RpcUser,
+		RpcPass:        backend.RpcPass,
+		ZmqPubRawBlock: backend.ZmqPubRawBlock,
+		ZmqPubRawTx:    backend.ZmqPubRawTx,
+	}
+}
+
+func toNodeHealth(node nodeman.LightningNode) *nodeHealthResponse {
+	healthNode, ok := node.(lightning.HealthNode)
+	if !ok {
+		return nil
+	}
+
+	health := healthNode.Health()
+	if health == nil {
+		return nil
+	}
+
+	return &nodeHealthResponse{
+		Time:           health.Time,
+		SyncedToChain:  health.SyncedToChain,
+		BlockHeight:    health.BlockHeight,
+		Peers:          health.Peers,
+		ActiveChannels: health.ActiveChannels,
+		Uris:           health.Uris,
+		Inbound:        health.Inbound,
+		Error:          health.Error,
+	}
+}
+
+// getNodeResponse returns the api representation of a node or nil if the
+// type of node is unknown
+func (a *Handler) getNodeResponse(node nodeman.LightningNode) interface{} {
+	switch node := node.(type) {
+	case *nodeman.RemoteLndNode:
+		return &getNodesRemoteLndResponse{
+			ID:              node.ID(),
+			Type:            postNodesTypeRemoteLnd,
+			Network:         string(node.Network),
+			Uri:             node.Uri,
+			Name:            node.Name(),
+			Enabled:         node.Enabled(),
+			Priority:        node.Priority(),
+			Status:          nodeStatusString(node.Status()),
+			Selection:       toNodeSelection(a.dispenser.GetNodeDecision(node.ID())),
+			Health:          toNodeHealth(node),
+			Tor:             node.Connection().Tor,
+			ConnectionState: node.Connection().State,
+			Warnings:        node.Warnings(),
+		}
+	case *nodeman.RemoteClnNode:
+		return &getNodesRemoteClnResponse{
+			ID:        node.ID(),
+			Type:      postNodesTypeRemoteCln,
+			Network:   string(node.Network),
+			Uri:       node.Uri,
+			Name:      node.Name(),
+			Enabled:   node.Enabled(),
+			Priority:  node.Priority(),
+			Status:    nodeStatusString(node.Status()),
+			Selection: toNodeSelection(a.dispenser.GetNodeDecision(node.ID())),
+			Health:    toNodeHealth(node),
+			Warnings:  node.Warnings(),
+		}
+	case *nodeman.LnbitsNode:
+		return &getNodesLnbitsResponse{
+			ID:        node.ID(),
+			Type:      postNodesTypeLnbits,
+			Uri:       node.Url,
+			Name:      node.Name(),
+			Enabled:   node.Enabled(),
+			Priority:  node.Priority(),
+			Status:    nodeStatusString(node.Status()),
+			Selection: toNodeSelection(a.dispenser.GetNodeDecision(node.ID())),
+			Health:    toNodeHealth(node),
+			Warnings:  node.Warnings(),
+		}
+	case *nodeman.LocalNode:
+		return &getNodesLocalLndResponse{
+			ID:         node.ID(),
+			Type:       postNodesTypeLocal,
+			Network:    string(node.Network),
+			Uri:        node.Uri(),
+			Backend:    toNodeBackend(node.Backend()),
+			AutoUnlock: node.AutoUnlock(),
+			Name:       node.Name(),
+			Enabled:    node.Enabled(),
+			Priority:   node.Priority(),
+			Status:     nodeStatusString(node.Status()),
+			Selection:  toNodeSelection(a.dispenser.GetNodeDecision(node.ID())),
+			Health:     toNodeHealth(node),
+		}
+	default:
+		return nil
+	}
 }
 
 func (a *Handler) getNodes() http.HandlerFunc {
@@ -219,34 +734,40 @@ func (a *Handler) getNodes() http.HandlerFunc {
 		nodes := a.dispenser.GetNodes()
 
 		for _, node := range nodes {
-			switch node := node.(type) {
-			case *nodeman.RemoteLndNode:
-				results = append(results, &getNodesRemoteLndResponse{
-					ID:      node.ID(),
-					Type:    postNodesTypeRemoteLnd,
-					Uri:     node.Uri,
-					Name:    node.Name(),
-					Enabled: node.Enabled(),
-					Status:  nodeStatusString(node.Status()),
-				})
-			case *nodeman.LocalNode:
-				results = append(results, &getNodesLocalLndResponse{
-					ID:      node.ID(),
-					Type:    postNodesTypeLocal,
-					Uri:     node.Uri(),
-					Name:    node.Name(),
-					Enabled: node.Enabled(),
-					Status:  nodeStatusString(node.Status()),
-				})
-			default:
+			res := a.getNodeResponse(node)
+			if res == nil {
 				a.log.Warnf("got unknown type of node %T", node)
+				continue
 			}
+
+			results = append(results, res)
 		}
 
 		a.jsonResponse(w, &results, http.StatusOK)
 	}
 }
 
+func (a *Handler) getNode() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		node := a.dispenser.GetNode(id)
+		if node == nil {
+			a.jsonError(w, fmt.Sprintf("node %s not found", id), http.StatusNotFound)
+			return
+		}
+
+		res := a.getNodeResponse(node)
+		if res == nil {
+			a.jsonError(w, fmt.Sprintf("unknown node type %T", node), http.StatusBadRequest)
+			return
+		}
+
+		a.jsonResponse(w, res, http.StatusOK)
+	}
+}
+
 func (a *Handler) deleteNode() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
@@ -317,6 +838,45 @@ func (a *Handler) patchNode() http.HandlerFunc {
 					return
 				}
 			}
+		case "priority":
+			req := patchNodePriorityRequest{}
+			err := json.Unmarshal(body, &req)
+			if err != nil {
+				a.jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			err = a.dispenser.SetNodePriority(id, req.Priority)
+			if err != nil {
+				a.jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		case "backend":
+			req := patchNodeBackendRequest{}
+			err := json.Unmarshal(body, &req)
+			if err != nil {
+				a.jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			err = a.dispenser.SetNodeBackend(id, fromNodeBackend(&req.Backend))
+			if err != nil {
+				a.jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		case "autoUnlock":
+			req := patchNodeAutoUnlockRequest{}
+			err := json.Unmarshal(body, &req)
+			if err != nil {
+				a.jsonError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			err = a.dispenser.SetAutoUnlock(id, req.Enabled, req.Password)
+			if err != nil {
+				a.jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
 		case "unlock":
 			req := patchNodeUnlockRequest{}
 			err := json.Unmarshal(body, &req)
@@ -344,35 +904,17 @@ func (a *Handler) patchNode() http.HandlerFunc {
 				return
 			}
 		default:
-			a.jsonError(w, "Can only rename, enable, disable, init and unlock node.", http.StatusBadRequest)
+			a.jsonError(w, "Can only rename, enable, disable, prioritize, change backend, init, unlock and auto unlock node.", http.StatusBadRequest)
 			return
 		}
 
-		switch node := node.(type) {
-		case *nodeman.RemoteLndNode:
-			a.jsonResponse(w, &getNodesRemoteLndResponse{
-				ID:      node.ID(),
-				Type:    postNodesTypeRemoteLnd,
-				Uri:     node.Uri,
-				Name:    node.Name(),
-				Enabled: node.Enabled(),
-				Status:  nodeStatusString(node.Status()),
-			}, http.StatusOK)
-			return
-		case *nodeman.LocalNode:
-			a.jsonResponse(w, &getNodesLocalLndResponse{
-				ID:      node.ID(),
-				Type:    postNodesTypeLocal,
-				Uri:     node.Uri(),
-				Name:    node.Name(),
-				Enabled: node.Enabled(),
-				Status:  nodeStatusString(node.Status()),
-			}, http.StatusOK)
-			return
-		default:
+		res := a.getNodeResponse(node)
+		if res == nil {
 			a.jsonError(w, fmt.Sprintf("unknown node type %T", node), http.StatusBadRequest)
 			return
 		}
+
+		a.jsonResponse(w, res, http.StatusOK)
 	}
 }
 
@@ -512,12 +1054,143 @@ func (a *Handler) handlePostNodeConnection() http.HandlerFunc {
 			return
 		}
 
-		// TODO create a new macaroon here
+		if req.Expiry < 0 {
+			a.jsonError(w, "Expiry must not be negative", http.StatusBadRequest)
+			return
+		}
 
-		a.jsonResponse(w, &nodeConnectionResponse{
-			Uri:      localNode.Uri(),
-			Cert:     localNode.Cert(),
-			Macaroon: localNode.AdminMacaroon(),
-		}, http.StatusOK)
+		switch req.Format {
+		case "":
+			req.Format = nodeConnectionFormatJson
+		case nodeConnectionFormatJson, nodeConnectionFormatSvg, nodeConnectionFormatPng:
+		default:
+			a.jsonError(w, fmt.Sprintf("Unknown format %s", req.Format), http.StatusBadRequest)
+			return
+		}
+
+		// the inline cert is the base64 encoded DER certificate
+		certBytes, err := base64.StdEncoding.DecodeString(localNode.Cert())
+		if err != nil {
+			a.jsonError(w, fmt.Sprintf("Unable to decode cert: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		permissions := lightning.InvoicePermissions
+		if len(req.Permissions) > 0 {
+			permissions = nil
+
+			for _, value := range req.Permissions {
+				permission, err := lightning.ParseMacaroonPermission(value)
+				if err != nil {
+					a.jsonError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+
+				permissions = append(permissions, permission)
+			}
+		}
+
+		expiry := time.Duration(req.Expiry) * time.Millisecond
+
+		macaroonBytes, err := a.dispenser.BakeMacaroon(id, permissions, expiry)
+		if err != nil {
+			a.jsonError(w, fmt.Sprintf("Unable to bake macaroon: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		conn := &lndconnect.Connection{
+			Host:     localNode.Uri(),
+			Cert:     certBytes,
+			Macaroon: macaroonBytes,
+		}
+
+		switch req.Format {
+		case nodeConnectionFormatSvg, nodeConnectionFormatPng:
+			a.qrResponse(w, conn.String(), req.Format)
+			return
+		}
+
+		res := &nodeConnectionResponse{
+			Uri:         localNode.Uri(),
+			Cert:        localNode.Cert(),
+			Macaroon:    base64.StdEncoding.EncodeToString(macaroonBytes),
+			Lndconnect:  conn.String(),
+			Permissions: []string{},
+		}
+
+		for _, permission := range permissions {
+			res.Permissions = append(res.Permissions, permission.String())
+		}
+
+		if expiry > 0 {
+			expiresAt := time.Now().Add(expiry)
+			res.ExpiresAt = &expiresAt
+		}
+
+		a.jsonResponse(w, res, http.StatusOK)
+	}
+}
+
+func (a *Handler) handleGetNodeBackup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		node := a.dispenser.GetNode(id)
+		if node == nil {
+			a.jsonError(w, fmt.Sprintf("No node with id %s found", id), http.StatusNotFound)
+			return
+		}
+
+		backup, savedAt, err := a.dispenser.GetChannelBackup(id)
+		if err != nil {
+			a.jsonError(w, fmt.Sprintf("Unable to get channel backup: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if backup == nil {
+			a.jsonError(w, fmt.Sprintf("No channel backup saved for node %s", id), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"channel.backup\"")
+		w.Header().Set("Last-Modified", savedAt.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		w.Write(backup)
+	}
+}
+
+func (a *Handler) handlePostNodeRestore() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		req := postNodeRestoreRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		node := a.dispenser.GetNode(id)
+		if node == nil {
+			a.jsonError(w, fmt.Sprintf("No node with id %s found", id), http.StatusNotFound)
+			return
+		}
+
+		err = a.dispenser.RestoreNode(id, req.Password, req.Mnemonic, req.Backup)
+		if err != nil {
+			a.jsonError(w, fmt.Sprintf("Unable to restore channel backup: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		res := a.getNodeResponse(node)
+		if res == nil {
+			a.jsonError(w, fmt.Sprintf("unknown node type %T", node), http.StatusBadRequest)
+			return
+		}
+
+		a.jsonResponse(w, res, http.StatusOK)
 	}
 }
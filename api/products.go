@@ -0,0 +1,178 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"net/http"
+)
+
+type postProductRequest struct {
+	Name     string `json:"name"`
+	Price    *price `json:"price"`
+	ImageUrl string `json:"imageUrl"`
+	Slot     int    `json:"slot"`
+}
+
+type patchProductRequest struct {
+	Name     *string `json:"name"`
+	Price    *price  `json:"price"`
+	ImageUrl *string `json:"imageUrl"`
+	Slot     *int    `json:"slot"`
+}
+
+type productResponse struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Price    *price `json:"price"`
+	ImageUrl string `json:"imageUrl"`
+	Slot     int    `json:"slot"`
+}
+
+func toProductResponse(product *sweetdb.Product) *productResponse {
+	return &productResponse{
+		ID:       product.Id,
+		Name:     product.Name,
+		Price:    toPrice(product.Price),
+		ImageUrl: product.ImageUrl,
+		Slot:     product.Slot,
+	}
+}
+
+func fromPrice(p *price) *sweetdb.Price {
+	if p == nil {
+		return nil
+	}
+
+	return &sweetdb.Price{
+		Currency: p.Currency,
+		Amount:   p.Amount,
+	}
+}
+
+func (a *Handler) handleGetProducts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		products, err := a.dispenser.GetProducts()
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		results := []*productResponse{}
+
+		for _, product := range products {
+			results = append(results, toProductResponse(product))
+		}
+
+		a.jsonResponse(w, &results, http.StatusOK)
+	}
+}
+
+func (a *Handler) handlePostProduct() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := postProductRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		product, err := a.dispenser.AddProduct(&sweetdb.Product{
+			Name:     req.Name,
+			Price:    fromPrice(req.Price),
+			ImageUrl: req.ImageUrl,
+			Slot:     req.Slot,
+		})
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		a.jsonResponse(w, toProductResponse(product), http.StatusOK)
+	}
+}
+
+func (a *Handler) handleGetProduct() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		product, err := a.dispenser.GetProduct(id)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if product == nil {
+			a.jsonError(w, fmt.Sprintf("No product with id %s found", id), http.StatusNotFound)
+			return
+		}
+
+		a.jsonResponse(w, toProductResponse(product), http.StatusOK)
+	}
+}
+
+func (a *Handler) handlePatchProduct() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		req := patchProductRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		product, err := a.dispenser.GetProduct(id)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if product == nil {
+			a.jsonError(w, fmt.Sprintf("No product with id %s found", id), http.StatusNotFound)
+			return
+		}
+
+		if req.Name != nil {
+			product.Name = *req.Name
+		}
+
+		if req.Price != nil {
+			product.Price = fromPrice(req.Price)
+		}
+
+		if req.ImageUrl != nil {
+			product.ImageUrl = *req.ImageUrl
+		}
+
+		if req.Slot != nil {
+			product.Slot = *req.Slot
+		}
+
+		product, err = a.dispenser.UpdateProduct(product)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		a.jsonResponse(w, toProductResponse(product), http.StatusOK)
+	}
+}
+
+func (a *Handler) handleDeleteProduct() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		err := a.dispenser.RemoveProduct(id)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		a.emptyResponse(w, http.StatusNoContent)
+	}
+}
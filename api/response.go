@@ -2,9 +2,13 @@ package api
 
 import (
 	"encoding/json"
+	"github.com/the-lightning-land/sweetd/qrcode"
 	"net/http"
 )
 
+// qrScale is the number of pixels per module of png qr codes
+const qrScale = 8
+
 func (a *Handler) jsonResponse(w http.ResponseWriter, v interface{}, code int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -17,3 +21,31 @@ func (a *Handler) jsonResponse(w http.ResponseWriter, v interface{}, code int) {
 func (a *Handler) emptyResponse(w http.ResponseWriter, code int) {
 	w.WriteHeader(code)
 }
+
+// qrResponse responds with data as qr code in svg or png format
+func (a *Handler) qrResponse(w http.ResponseWriter, data string, format string) {
+	code, err := qrcode.Encode([]byte(data), qrcode.LevelL)
+	if err != nil {
+		a.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var image []byte
+	contentType := "image/svg+xml"
+
+	if format == nodeConnectionFormatPng {
+		image, err = code.PNG(qrScale)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		contentType = "image/png"
+	} else {
+		image = code.SVG()
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(image)
+}
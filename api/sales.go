@@ -0,0 +1,119 @@
+package api
+
+import (
+	"github.com/go-errors/errors"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultSalesLimit caps the number of sales returned if no limit is given
+const defaultSalesLimit = 100
+
+type saleResponse struct {
+	Time      time.Time `json:"time"`
+	Trigger   string    `json:"trigger"`
+	RHash     string    `json:"rHash"`
+	MSat      int64     `json:"msat"`
+	NodeId    string    `json:"nodeId"`
+	ProductId string    `json:"productId"`
+	Slot      int       `json:"slot"`
+	// RunTime is the motor run time in milliseconds
+	RunTime int64 `json:"runTime"`
+	Failed  bool  `json:"failed"`
+}
+
+type salesResponse struct {
+	Sales []*saleResponse `json:"sales"`
+	Total int             `json:"total"`
+}
+
+func toSaleResponse(sale *sweetdb.Sale) *saleResponse {
+	return &saleResponse{
+		Time:      sale.Time,
+		Trigger:   string(sale.Trigger),
+		RHash:     sale.RHash,
+		MSat:      sale.MSat,
+		NodeId:    sale.NodeId,
+		ProductId: sale.ProductId,
+		Slot:      sale.Slot,
+		RunTime:   int64(sale.RunTime / time.Millisecond),
+		Failed:    sale.Failed,
+	}
+}
+
+// parseSalesQuery reads the from and to times as RFC 3339 and the
+// offset and limit of the requested page
+func parseSalesQuery(r *http.Request) (*sweetdb.SalesQuery, error) {
+	query := &sweetdb.SalesQuery{
+		Limit: defaultSalesLimit,
+	}
+
+	values := r.URL.Query()
+
+	if from := values.Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return nil, errors.Errorf("from is not a RFC 3339 time: %v", err)
+		}
+
+		query.From = t
+	}
+
+	if to := values.Get("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return nil, errors.Errorf("to is not a RFC 3339 time: %v", err)
+		}
+
+		query.To = t
+	}
+
+	if offset := values.Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return nil, errors.Errorf("offset must be a non-negative number")
+		}
+
+		query.Offset = n
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return nil, errors.Errorf("limit must be a positive number")
+		}
+
+		query.Limit = n
+	}
+
+	return query, nil
+}
+
+func (a *Handler) handleGetSales() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseSalesQuery(r)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sales, total, err := a.dispenser.GetSales(query)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		res := &salesResponse{
+			Sales: []*saleResponse{},
+			Total: total,
+		}
+
+		for _, sale := range sales {
+			res.Sales = append(res.Sales, toSaleResponse(sale))
+		}
+
+		a.jsonResponse(w, res, http.StatusOK)
+	}
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultSweepsLimit caps the number of sweeps returned if no limit is
+// given
+const defaultSweepsLimit = 100
+
+// sweepConfig is the api representation of a sweep config with the
+// interval in milliseconds and all amounts in satoshis
+type sweepConfig struct {
+	Enabled          bool   `json:"enabled"`
+	NodeId           string `json:"nodeId"`
+	Interval         int64  `json:"interval"`
+	Threshold        int64  `json:"threshold"`
+	Address          string `json:"address"`
+	LightningAddress string `json:"lightningAddress"`
+	SatPerByte       int64  `json:"satPerByte"`
+	MaxFee           int64  `json:"maxFee"`
+}
+
+// sweepResponse holds the amount in satoshis
+type sweepResponse struct {
+	Id               uint64    `json:"id"`
+	Time             time.Time `json:"time"`
+	NodeId           string    `json:"nodeId"`
+	Amount           int64     `json:"amount"`
+	Address          string    `json:"address"`
+	LightningAddress string    `json:"lightningAddress"`
+	Txid             string    `json:"txid"`
+	PaymentHash      string    `json:"paymentHash"`
+	Error            string    `json:"error"`
+}
+
+type sweepsResponse struct {
+	Sweeps []*sweepResponse `json:"sweeps"`
+}
+
+type postSweepResponse struct {
+	// Sweep is null if there was nothing to sweep
+	Sweep *sweepResponse `json:"sweep"`
+}
+
+func toSweepConfig(config *sweetdb.SweepConfig) *sweepConfig {
+	if config == nil {
+		return nil
+	}
+
+	return &sweepConfig{
+		Enabled:          config.Enabled,
+		NodeId:           config.NodeId,
+		Interval:         int64(config.Interval / time.Millisecond),
+		Threshold:        config.Threshold,
+		Address:          config.Address,
+		LightningAddress: config.LightningAddress,
+		SatPerByte:       config.SatPerByte,
+		MaxFee:           config.MaxFee,
+	}
+}
+
+func fromSweepConfig(config *sweepConfig) *sweetdb.SweepConfig {
+	return &sweetdb.SweepConfig{
+		Enabled:          config.Enabled,
+		NodeId:           config.NodeId,
+		Interval:         time.Duration(config.Interval) * time.Millisecond,
+		Threshold:        config.Threshold,
+		Address:          config.Address,
+		LightningAddress: config.LightningAddress,
+		SatPerByte:       config.SatPerByte,
+		MaxFee:           config.MaxFee,
+	}
+}
+
+func toSweepResponse(sweep *sweetdb.Sweep) *sweepResponse {
+	if sweep == nil {
+		return nil
+	}
+
+	return &sweepResponse{
+		Id:               sweep.Id,
+		Time:             sweep.Time,
+		NodeId:           sweep.NodeId,
+		Amount:           sweep.Amount,
+		Address:          sweep.Address,
+		LightningAddress: sweep.LightningAddress,
+		Txid:             sweep.Txid,
+		PaymentHash:      sweep.PaymentHash,
+		Error:            sweep.Error,
+	}
+}
+
+// handleGetSweeps returns the latest sweeps, newest first
+func (a *Handler) handleGetSweeps() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultSweepsLimit
+
+		if value := r.URL.Query().Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				a.jsonError(w, "limit must be a positive number", http.StatusBadRequest)
+				return
+			}
+
+			limit = n
+		}
+
+		sweeps, err := a.dispenser.GetSweeps(limit)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		res := &sweepsResponse{
+			Sweeps: []*sweepResponse{},
+		}
+
+		for _, sweep := range sweeps {
+			res.Sweeps = append(res.Sweeps, toSweepResponse(sweep))
+		}
+
+		a.jsonResponse(w, res, http.StatusOK)
+	}
+}
+
+// handlePostSweeps sweeps right away instead of waiting for the interval
+func (a *Handler) handlePostSweeps() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sweep, err := a.dispenser.Sweep()
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		a.jsonResponse(w, &postSweepResponse{
+			Sweep: toSweepResponse(sweep),
+		}, http.StatusOK)
+	}
+}
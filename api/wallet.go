@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/the-lightning-land/sweetd/lightning"
+	"net/http"
+)
+
+// walletResponse holds all amounts in satoshis
+type walletResponse struct {
+	Confirmed   int64 `json:"confirmed"`
+	Unconfirmed int64 `json:"unconfirmed"`
+	Channels    int64 `json:"channels"`
+}
+
+type addressResponse struct {
+	Address string `json:"address"`
+}
+
+type postSendRequest struct {
+	Address string `json:"address"`
+	// Amount in satoshis, ignored if all funds are sent
+	Amount     int64 `json:"amount"`
+	SatPerByte int64 `json:"satPerByte"`
+	SendAll    bool  `json:"sendAll"`
+}
+
+type postSendResponse struct {
+	Txid string `json:"txid"`
+}
+
+func (a *Handler) getNodeWallet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		balance, err := a.dispenser.GetWalletBalance(id)
+		if err != nil {
+			a.jsonError(w, fmt.Sprintf("Unable to get balance: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		a.jsonResponse(w, &walletResponse{
+			Confirmed:   balance.Confirmed,
+			Unconfirmed: balance.Unconfirmed,
+			Channels:    balance.Channels,
+		}, http.StatusOK)
+	}
+}
+
+func (a *Handler) postNodeWalletAddress() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		address, err := a.dispenser.NewAddress(id)
+		if err != nil {
+			a.jsonError(w, fmt.Sprintf("Unable to create address: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		a.jsonResponse(w, &addressResponse{
+			Address: address,
+		}, http.StatusOK)
+	}
+}
+
+func (a *Handler) postNodeWalletSend() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		req := postSendRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			a.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.Address == "" || (!req.SendAll && req.Amount <= 0) || req.SatPerByte < 0 {
+			a.jsonError(w, "Address and a positive amount are required", http.StatusBadRequest)
+			return
+		}
+
+		txid, err := a.dispenser.SendCoins(id, &lightning.SendCoinsRequest{
+			Address:    req.Address,
+			Amount:     req.Amount,
+			SatPerByte: req.SatPerByte,
+			SendAll:    req.SendAll,
+		})
+		if err != nil {
+			a.jsonError(w, fmt.Sprintf("Unable to send: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		a.jsonResponse(w, &postSendResponse{
+			Txid: txid,
+		}, http.StatusOK)
+	}
+}
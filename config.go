@@ -2,12 +2,14 @@ package main
 
 import (
 	"github.com/jessevdk/go-flags"
+	"github.com/the-lightning-land/sweetd/pricing"
 )
 
 type raspberryConfig struct {
-	TouchPin  string `long:"touchpin" description:"BCM number of the touch input pin."`
-	MotorPin  string `long:"motorpin" description:"BCM number of the motor output pin."`
-	BuzzerPin string `long:"buzzerpin" description:"BCM number of the buzzer output pin."`
+	TouchPin  string   `long:"touchpin" description:"BCM number of the touch input pin."`
+	MotorPin  string   `long:"motorpin" description:"BCM number of the motor output pin."`
+	SlotPins  []string `long:"slotpin" description:"BCM number of the motor output pin of an additional dispensing slot. Can be repeated."`
+	BuzzerPin string   `long:"buzzerpin" description:"BCM number of the buzzer output pin."`
 }
 
 type mockConfig struct {
@@ -18,6 +20,11 @@ type torConfig struct {
 	Path string `long:"path" description:"The path to the Tor binary."`
 }
 
+type ratesConfig struct {
+	Url  string `long:"url" description:"Url of a CoinGecko compatible exchange rates api."`
+	File string `long:"file" description:"Path to a JSON file with exchange rates, used instead of the api."`
+}
+
 type profilingConfig struct {
 	Listen string `long:"listen" description:"Add an interface/port to listen for profiling data."`
 }
@@ -41,6 +48,7 @@ type config struct {
 	DataDir     string           `long:"datadir" description:"The directory to store sweetd's data within.'"`
 	Updater     string           `long:"updater" description:"The updater to use." choice:"none" choice:"mender"`
 	Tor         *torConfig       `group:"Tor" namespace:"tor"`
+	Rates       *ratesConfig     `group:"Rates" namespace:"rates"`
 	Profiling   *profilingConfig `group:"Profiling" namespace:"profiling"`
 }
 
@@ -60,6 +68,9 @@ func loadConfig() (*config, error) {
 		Tor: &torConfig{
 			Path: "",
 		},
+		Rates: &ratesConfig{
+			Url: pricing.DefaultRatesUrl,
+		},
 	}
 
 	preCfg := defaultCfg
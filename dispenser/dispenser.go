@@ -7,13 +7,14 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/the-lightning-land/sweetd/api"
 	"github.com/the-lightning-land/sweetd/app"
-	"github.com/the-lightning-land/sweetd/lightning"
+	"github.com/the-lightning-land/sweetd/lnurl"
 	"github.com/the-lightning-land/sweetd/machine"
 	"github.com/the-lightning-land/sweetd/network"
 	"github.com/the-lightning-land/sweetd/nodeman"
 	"github.com/the-lightning-land/sweetd/onion"
 	"github.com/the-lightning-land/sweetd/pairing"
 	"github.com/the-lightning-land/sweetd/pos"
+	"github.com/the-lightning-land/sweetd/pricing"
 	"github.com/the-lightning-land/sweetd/reboot"
 	"github.com/the-lightning-land/sweetd/state"
 	"github.com/the-lightning-land/sweetd/sweetdb"
@@ -46,6 +47,7 @@ type Config struct {
 	Network  network.Network
 	Nodeman  *nodeman.Nodeman
 	Pairing  pairing.Controller
+	Rates    pricing.RateProvider
 }
 
 type Dispenser struct {
@@ -67,6 +69,28 @@ type Dispenser struct {
 	// buzzOnDispense indicates if the dispenser should buzz during dispensing
 	buzzOnDispense bool
 
+	// dispensePolicy turns the amount of a payment into a dispense duration
+	dispensePolicy *sweetdb.DispensePolicy
+
+	// price of a dispense, either in satoshis or in a fiat currency
+	price *sweetdb.Price
+
+	// nodeSelection decides which node issues an invoice
+	nodeSelection sweetdb.NodeSelection
+
+	// rates provides exchange rates for fiat prices
+	rates pricing.RateProvider
+
+	// sweepConfig moves earnings off the node, if enabled
+	sweepConfig   *sweetdb.SweepConfig
+	sweepConfigMu sync.Mutex
+
+	// sweepMu makes sure only a single sweep runs at a time
+	sweepMu sync.Mutex
+
+	// lnurl requests invoices from Lightning addresses for sweeps
+	lnurl *lnurl.Client
+
 	// apiOnionService
 	apiOnionService *onion.Service
 
@@ -79,8 +103,25 @@ type Dispenser struct {
 	// dispenses signals whenever
 	dispenses chan DispenseState
 
-	// payments
-	payments chan *lightning.Invoice
+	// dispenseQueueSignal wakes up the dispense queue worker
+	dispenseQueueSignal chan struct{}
+
+	// dispenseQueueDone is closed once the dispense queue worker stopped
+	dispenseQueueDone chan struct{}
+
+	// motorMu hands the motors either to touches or to the dispense queue
+	motorMu sync.Mutex
+
+	// touchStarted is the time a touch dispense started, zero if none is running
+	touchStarted time.Time
+
+	// queueDispensing is set while the dispense queue worker runs a motor,
+	// touches are ignored meanwhile
+	queueDispensing bool
+
+	// settleProgress tracks the processed settled invoices per node
+	settleProgress   map[string]*settleProgress
+	settleProgressMu sync.Mutex
 
 	// subscribers to dispense events
 	dispenseClients map[uint32]*DispenseClient
@@ -118,18 +159,21 @@ type Dispenser struct {
 
 func NewDispenser(config *Config) *Dispenser {
 	dispenser := &Dispenser{
-		nodeman:         config.Nodeman,
-		pairing:         config.Pairing,
-		machine:         config.Machine,
-		network:         config.Network,
-		db:              config.DB,
-		payments:        make(chan *lightning.Invoice),
-		dispenseClients: make(map[uint32]*DispenseClient),
-		updater:         config.Updater,
-		sweetLog:        config.SweetLog,
-		log:             config.Logger,
-		tor:             config.Tor,
-		state:           state.StateStopped,
+		nodeman:             config.Nodeman,
+		pairing:             config.Pairing,
+		machine:             config.Machine,
+		network:             config.Network,
+		db:                  config.DB,
+		dispenseClients:     make(map[uint32]*DispenseClient),
+		dispenseQueueSignal: make(chan struct{}, 1),
+		settleProgress:      make(map[string]*settleProgress),
+		updater:             config.Updater,
+		sweetLog:            config.SweetLog,
+		log:                 config.Logger,
+		tor:                 config.Tor,
+		rates:               config.Rates,
+		lnurl:               lnurl.New(&lnurl.Config{Tor: config.Tor}),
+		state:               state.StateStopped,
 		posOnionService: onion.NewService(&onion.ServiceConfig{
 			Tor:    config.Tor,
 			Logger: config.Logger.WithField("system", "onion").WithField("for", "pos"),
@@ -186,6 +230,48 @@ func (d *Dispenser) restoreConfigs() {
 
 	d.buzzOnDispense = buzzOnDispense
 
+	dispensePolicy, err := d.db.GetDispensePolicy()
+	if err != nil {
+		d.log.Errorf("could not get dispense policy: %v", err)
+	}
+
+	if dispensePolicy == nil {
+		policy := defaultDispensePolicy
+		dispensePolicy = &policy
+	}
+
+	d.dispensePolicy = dispensePolicy
+
+	price, err := d.db.GetPrice()
+	if err != nil {
+		d.log.Errorf("could not get price: %v", err)
+	}
+
+	if price == nil {
+		p := defaultPrice
+		price = &p
+	}
+
+	d.price = price
+
+	nodeSelection, err := d.db.GetNodeSelection()
+	if err != nil {
+		d.log.Errorf("could not get node selection: %v", err)
+	}
+
+	if nodeSelection == "" {
+		nodeSelection = sweetdb.NodeSelectionPriority
+	}
+
+	d.nodeSelection = nodeSelection
+
+	sweepConfig, err := d.db.GetSweepConfig()
+	if err != nil {
+		d.log.Errorf("could not get sweep config: %v", err)
+	}
+
+	d.sweepConfig = sweepConfig
+
 	posPrivateKey, err := d.db.GetPosPrivateKey()
 	if err != nil {
 		d.log.Warnf("Could not read PoS private key: %v", err)
@@ -234,8 +320,8 @@ func (d *Dispenser) restoreConfigs() {
 }
 
 // handleDispenses is run as a goroutine and handles dispenses
-func (d *Dispenser) handleDispenses(wg sync.WaitGroup) {
-	wg.Add(1)
+func (d *Dispenser) handleDispenses(wg *sync.WaitGroup) {
+	defer wg.Done()
 
 	d.log.Infof("started handling dispenses")
 
@@ -245,22 +331,7 @@ func (d *Dispenser) handleDispenses(wg sync.WaitGroup) {
 	for !done {
 		select {
 		case on := <-touchesClient.Touches:
-			// react on direct touch events of the machine
-			if d.dispenseOnTouch && on {
-				d.ToggleDispense(true)
-			} else {
-				d.ToggleDispense(false)
-			}
-
-		case <-d.payments:
-			// react on incoming payments
-			dispense := 1500 * time.Millisecond
-
-			d.log.Debugf("Dispensing for a duration of %v", dispense)
-
-			d.ToggleDispense(true)
-			time.Sleep(dispense)
-			d.ToggleDispense(false)
+			d.handleTouch(on)
 
 		case <-d.done:
 			// finish loop when program is done
@@ -271,8 +342,44 @@ func (d *Dispenser) handleDispenses(wg sync.WaitGroup) {
 	touchesClient.Cancel()
 
 	d.log.Infof("stopped handling dispenses")
+}
 
-	wg.Done()
+// handleTouch reacts on direct touch events of the machine, unless a
+// queued dispense is running which must not be cut short
+func (d *Dispenser) handleTouch(on bool) {
+	d.motorMu.Lock()
+	defer d.motorMu.Unlock()
+
+	if d.queueDispensing {
+		return
+	}
+
+	if d.dispenseOnTouch && on {
+		d.ToggleDispense(true)
+
+		if d.touchStarted.IsZero() {
+			d.touchStarted = time.Now()
+		}
+	} else {
+		d.ToggleDispense(false)
+		d.endTouchDispense()
+	}
+}
+
+// endTouchDispense records the running touch dispense, the caller has to
+// hold motorMu and stop the motor
+func (d *Dispenser) endTouchDispense() {
+	if d.touchStarted.IsZero() {
+		return
+	}
+
+	d.recordSale(&sweetdb.Sale{
+		Time:    time.Now(),
+		Trigger: sweetdb.SaleTriggerTouch,
+		RunTime: time.Since(d.touchStarted),
+	})
+
+	d.touchStarted = time.Time{}
 }
 
 // notifyDispenseSubscribers is run as a goroutine and notifies all dispense
@@ -344,6 +451,7 @@ func (d *Dispenser) RunAndWait() error {
 
 	// initialize a new done channel to be closed to stop the dispenser
 	d.done = make(chan struct{})
+	d.dispenseQueueDone = make(chan struct{})
 
 	// restore configs from the database
 	d.restoreConfigs()
@@ -354,7 +462,10 @@ func (d *Dispenser) RunAndWait() error {
 	go d.maybeAttemptSavedWifiConnection(wg)
 	go d.notifyDispenseSubscribers(wg)
 	go d.runLightningNodes(wg)
-	go d.handleDispenses(wg)
+	wg.Add(2)
+	go d.handleDispenses(&wg)
+	go d.processDispenseQueue(&wg)
+	go d.runSweeps()
 
 	//go func() {
 	//	check, err := onion.Check(d.tor)
@@ -401,30 +512,45 @@ func (d *Dispenser) RunAndWait() error {
 Teardown:
 	d.state = state.StateStopping
 
-	// tear off dispenses channel
-	close(d.dispenses)
-	d.dispenses = nil
-
 	// wait for all registered tasks to finish
 	wg.Wait()
 
+	// tear off dispenses channel once nothing toggles dispenses anymore
+	close(d.dispenses)
+	d.dispenses = nil
+
 	d.state = state.StateStopped
 
 	return err
 }
 
 func (d *Dispenser) ToggleDispense(on bool) {
+	d.toggleSlotDispense(0, on)
+}
+
+func (d *Dispenser) toggleSlotDispense(slot int, on bool) {
 	// Always make sure that buzzing stops
 	if d.buzzOnDispense || !on {
 		d.machine.ToggleBuzzer(on)
 	}
 
-	d.machine.ToggleMotor(on)
+	d.machine.ToggleMotor(slot, on)
 
+	dispenseState := DispenseStateOff
 	if on {
-		d.dispenses <- DispenseStateOn
-	} else {
-		d.dispenses <- DispenseStateOff
+		dispenseState = DispenseStateOn
+	}
+
+	// subscribers aren't notified anymore once the dispenser is stopping
+	select {
+	case <-d.done:
+		return
+	default:
+	}
+
+	select {
+	case d.dispenses <- dispenseState:
+	case <-d.done:
 	}
 }
 
@@ -0,0 +1,193 @@
+package dispenser
+
+import (
+	"github.com/go-errors/errors"
+	"github.com/the-lightning-land/sweetd/state"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"time"
+)
+
+// tracked tells whether an inventory was calibrated, as the fill level
+// of uncalibrated slots can't be estimated
+func tracked(inventory *sweetdb.Inventory) bool {
+	return inventory != nil && inventory.Capacity > 0 && inventory.GramsPerSecond > 0
+}
+
+// remainingGrams estimates the grams left in a slot from the motor run
+// time since it was refilled
+func remainingGrams(inventory *sweetdb.Inventory) float64 {
+	remaining := inventory.Capacity - inventory.RunTime.Seconds()*inventory.GramsPerSecond
+	if remaining < 0 {
+		return 0
+	}
+
+	return remaining
+}
+
+func stockLevel(inventory *sweetdb.Inventory) state.Stock {
+	if !tracked(inventory) {
+		return state.StockOk
+	}
+
+	remaining := remainingGrams(inventory)
+
+	if remaining <= 0 {
+		return state.StockEmpty
+	}
+
+	if remaining < inventory.LowThreshold {
+		return state.StockLow
+	}
+
+	return state.StockOk
+}
+
+func (d *Dispenser) getInventory(slot int) (*sweetdb.Inventory, error) {
+	inventory, err := d.db.GetInventory(slot)
+	if err != nil {
+		return nil, errors.Errorf("unable to get inventory of slot %d: %v", slot, err)
+	}
+
+	if inventory == nil {
+		inventory = &sweetdb.Inventory{Slot: slot}
+	}
+
+	return inventory, nil
+}
+
+// GetInventories returns the inventory of every slot of the machine
+func (d *Dispenser) GetInventories() ([]*sweetdb.Inventory, error) {
+	inventories := []*sweetdb.Inventory{}
+
+	for slot := 0; slot < d.machine.Slots(); slot++ {
+		inventory, err := d.getInventory(slot)
+		if err != nil {
+			return nil, err
+		}
+
+		inventories = append(inventories, inventory)
+	}
+
+	return inventories, nil
+}
+
+// RemainingGrams estimates the grams left in a slot, or returns false if
+// the slot wasn't calibrated
+func (d *Dispenser) RemainingGrams(slot int) (float64, bool) {
+	inventory, err := d.getInventory(slot)
+	if err != nil {
+		d.log.Errorf("could not get inventory: %v", err)
+		return 0, false
+	}
+
+	if !tracked(inventory) {
+		return 0, false
+	}
+
+	return remainingGrams(inventory), true
+}
+
+// CalibrateInventory sets the capacity, dispense rate and low threshold
+// of a slot while keeping the run time accumulated since the last refill
+func (d *Dispenser) CalibrateInventory(calibration *sweetdb.Inventory) (*sweetdb.Inventory, error) {
+	if calibration.Slot < 0 || calibration.Slot >= d.machine.Slots() {
+		return nil, errors.Errorf("slot %d not available, machine has %d slots", calibration.Slot, d.machine.Slots())
+	}
+
+	if calibration.Capacity < 0 || calibration.GramsPerSecond < 0 || calibration.LowThreshold < 0 {
+		return nil, errors.Errorf("inventory values must not be negative")
+	}
+
+	d.log.Infof("Calibrating inventory of slot %d", calibration.Slot)
+
+	inventory, err := d.db.UpdateInventory(calibration.Slot, func(inventory *sweetdb.Inventory) {
+		inventory.Capacity = calibration.Capacity
+		inventory.GramsPerSecond = calibration.GramsPerSecond
+		inventory.LowThreshold = calibration.LowThreshold
+	})
+	if err != nil {
+		return nil, errors.Errorf("Failed calibrating inventory: %v", err)
+	}
+
+	return inventory, nil
+}
+
+// Refill resets the run time of a slot after it was filled up again
+func (d *Dispenser) Refill(slot int) error {
+	if slot < 0 || slot >= d.machine.Slots() {
+		return errors.Errorf("slot %d not available, machine has %d slots", slot, d.machine.Slots())
+	}
+
+	d.log.Infof("Refilled slot %d", slot)
+
+	_, err := d.db.UpdateInventory(slot, func(inventory *sweetdb.Inventory) {
+		inventory.RunTime = 0
+		inventory.Refilled = time.Now()
+	})
+	if err != nil {
+		return errors.Errorf("Failed refilling slot: %v", err)
+	}
+
+	return nil
+}
+
+// GetSlotStock returns the estimated fill level of a slot
+func (d *Dispenser) GetSlotStock(slot int) state.Stock {
+	inventory, err := d.getInventory(slot)
+	if err != nil {
+		d.log.Errorf("could not get inventory: %v", err)
+		return state.StockOk
+	}
+
+	return stockLevel(inventory)
+}
+
+// GetStock returns the fill level of the dispenser, which is empty if
+// all calibrated slots are empty and low if any of them runs low
+func (d *Dispenser) GetStock() state.Stock {
+	inventories, err := d.GetInventories()
+	if err != nil {
+		d.log.Errorf("could not get inventories: %v", err)
+		return state.StockOk
+	}
+
+	stock := state.StockOk
+	empty := 0
+	calibrated := 0
+
+	for _, inventory := range inventories {
+		if !tracked(inventory) {
+			continue
+		}
+
+		calibrated++
+
+		switch stockLevel(inventory) {
+		case state.StockEmpty:
+			empty++
+			stock = state.StockLow
+		case state.StockLow:
+			stock = state.StockLow
+		}
+	}
+
+	if calibrated > 0 && empty == calibrated {
+		return state.StockEmpty
+	}
+
+	return stock
+}
+
+// consumeInventory accounts the run time of a dispense against the
+// inventory of its slot
+func (d *Dispenser) consumeInventory(slot int, runTime time.Duration) {
+	err := d.db.AddInventoryRunTime(slot, runTime)
+	if err != nil {
+		d.log.Errorf("could not update inventory of slot %d: %v", slot, err)
+		return
+	}
+
+	if stock := d.GetSlotStock(slot); stock != state.StockOk {
+		d.log.Warnf("stock of slot %d is %s", slot, stock)
+	}
+}
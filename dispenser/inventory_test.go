@@ -0,0 +1,72 @@
+package dispenser
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/the-lightning-land/sweetd/machine"
+	"github.com/the-lightning-land/sweetd/state"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStockLevel(t *testing.T) {
+	t.Parallel()
+
+	inventory := &sweetdb.Inventory{
+		Capacity:       100,
+		GramsPerSecond: 2,
+		LowThreshold:   20,
+	}
+
+	assert.Equal(t, state.StockOk, stockLevel(inventory))
+
+	inventory.RunTime = 45 * time.Second
+	assert.Equal(t, state.StockLow, stockLevel(inventory))
+
+	inventory.RunTime = time.Minute
+	assert.Equal(t, state.StockEmpty, stockLevel(inventory))
+	assert.Equal(t, float64(0), remainingGrams(inventory))
+
+	assert.Equal(t, state.StockOk, stockLevel(&sweetdb.Inventory{RunTime: time.Hour}))
+}
+
+func TestCalibrateKeepsRunTime(t *testing.T) {
+	t.Parallel()
+
+	d, cleanup := newTestDispenser(t, machine.NewMockMachine(""))
+	defer cleanup()
+
+	var wg sync.WaitGroup
+
+	// dispenses finishing during calibrations keep their run time
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			d.consumeInventory(1, time.Second)
+		}()
+
+		go func() {
+			defer wg.Done()
+			_, err := d.CalibrateInventory(&sweetdb.Inventory{Slot: 1, Capacity: 100, GramsPerSecond: 1})
+			assert.Equal(t, nil, err)
+		}()
+	}
+
+	wg.Wait()
+
+	inventory, err := d.getInventory(1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 20*time.Second, inventory.RunTime)
+	assert.Equal(t, float64(100), inventory.Capacity)
+
+	assert.Equal(t, nil, d.Refill(1))
+
+	inventory, err = d.getInventory(1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, time.Duration(0), inventory.RunTime)
+	assert.Equal(t, float64(100), inventory.Capacity)
+	assert.False(t, inventory.Refilled.IsZero())
+}
@@ -4,7 +4,9 @@ import (
 	"github.com/go-errors/errors"
 	"github.com/the-lightning-land/sweetd/lightning"
 	"github.com/the-lightning-land/sweetd/nodeman"
+	"github.com/the-lightning-land/sweetd/sweetdb"
 	"sync"
+	"time"
 )
 
 // runLightningNodes
@@ -32,6 +34,7 @@ func (d *Dispenser) runLightningNodes(wg sync.WaitGroup) {
 			d.log.Infof("Network changed to %v", update)
 
 			if update.Connected {
+				d.nodeman.NetworkChanged()
 				d.startLightningNodes()
 			}
 		case <-d.done:
@@ -41,6 +44,9 @@ func (d *Dispenser) runLightningNodes(wg sync.WaitGroup) {
 
 	networkClient.Cancel()
 
+	// wait for held payments to be settled or canceled
+	<-d.dispenseQueueDone
+
 	for _, node := range d.nodeman.GetNodes() {
 		err := node.Stop()
 		if err != nil {
@@ -55,7 +61,20 @@ func (d *Dispenser) runLightningNodes(wg sync.WaitGroup) {
 
 func (d *Dispenser) startLightningNodes() {
 	for _, node := range d.nodeman.GetNodes() {
+		// nodes which are running already keep their subscriptions
+		if node.Status() != lightning.StatusStopped && node.Status() != lightning.StatusFailed {
+			continue
+		}
+
 		if node.Enabled() {
+			// failed nodes are reset before starting them again
+			if node.Status() == lightning.StatusFailed {
+				err := node.Stop()
+				if err != nil {
+					d.log.Errorf("could not stop failed node %v", node)
+				}
+			}
+
 			err := node.Start()
 			if err != nil {
 				d.log.Errorf("could not start node %v", node)
@@ -67,12 +86,12 @@ func (d *Dispenser) startLightningNodes() {
 				d.log.Errorf("could not subscribe to invoices: %v", err)
 			}
 
-			go d.handleLightningNodeInvoices(client)
+			go d.handleLightningNodeInvoices(node, client)
 		}
 	}
 }
 
-func (d *Dispenser) handleLightningNodeInvoices(client *lightning.InvoicesClient) {
+func (d *Dispenser) handleLightningNodeInvoices(node nodeman.LightningNode, client *lightning.InvoicesClient) {
 	d.log.Infof("start handling lightning invoices")
 
 	for {
@@ -82,9 +101,31 @@ func (d *Dispenser) handleLightningNodeInvoices(client *lightning.InvoicesClient
 			break
 		}
 
+		record := d.getInvoice(invoice.RHash)
+
+		// hold invoices are dispensed once accepted and settled afterwards
+		if record != nil && record.Preimage != "" {
+			if invoice.Accepted {
+				err := d.enqueueHeldPayment(node.ID(), invoice, record)
+				if err != nil {
+					d.log.Errorf("could not enqueue held payment of invoice %s: %v", invoice.RHash, err)
+				}
+			}
+
+			d.saveSettleIndex(node.ID(), invoice)
+
+			continue
+		}
+
 		if invoice.Settled {
-			d.payments <- invoice
+			err := d.enqueuePayment(node.ID(), invoice, record)
+			if err != nil {
+				d.log.Errorf("could not enqueue payment of invoice %s: %v", invoice.RHash, err)
+				continue
+			}
 		}
+
+		d.saveSettleIndex(node.ID(), invoice)
 	}
 }
 
@@ -92,6 +133,47 @@ func (d *Dispenser) GetNodes() []nodeman.LightningNode {
 	return d.nodeman.GetNodes()
 }
 
+func (d *Dispenser) GetActiveNodes() []nodeman.LightningNode {
+	return d.nodeman.GetActiveNodes()
+}
+
+// SelectNodes returns the nodes that can receive the given amount in the
+// order they should issue the invoice
+func (d *Dispenser) SelectNodes(msat int64) []nodeman.LightningNode {
+	return d.nodeman.SelectNodes(d.nodeSelection, msat)
+}
+
+func (d *Dispenser) GetNodeDecision(id string) *nodeman.Decision {
+	return d.nodeman.GetDecision(id)
+}
+
+func (d *Dispenser) GetNodeSelection() sweetdb.NodeSelection {
+	return d.nodeSelection
+}
+
+func (d *Dispenser) SetNodeSelection(selection sweetdb.NodeSelection) error {
+	d.log.Infof("Setting node selection")
+
+	switch selection {
+	case sweetdb.NodeSelectionPriority, sweetdb.NodeSelectionMostInbound, sweetdb.NodeSelectionRoundRobin:
+	default:
+		return errors.Errorf("Invalid node selection %s", selection)
+	}
+
+	err := d.db.SetNodeSelection(selection)
+	if err != nil {
+		return errors.Errorf("Failed setting node selection: %v", err)
+	}
+
+	d.nodeSelection = selection
+
+	return nil
+}
+
+func (d *Dispenser) SetNodePriority(id string, priority int) error {
+	return d.nodeman.SetNodePriority(id, priority)
+}
+
 func (d *Dispenser) GetNode(id string) nodeman.LightningNode {
 	return d.nodeman.GetNode(id)
 }
@@ -100,6 +182,10 @@ func (d *Dispenser) AddNode(config nodeman.NodeConfig) (nodeman.LightningNode, e
 	return d.nodeman.AddNode(config)
 }
 
+func (d *Dispenser) TestNode(config nodeman.NodeConfig) (*lightning.Diagnosis, error) {
+	return d.nodeman.TestNode(config)
+}
+
 func (d *Dispenser) RemoveNode(id string) error {
 	return d.nodeman.RemoveNode(id)
 }
@@ -121,7 +207,7 @@ func (d *Dispenser) EnableNode(id string) error {
 		return errors.Errorf("unable to subscribe to invoices: %v", err)
 	}
 
-	go d.handleLightningNodeInvoices(client)
+	go d.handleLightningNodeInvoices(node, client)
 
 	return d.nodeman.EnableNode(id)
 }
@@ -141,6 +227,119 @@ func (d *Dispenser) DisableNode(id string) error {
 	return d.nodeman.DisableNode(id)
 }
 
+// SetNodeBackend changes the chain backend of a local node and restarts
+// it if it is running
+func (d *Dispenser) SetNodeBackend(id string, backend *lightning.Backend) error {
+	err := d.nodeman.SetNodeBackend(id, backend)
+	if err != nil {
+		return err
+	}
+
+	node := d.nodeman.GetNode(id)
+
+	if !node.Enabled() || node.Status() == lightning.StatusStopped {
+		return nil
+	}
+
+	d.log.Infof("Restarting node %s with new backend", id)
+
+	err = node.Stop()
+	if err != nil {
+		return errors.Errorf("unable to stop node %v", err)
+	}
+
+	err = node.Start()
+	if err != nil {
+		return errors.Errorf("unable to start node %v", err)
+	}
+
+	client, err := node.SubscribeInvoices()
+	if err != nil {
+		return errors.Errorf("unable to subscribe to invoices: %v", err)
+	}
+
+	go d.handleLightningNodeInvoices(node, client)
+
+	return nil
+}
+
+func (d *Dispenser) SetAutoUnlock(id string, enabled bool, password string) error {
+	return d.nodeman.SetAutoUnlock(id, enabled, password)
+}
+
+// GetChannelBackup returns the latest channel backup of a node and when
+// it was saved, or nil if none was saved yet
+func (d *Dispenser) GetChannelBackup(id string) ([]byte, time.Time, error) {
+	return d.nodeman.GetChannelBackup(id)
+}
+
+// RestoreNode recovers the channel funds of a node from a channel backup,
+// which defaults to the saved one. With a mnemonic the wallet is created
+// from the seed first, otherwise the backup goes into the running wallet.
+func (d *Dispenser) RestoreNode(id string, password string, mnemonic []string, backup []byte) error {
+	node, ok := d.nodeman.GetNode(id).(lightning.BackupNode)
+	if !ok {
+		return errors.Errorf("node with id %s can not restore channel backups", id)
+	}
+
+	if len(backup) == 0 {
+		saved, _, err := d.nodeman.GetChannelBackup(id)
+		if err != nil {
+			return err
+		}
+
+		if len(saved) == 0 {
+			return errors.Errorf("no channel backup saved for node %s", id)
+		}
+
+		backup = saved
+	}
+
+	d.log.Infof("Restoring channel backup of node %s", id)
+
+	if len(mnemonic) > 0 {
+		return node.InitFromBackup(password, mnemonic, backup)
+	}
+
+	return node.Restore(backup)
+}
+
+func (d *Dispenser) ConnectPeer(id string, pubKey string, host string) error {
+	return d.nodeman.ConnectPeer(id, pubKey, host)
+}
+
+func (d *Dispenser) GetPeers(id string) ([]*lightning.Peer, error) {
+	return d.nodeman.GetPeers(id)
+}
+
+func (d *Dispenser) OpenChannel(id string, req *lightning.OpenChannelRequest) (string, error) {
+	return d.nodeman.OpenChannel(id, req)
+}
+
+func (d *Dispenser) GetChannels(id string) ([]*lightning.Channel, error) {
+	return d.nodeman.GetChannels(id)
+}
+
+func (d *Dispenser) CloseChannel(id string, channelPoint string, force bool) (string, error) {
+	return d.nodeman.CloseChannel(id, channelPoint, force)
+}
+
+func (d *Dispenser) BakeMacaroon(id string, permissions []lightning.MacaroonPermission, expiry time.Duration) ([]byte, error) {
+	return d.nodeman.BakeMacaroon(id, permissions, expiry)
+}
+
+func (d *Dispenser) GetWalletBalance(id string) (*lightning.WalletBalance, error) {
+	return d.nodeman.GetWalletBalance(id)
+}
+
+func (d *Dispenser) NewAddress(id string) (string, error) {
+	return d.nodeman.NewAddress(id)
+}
+
+func (d *Dispenser) SendCoins(id string, req *lightning.SendCoinsRequest) (string, error) {
+	return d.nodeman.SendCoins(id, req)
+}
+
 func (d *Dispenser) RenameNode(id string, name string) error {
 	return d.nodeman.RenameNode(id, name)
 }
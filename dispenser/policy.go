@@ -0,0 +1,100 @@
+package dispenser
+
+import (
+	"github.com/go-errors/errors"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"math"
+	"time"
+)
+
+// defaultDispensePolicy is used as long as no dispense policy was saved
+// and roughly matches a dispense of 1.5 seconds for 8 satoshis
+var defaultDispensePolicy = sweetdb.DispensePolicy{
+	SatsPerSecond: 5,
+	MinDuration:   1000 * time.Millisecond,
+	MaxDuration:   5000 * time.Millisecond,
+	Rounding:      sweetdb.RoundingNearest,
+	RoundingStep:  100 * time.Millisecond,
+}
+
+// validateDispensePolicy makes sure that a policy can be applied
+func validateDispensePolicy(policy *sweetdb.DispensePolicy) error {
+	if policy.SatsPerSecond <= 0 {
+		return errors.Errorf("sats per second must be positive")
+	}
+
+	if policy.MinDuration < 0 || policy.MaxDuration < 0 || policy.RoundingStep < 0 {
+		return errors.Errorf("durations must not be negative")
+	}
+
+	if policy.MaxDuration > 0 && policy.MinDuration > policy.MaxDuration {
+		return errors.Errorf("min duration %v exceeds max duration %v", policy.MinDuration, policy.MaxDuration)
+	}
+
+	switch policy.Rounding {
+	case sweetdb.RoundingNone, sweetdb.RoundingUp, sweetdb.RoundingDown, sweetdb.RoundingNearest:
+	default:
+		return errors.Errorf("unknown rounding %s", policy.Rounding)
+	}
+
+	return nil
+}
+
+// dispenseDuration computes for how long the motor should run for
+// the given amount in millisatoshis
+func dispenseDuration(policy *sweetdb.DispensePolicy, msat int64) time.Duration {
+	if policy.SatsPerSecond <= 0 {
+		return policy.MinDuration
+	}
+
+	seconds := float64(msat) / 1000 / policy.SatsPerSecond
+
+	if policy.RoundingStep > 0 {
+		steps := seconds * float64(time.Second) / float64(policy.RoundingStep)
+
+		switch policy.Rounding {
+		case sweetdb.RoundingUp:
+			steps = math.Ceil(steps)
+		case sweetdb.RoundingDown:
+			steps = math.Floor(steps)
+		case sweetdb.RoundingNearest:
+			steps = math.Round(steps)
+		}
+
+		seconds = steps * float64(policy.RoundingStep) / float64(time.Second)
+	}
+
+	duration := time.Duration(seconds * float64(time.Second))
+
+	if duration < policy.MinDuration {
+		duration = policy.MinDuration
+	}
+
+	if policy.MaxDuration > 0 && duration > policy.MaxDuration {
+		duration = policy.MaxDuration
+	}
+
+	return duration
+}
+
+func (d *Dispenser) GetDispensePolicy() *sweetdb.DispensePolicy {
+	return d.dispensePolicy
+}
+
+func (d *Dispenser) SetDispensePolicy(policy *sweetdb.DispensePolicy) error {
+	d.log.Infof("Setting dispense policy")
+
+	err := validateDispensePolicy(policy)
+	if err != nil {
+		return errors.Errorf("Invalid dispense policy: %v", err)
+	}
+
+	err = d.db.SetDispensePolicy(policy)
+	if err != nil {
+		return errors.Errorf("Failed setting dispense policy: %v", err)
+	}
+
+	d.dispensePolicy = policy
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package dispenser
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"testing"
+	"time"
+)
+
+func TestDispenseDurationProportional(t *testing.T) {
+	t.Parallel()
+
+	policy := &sweetdb.DispensePolicy{
+		SatsPerSecond: 10,
+		Rounding:      sweetdb.RoundingNone,
+	}
+
+	assert.Equal(t, 2500*time.Millisecond, dispenseDuration(policy, 25000))
+}
+
+func TestDispenseDurationRounding(t *testing.T) {
+	t.Parallel()
+
+	policy := &sweetdb.DispensePolicy{
+		SatsPerSecond: 3,
+		Rounding:      sweetdb.RoundingUp,
+		RoundingStep:  500 * time.Millisecond,
+	}
+
+	assert.Equal(t, 3000*time.Millisecond, dispenseDuration(policy, 8000))
+
+	policy.Rounding = sweetdb.RoundingDown
+	assert.Equal(t, 2500*time.Millisecond, dispenseDuration(policy, 8000))
+
+	policy.Rounding = sweetdb.RoundingNearest
+	assert.Equal(t, 2500*time.Millisecond, dispenseDuration(policy, 8000))
+}
+
+func TestDispenseDurationBounds(t *testing.T) {
+	t.Parallel()
+
+	policy := &sweetdb.DispensePolicy{
+		SatsPerSecond: 1,
+		MinDuration:   time.Second,
+		MaxDuration:   5 * time.Second,
+		Rounding:      sweetdb.RoundingNone,
+	}
+
+	assert.Equal(t, time.Second, dispenseDuration(policy, 100))
+	assert.Equal(t, 5*time.Second, dispenseDuration(policy, 1000000))
+}
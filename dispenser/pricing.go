@@ -0,0 +1,91 @@
+package dispenser
+
+import (
+	"github.com/go-errors/errors"
+	"github.com/the-lightning-land/sweetd/pricing"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+)
+
+// defaultPrice is used as long as no price was saved
+var defaultPrice = sweetdb.Price{
+	Currency: string(pricing.CurrencySat),
+	Amount:   8,
+}
+
+// toPricingPrice converts a persisted price into one that can be quoted
+func toPricingPrice(price *sweetdb.Price) (*pricing.Price, error) {
+	currency, err := pricing.ParseCurrency(price.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pricing.Price{
+		Currency: currency,
+		Amount:   price.Amount,
+	}, nil
+}
+
+func (d *Dispenser) GetPrice() *sweetdb.Price {
+	return d.price
+}
+
+func (d *Dispenser) SetPrice(price *sweetdb.Price) error {
+	d.log.Infof("Setting price")
+
+	pricingPrice, err := toPricingPrice(price)
+	if err != nil {
+		return errors.Errorf("Invalid price: %v", err)
+	}
+
+	if pricingPrice.Amount <= 0 {
+		return errors.Errorf("Invalid price: amount must be positive")
+	}
+
+	// normalize a copy, the caller keeps its price
+	price = &sweetdb.Price{
+		Currency: string(pricingPrice.Currency),
+		Amount:   price.Amount,
+	}
+
+	err = d.db.SetPrice(price)
+	if err != nil {
+		return errors.Errorf("Failed setting price: %v", err)
+	}
+
+	d.price = price
+
+	return nil
+}
+
+// GetQuote converts the price of a product into millisatoshis, or the
+// default price if no product id is given
+func (d *Dispenser) GetQuote(productId string) (*pricing.Quote, error) {
+	dispenserPrice := d.price
+
+	if productId != "" {
+		product, err := d.db.GetProduct(productId)
+		if err != nil {
+			return nil, errors.Errorf("unable to get product: %v", err)
+		}
+
+		if product == nil {
+			return nil, errors.Errorf("product with id %s not found", productId)
+		}
+
+		if product.Price != nil {
+			dispenserPrice = product.Price
+		}
+	}
+
+	price, err := toPricingPrice(dispenserPrice)
+	if err != nil {
+		return nil, errors.Errorf("unable to read price: %v", err)
+	}
+
+	quote, err := pricing.NewQuote(price, d.rates)
+	if err != nil {
+		return nil, errors.Errorf("unable to quote price: %v", err)
+	}
+
+	return quote, nil
+}
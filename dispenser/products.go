@@ -0,0 +1,166 @@
+package dispenser
+
+import (
+	"github.com/go-errors/errors"
+	"github.com/google/uuid"
+	"github.com/the-lightning-land/sweetd/lightning"
+	"github.com/the-lightning-land/sweetd/nodeman"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"time"
+)
+
+// validateProduct makes sure that a product can be sold by this machine
+// and returns a copy of it with a normalized price
+func (d *Dispenser) validateProduct(product *sweetdb.Product) (*sweetdb.Product, error) {
+	if product.Name == "" {
+		return nil, errors.Errorf("name must not be empty")
+	}
+
+	if product.Slot < 0 || product.Slot >= d.machine.Slots() {
+		return nil, errors.Errorf("slot %d not available, machine has %d slots", product.Slot, d.machine.Slots())
+	}
+
+	validated := *product
+
+	if product.Price != nil {
+		price, err := toPricingPrice(product.Price)
+		if err != nil {
+			return nil, err
+		}
+
+		if price.Amount <= 0 {
+			return nil, errors.Errorf("price must be positive")
+		}
+
+		validated.Price = &sweetdb.Price{
+			Currency: string(price.Currency),
+			Amount:   price.Amount,
+		}
+	}
+
+	return &validated, nil
+}
+
+func (d *Dispenser) GetProducts() ([]*sweetdb.Product, error) {
+	return d.db.GetProducts()
+}
+
+func (d *Dispenser) GetProduct(id string) (*sweetdb.Product, error) {
+	return d.db.GetProduct(id)
+}
+
+func (d *Dispenser) AddProduct(product *sweetdb.Product) (*sweetdb.Product, error) {
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return nil, errors.Errorf("unable to generate uuid: %v", err)
+	}
+
+	product, err = d.validateProduct(product)
+	if err != nil {
+		return nil, errors.Errorf("Invalid product: %v", err)
+	}
+
+	product.Id = id.String()
+
+	d.log.Infof("Adding product %s", product.Id)
+
+	err = d.db.SaveProduct(product)
+	if err != nil {
+		return nil, errors.Errorf("Failed adding product: %v", err)
+	}
+
+	return product, nil
+}
+
+func (d *Dispenser) UpdateProduct(product *sweetdb.Product) (*sweetdb.Product, error) {
+	existing, err := d.db.GetProduct(product.Id)
+	if err != nil {
+		return nil, errors.Errorf("unable to get product: %v", err)
+	}
+
+	if existing == nil {
+		return nil, errors.Errorf("product with id %s not found", product.Id)
+	}
+
+	product, err = d.validateProduct(product)
+	if err != nil {
+		return nil, errors.Errorf("Invalid product: %v", err)
+	}
+
+	d.log.Infof("Updating product %s", product.Id)
+
+	err = d.db.SaveProduct(product)
+	if err != nil {
+		return nil, errors.Errorf("Failed updating product: %v", err)
+	}
+
+	return product, nil
+}
+
+func (d *Dispenser) RemoveProduct(id string) error {
+	d.log.Infof("Removing product %s", id)
+
+	err := d.db.RemoveProduct(id)
+	if err != nil {
+		return errors.Errorf("Failed removing product: %v", err)
+	}
+
+	return nil
+}
+
+// RegisterInvoice remembers which product an invoice was issued for and
+// by which node, so the matching slot can be dispensed once it is paid
+func (d *Dispenser) RegisterInvoice(invoice *lightning.Invoice, productId string, nodeId string) error {
+	slot := 0
+
+	if productId != "" {
+		product, err := d.db.GetProduct(productId)
+		if err != nil {
+			return errors.Errorf("unable to get product: %v", err)
+		}
+
+		if product == nil {
+			return errors.Errorf("product with id %s not found", productId)
+		}
+
+		slot = product.Slot
+	}
+
+	err := d.db.SaveInvoice(&sweetdb.Invoice{
+		RHash:     invoice.RHash,
+		ProductId: productId,
+		NodeId:    nodeId,
+		Slot:      slot,
+		MSat:      invoice.MSat,
+		Preimage:  invoice.Preimage,
+		Created:   time.Now(),
+	})
+	if err != nil {
+		return errors.Errorf("unable to save invoice: %v", err)
+	}
+
+	return nil
+}
+
+// getInvoice returns what an invoice was issued for, or nil if it wasn't
+// created by the point of sale
+func (d *Dispenser) getInvoice(rHash string) *sweetdb.Invoice {
+	invoice, err := d.db.GetInvoice(rHash)
+	if err != nil {
+		d.log.Errorf("could not get invoice %s: %v", rHash, err)
+		return nil
+	}
+
+	return invoice
+}
+
+// GetInvoiceNode returns the node which issued an invoice of the point of
+// sale or nil if it is unknown
+func (d *Dispenser) GetInvoiceNode(rHash string) nodeman.LightningNode {
+	invoice := d.getInvoice(rHash)
+	if invoice == nil || invoice.NodeId == "" {
+		return nil
+	}
+
+	return d.nodeman.GetNode(invoice.NodeId)
+}
@@ -0,0 +1,111 @@
+package dispenser
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-lightning-land/sweetd/lightning"
+	"github.com/the-lightning-land/sweetd/machine"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// newTestDispenser creates a dispenser backed by a temporary database and
+// a mock machine, the returned function cleans both up
+func newTestDispenser(t *testing.T, m machine.Machine) (*Dispenser, func()) {
+	dir, err := ioutil.TempDir("", "dispenser")
+	assert.Equal(t, nil, err)
+
+	db, err := sweetdb.Open(dir)
+	assert.Equal(t, nil, err)
+
+	d := &Dispenser{
+		machine:             m,
+		db:                  db,
+		log:                 logrus.NewEntry(logrus.New()),
+		dispenseClients:     make(map[uint32]*DispenseClient),
+		dispenseQueueSignal: make(chan struct{}, 1),
+		settleProgress:      make(map[string]*settleProgress),
+	}
+
+	return d, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestProducts(t *testing.T) {
+	t.Parallel()
+
+	d, cleanup := newTestDispenser(t, machine.NewMockMachine(""))
+	defer cleanup()
+
+	price := &sweetdb.Price{Currency: "eur", Amount: 50}
+
+	product, err := d.AddProduct(&sweetdb.Product{Name: "Candy", Slot: 2, Price: price})
+	assert.Equal(t, nil, err)
+	assert.NotEqual(t, "", product.Id)
+	assert.Equal(t, "EUR", product.Price.Currency)
+
+	// the caller's price is left as it was
+	assert.Equal(t, "eur", price.Currency)
+
+	saved, err := d.GetProduct(product.Id)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, product, saved)
+
+	// slots are limited to the ones of the machine
+	saved.Slot = 4
+	_, err = d.UpdateProduct(saved)
+	assert.NotEqual(t, nil, err)
+
+	saved.Slot = 3
+	updated, err := d.UpdateProduct(saved)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, updated.Slot)
+
+	_, err = d.UpdateProduct(&sweetdb.Product{Id: "unknown", Name: "Gum"})
+	assert.NotEqual(t, nil, err)
+
+	_, err = d.AddProduct(&sweetdb.Product{Name: "Gum", Slot: -1})
+	assert.NotEqual(t, nil, err)
+
+	_, err = d.AddProduct(&sweetdb.Product{Name: "Gum", Price: &sweetdb.Price{Currency: "SAT", Amount: 0}})
+	assert.NotEqual(t, nil, err)
+
+	assert.Equal(t, nil, d.RemoveProduct(product.Id))
+
+	products, err := d.GetProducts()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(products))
+}
+
+func TestRegisterInvoice(t *testing.T) {
+	t.Parallel()
+
+	d, cleanup := newTestDispenser(t, machine.NewMockMachine(""))
+	defer cleanup()
+
+	product, err := d.AddProduct(&sweetdb.Product{Name: "Candy", Slot: 2})
+	assert.Equal(t, nil, err)
+
+	err = d.RegisterInvoice(&lightning.Invoice{RHash: "candy", MSat: 8000, Preimage: "preimage"}, product.Id, "node")
+	assert.Equal(t, nil, err)
+
+	invoice := d.getInvoice("candy")
+	assert.Equal(t, product.Id, invoice.ProductId)
+	assert.Equal(t, 2, invoice.Slot)
+	assert.Equal(t, "node", invoice.NodeId)
+	assert.Equal(t, int64(8000), invoice.MSat)
+	assert.Equal(t, "preimage", invoice.Preimage)
+
+	// invoices without a product dispense the first slot
+	err = d.RegisterInvoice(&lightning.Invoice{RHash: "plain", MSat: 8000}, "", "node")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, d.getInvoice("plain").Slot)
+
+	err = d.RegisterInvoice(&lightning.Invoice{RHash: "unknown"}, "unknown", "node")
+	assert.NotEqual(t, nil, err)
+	assert.Nil(t, d.getInvoice("unknown"))
+}
@@ -0,0 +1,394 @@
+package dispenser
+
+import (
+	"github.com/go-errors/errors"
+	"github.com/the-lightning-land/sweetd/lightning"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"sync"
+	"time"
+)
+
+const (
+	// settleAttempts is how often settling a hold invoice is tried before
+	// it is left to be settled once the node reports it again
+	settleAttempts   = 4
+	settleRetryDelay = 2 * time.Second
+	// dispenseQueueRetryDelay is waited before a dispense is retried
+	// whose state could not be saved
+	dispenseQueueRetryDelay = 5 * time.Second
+	// dispensedRetention is how long paid invoices are remembered to only
+	// be dispensed once, far longer than invoices are usually replayed
+	dispensedRetention = 90 * 24 * time.Hour
+	// invoiceRetention is how long issued invoices are kept, long after
+	// they expired unpaid or their payment was dispensed
+	invoiceRetention = 30 * 24 * time.Hour
+)
+
+// holdResolution is the outcome of resolving the hold invoice of a dispense
+type holdResolution int
+
+const (
+	// holdSettled means the payment was received
+	holdSettled holdResolution = iota
+	// holdCanceled means the payment was refunded, or will be once the
+	// held payment times out
+	holdCanceled
+	// holdUnsettled means the payment could not be settled yet and is
+	// still held by the node
+	holdUnsettled
+)
+
+// enqueuePayment persists a dispense for a paid invoice and wakes up
+// the dispense queue worker without blocking the caller
+func (d *Dispenser) enqueuePayment(nodeId string, invoice *lightning.Invoice, record *sweetdb.Invoice) error {
+	msat := invoice.PaidMSat
+	if msat == 0 {
+		msat = invoice.MSat
+	}
+
+	dispense := &sweetdb.Dispense{
+		Trigger: sweetdb.SaleTriggerPayment,
+		RHash:   invoice.RHash,
+		MSat:    msat,
+		NodeId:  nodeId,
+	}
+
+	// invoices not created by the point of sale dispense the first slot
+	if record != nil {
+		dispense.ProductId = record.ProductId
+		dispense.Slot = record.Slot
+	}
+
+	return d.enqueueDispense(dispense)
+}
+
+// enqueueHeldPayment enqueues a dispense for an accepted hold invoice,
+// unless the invoice was already queued before a restart
+func (d *Dispenser) enqueueHeldPayment(nodeId string, invoice *lightning.Invoice, record *sweetdb.Invoice) error {
+	existing, err := d.db.GetDispenseByRHash(invoice.RHash)
+	if err != nil {
+		return errors.Errorf("unable to get dispense: %v", err)
+	}
+
+	if existing == nil {
+		return d.enqueuePayment(nodeId, invoice, record)
+	}
+
+	switch existing.State {
+	case sweetdb.DispenseStateDone:
+		d.resolveHoldInvoice(existing, true)
+	case sweetdb.DispenseStateFailed:
+		d.resolveHoldInvoice(existing, false)
+	}
+
+	return nil
+}
+
+// resolveHoldInvoice settles the hold invoice of a completed dispense and
+// cancels it otherwise, which refunds the payer. Settling is retried, and
+// a payment that still can't be settled stays held. As the dispense is
+// done, it is settled when the node reports the held invoice again.
+func (d *Dispenser) resolveHoldInvoice(dispense *sweetdb.Dispense, completed bool) holdResolution {
+	if dispense.Trigger != sweetdb.SaleTriggerPayment {
+		return holdSettled
+	}
+
+	record := d.getInvoice(dispense.RHash)
+	if record == nil || record.Preimage == "" {
+		// regular invoices are settled before dispensing
+		return holdSettled
+	}
+
+	if completed {
+		return d.settleHoldInvoice(dispense, record.Preimage)
+	}
+
+	node := d.nodeman.GetNode(dispense.NodeId)
+	if node == nil {
+		d.log.Errorf("could not find node %s of hold invoice %s, it is refunded once it times out", dispense.NodeId, dispense.RHash)
+		return holdCanceled
+	}
+
+	err := node.CancelInvoice(dispense.RHash)
+	if err != nil {
+		d.log.Errorf("could not cancel invoice %s, it is refunded once it times out: %v", dispense.RHash, err)
+		return holdCanceled
+	}
+
+	d.log.Infof("canceled invoice %s", dispense.RHash)
+
+	return holdCanceled
+}
+
+func (d *Dispenser) settleHoldInvoice(dispense *sweetdb.Dispense, preimage string) holdResolution {
+	delay := settleRetryDelay
+
+	for attempt := 1; ; attempt++ {
+		var err error
+
+		node := d.nodeman.GetNode(dispense.NodeId)
+		if node == nil {
+			err = errors.Errorf("node %s not found", dispense.NodeId)
+		} else {
+			err = node.SettleInvoice(preimage)
+		}
+
+		if err == nil {
+			d.log.Infof("settled invoice %s", dispense.RHash)
+			return holdSettled
+		}
+
+		if attempt == settleAttempts {
+			d.log.Errorf("could not settle invoice %s, it is still held: %v", dispense.RHash, err)
+			return holdUnsettled
+		}
+
+		d.log.Warnf("could not settle invoice %s, retrying in %v: %v", dispense.RHash, delay, err)
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// cancelPendingHoldDispenses refunds all held payments which weren't
+// dispensed yet, as they can't be dispensed while the machine is stopped
+func (d *Dispenser) cancelPendingHoldDispenses() {
+	pending, err := d.db.GetDispenses(sweetdb.DispenseStatePending)
+	if err != nil {
+		d.log.Errorf("could not get pending dispenses: %v", err)
+		return
+	}
+
+	for _, dispense := range pending {
+		record := d.getInvoice(dispense.RHash)
+		if record == nil || record.Preimage == "" {
+			// already paid dispenses are resumed after a restart
+			continue
+		}
+
+		d.resolveHoldInvoice(dispense, false)
+
+		err := d.db.SetDispenseState(dispense, sweetdb.DispenseStateFailed)
+		if err != nil {
+			d.log.Errorf("could not mark dispense %d as failed: %v", dispense.Id, err)
+		}
+	}
+}
+
+// Dispense enqueues a dispense of the given slot and duration that was
+// triggered by an administrator
+func (d *Dispenser) Dispense(slot int, duration time.Duration) error {
+	if slot < 0 || slot >= d.machine.Slots() {
+		return errors.Errorf("slot %d not available, machine has %d slots", slot, d.machine.Slots())
+	}
+
+	if duration <= 0 {
+		return errors.Errorf("duration must be positive")
+	}
+
+	return d.enqueueDispense(&sweetdb.Dispense{
+		Trigger:  sweetdb.SaleTriggerAdmin,
+		Slot:     slot,
+		Duration: duration,
+	})
+}
+
+func (d *Dispenser) enqueueDispense(dispense *sweetdb.Dispense) error {
+	enqueued, err := d.db.EnqueueDispense(dispense)
+	if err != nil {
+		return errors.Errorf("unable to enqueue dispense: %v", err)
+	}
+
+	if !enqueued {
+		d.log.Infof("invoice %s was dispensed already", dispense.RHash)
+		return nil
+	}
+
+	d.log.Infof("enqueued %s dispense %d", dispense.Trigger, dispense.Id)
+
+	d.signalDispenseQueue()
+
+	return nil
+}
+
+// signalDispenseQueue notifies the dispense queue worker about new entries
+func (d *Dispenser) signalDispenseQueue() {
+	select {
+	case d.dispenseQueueSignal <- struct{}{}:
+	default:
+		// worker is already signalled
+	}
+}
+
+// processDispenseQueue is run as a goroutine and drains the persisted
+// dispense queue in order, one dispense at a time
+func (d *Dispenser) processDispenseQueue(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	// lightning nodes are kept running until held payments are resolved
+	defer close(d.dispenseQueueDone)
+
+	d.log.Infof("started processing dispense queue")
+
+	d.failInterruptedDispenses()
+
+	pruned, err := d.db.PruneDispensed(time.Now().Add(-dispensedRetention))
+	if err != nil {
+		d.log.Errorf("could not prune dispensed invoices: %v", err)
+	} else if pruned > 0 {
+		d.log.Infof("pruned %d dispensed invoices", pruned)
+	}
+
+	pruned, err = d.db.PruneInvoices(time.Now().Add(-invoiceRetention))
+	if err != nil {
+		d.log.Errorf("could not prune invoices: %v", err)
+	} else if pruned > 0 {
+		d.log.Infof("pruned %d invoices", pruned)
+	}
+
+	// cursor is the id of the last processed dispense
+	var cursor uint64
+
+	for {
+		dispense, err := d.db.NextPendingDispense(cursor)
+		if err != nil {
+			d.log.Errorf("could not get next dispense: %v", err)
+		}
+
+		if dispense == nil {
+			// wait for new entries
+			select {
+			case <-d.dispenseQueueSignal:
+				continue
+			case <-d.done:
+				d.cancelPendingHoldDispenses()
+				d.log.Infof("stopped processing dispense queue")
+				return
+			}
+		}
+
+		err = d.db.SetDispenseState(dispense, sweetdb.DispenseStateDispensing)
+		if err != nil {
+			d.log.Errorf("could not mark dispense %d as dispensing, retrying in %v: %v", dispense.Id, dispenseQueueRetryDelay, err)
+
+			select {
+			case <-time.After(dispenseQueueRetryDelay):
+				continue
+			case <-d.done:
+				d.cancelPendingHoldDispenses()
+				d.log.Infof("stopped processing dispense queue")
+				return
+			}
+		}
+
+		cursor = dispense.Id
+
+		runTime, completed := d.dispense(dispense)
+
+		state := sweetdb.DispenseStateDone
+		if !completed {
+			state = sweetdb.DispenseStateFailed
+		}
+
+		err = d.db.SetDispenseState(dispense, state)
+		if err != nil {
+			d.log.Errorf("could not mark dispense %d as %s: %v", dispense.Id, state, err)
+		}
+
+		d.recordDispense(dispense, runTime, completed)
+
+		if !completed {
+			d.cancelPendingHoldDispenses()
+			d.log.Infof("stopped processing dispense queue")
+			return
+		}
+	}
+}
+
+// failInterruptedDispenses records the dispenses which were interrupted by
+// a crash as failed sales, so they show up in the sales ledger. They can't
+// be resumed reliably, as it's unknown how much was dispensed already,
+// and retrying them could dispense forever if they caused the crash.
+func (d *Dispenser) failInterruptedDispenses() {
+	interrupted, err := d.db.GetDispenses(sweetdb.DispenseStateDispensing)
+	if err != nil {
+		d.log.Errorf("could not get interrupted dispenses: %v", err)
+		return
+	}
+
+	for _, dispense := range interrupted {
+		d.log.Warnf("dispense %d was interrupted", dispense.Id)
+
+		err := d.db.SetDispenseState(dispense, sweetdb.DispenseStateFailed)
+		if err != nil {
+			d.log.Errorf("could not mark dispense %d as failed: %v", dispense.Id, err)
+		}
+
+		d.recordDispense(dispense, 0, false)
+	}
+}
+
+// recordDispense resolves the hold invoice of a dispense and records it as
+// a sale, which is marked as failed unless the dispense completed
+func (d *Dispenser) recordDispense(dispense *sweetdb.Dispense, runTime time.Duration, completed bool) {
+	sale := &sweetdb.Sale{
+		Time:      time.Now(),
+		Trigger:   dispense.Trigger,
+		RHash:     dispense.RHash,
+		MSat:      dispense.MSat,
+		NodeId:    dispense.NodeId,
+		ProductId: dispense.ProductId,
+		Slot:      dispense.Slot,
+		RunTime:   runTime,
+		Failed:    !completed,
+	}
+
+	if d.resolveHoldInvoice(dispense, completed) == holdCanceled {
+		// the payment was refunded
+		sale.MSat = 0
+	}
+
+	d.recordSale(sale)
+}
+
+// dispense runs the motor of the dispense's slot for as long as the
+// dispense policy determines. It returns the time the motor ran and
+// false if the dispense was interrupted.
+func (d *Dispenser) dispense(dispense *sweetdb.Dispense) (time.Duration, bool) {
+	duration := dispense.Duration
+	if duration == 0 {
+		duration = dispenseDuration(d.dispensePolicy, dispense.MSat)
+	}
+
+	// take over the motors from touches until the dispense is done
+	d.motorMu.Lock()
+	if !d.touchStarted.IsZero() {
+		d.ToggleDispense(false)
+		d.endTouchDispense()
+	}
+	d.queueDispensing = true
+	d.motorMu.Unlock()
+
+	defer func() {
+		d.motorMu.Lock()
+		d.queueDispensing = false
+		d.motorMu.Unlock()
+	}()
+
+	d.log.Infof("dispensing %d from slot %d for a duration of %v", dispense.Id, dispense.Slot, duration)
+
+	started := time.Now()
+
+	d.toggleSlotDispense(dispense.Slot, true)
+
+	select {
+	case <-time.After(duration):
+		d.toggleSlotDispense(dispense.Slot, false)
+		return duration, true
+	case <-d.done:
+		// subscribers aren't notified anymore while stopping
+		d.machine.ToggleMotor(dispense.Slot, false)
+		d.machine.ToggleBuzzer(false)
+		return time.Since(started), false
+	}
+}
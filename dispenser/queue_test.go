@@ -0,0 +1,139 @@
+package dispenser
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/the-lightning-land/sweetd/machine"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"sync"
+	"testing"
+	"time"
+)
+
+// motorMachine is a mock machine which remembers the state of its motors
+type motorMachine struct {
+	*machine.MockMachine
+	mu     sync.Mutex
+	motors map[int]bool
+}
+
+func (m *motorMachine) ToggleMotor(slot int, on bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.motors[slot] = on
+}
+
+func (m *motorMachine) motor(slot int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.motors[slot]
+}
+
+func TestTouchDuringQueuedDispense(t *testing.T) {
+	t.Parallel()
+
+	m := &motorMachine{MockMachine: machine.NewMockMachine(""), motors: make(map[int]bool)}
+
+	d, cleanup := newTestDispenser(t, m)
+	defer cleanup()
+
+	d.done = make(chan struct{})
+	d.dispenses = make(chan DispenseState, 10)
+	d.dispenseOnTouch = true
+
+	d.handleTouch(true)
+	assert.True(t, m.motor(0))
+
+	dispensed := make(chan bool)
+
+	go func() {
+		_, completed := d.dispense(&sweetdb.Dispense{Slot: 0, Duration: 200 * time.Millisecond})
+		dispensed <- completed
+	}()
+
+	// wait for the queued dispense to take over the motor
+	for deadline := time.Now().Add(time.Second); ; {
+		d.motorMu.Lock()
+		queueDispensing := d.queueDispensing
+		d.motorMu.Unlock()
+
+		if queueDispensing && m.motor(0) {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("queued dispense didn't start")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	// releasing and touching again must not stop the paid dispense
+	d.handleTouch(false)
+	assert.True(t, m.motor(0))
+	d.handleTouch(true)
+	d.handleTouch(false)
+	assert.True(t, m.motor(0))
+
+	assert.True(t, <-dispensed)
+	assert.False(t, m.motor(0))
+
+	// the touch dispense ended once the queued dispense took over
+	sales, total, err := d.db.GetSales(&sweetdb.SalesQuery{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, sweetdb.SaleTriggerTouch, sales[0].Trigger)
+
+	// touches work again after the queued dispense
+	d.handleTouch(true)
+	assert.True(t, m.motor(0))
+	d.handleTouch(false)
+	assert.False(t, m.motor(0))
+}
+
+func TestToggleDispenseAfterStop(t *testing.T) {
+	t.Parallel()
+
+	m := &motorMachine{MockMachine: machine.NewMockMachine(""), motors: make(map[int]bool)}
+
+	d, cleanup := newTestDispenser(t, m)
+	defer cleanup()
+
+	// nobody receives dispense states anymore once the dispenser stopped
+	d.done = make(chan struct{})
+	d.dispenses = make(chan DispenseState)
+	close(d.done)
+
+	d.toggleSlotDispense(1, true)
+	assert.True(t, m.motor(1))
+	d.toggleSlotDispense(1, false)
+	assert.False(t, m.motor(1))
+}
+
+func TestInterruptedDispenseIsFailedSale(t *testing.T) {
+	t.Parallel()
+
+	d, cleanup := newTestDispenser(t, machine.NewMockMachine(""))
+	defer cleanup()
+
+	dispense := &sweetdb.Dispense{Trigger: sweetdb.SaleTriggerPayment, RHash: "paid", MSat: 8000, Slot: 1}
+
+	_, err := d.db.EnqueueDispense(dispense)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, d.db.SetDispenseState(dispense, sweetdb.DispenseStateDispensing))
+
+	d.failInterruptedDispenses()
+
+	failed, err := d.db.GetDispenses(sweetdb.DispenseStateFailed)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(failed))
+
+	sales, total, err := d.db.GetSales(&sweetdb.SalesQuery{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, total)
+	assert.True(t, sales[0].Failed)
+	assert.Equal(t, "paid", sales[0].RHash)
+	assert.Equal(t, int64(8000), sales[0].MSat)
+	assert.Equal(t, 1, sales[0].Slot)
+}
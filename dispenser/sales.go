@@ -0,0 +1,26 @@
+package dispenser
+
+import (
+	"github.com/go-errors/errors"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+)
+
+// recordSale writes a dispense to the sales ledger and accounts its run
+// time against the inventory of the dispensed slot
+func (d *Dispenser) recordSale(sale *sweetdb.Sale) {
+	d.consumeInventory(sale.Slot, sale.RunTime)
+
+	err := d.db.AddSale(sale)
+	if err != nil {
+		d.log.Errorf("could not record %s sale: %v", sale.Trigger, err)
+	}
+}
+
+func (d *Dispenser) GetSales(query *sweetdb.SalesQuery) ([]*sweetdb.Sale, int, error) {
+	sales, total, err := d.db.GetSales(query)
+	if err != nil {
+		return nil, 0, errors.Errorf("unable to get sales: %v", err)
+	}
+
+	return sales, total, nil
+}
@@ -0,0 +1,94 @@
+package dispenser
+
+import (
+	"github.com/the-lightning-land/sweetd/lightning"
+)
+
+// settleProgress tracks up to which settle index all settled invoices of a
+// node were processed. Settle indexes have no gaps, but invoices can be
+// processed out of order or fail to be enqueued, and the index may only
+// advance past an invoice once it was processed.
+type settleProgress struct {
+	// index up to which all invoices were processed
+	index uint64
+	// seeded is false until the index is known. Nodes don't replay any
+	// invoices without a saved index, so the first processed invoice is
+	// the first one received.
+	seeded bool
+	// processed are the indexes above index which were processed
+	processed map[uint64]struct{}
+}
+
+// newSettleProgress starts tracking from a saved index, or from the first
+// processed invoice if no index was saved yet
+func newSettleProgress(index uint64) *settleProgress {
+	return &settleProgress{
+		index:     index,
+		seeded:    index > 0,
+		processed: make(map[uint64]struct{}),
+	}
+}
+
+// complete marks an invoice as processed and returns the index up to which
+// all invoices were processed now and whether it advanced
+func (p *settleProgress) complete(index uint64) (uint64, bool) {
+	if !p.seeded {
+		p.index = index - 1
+		p.seeded = true
+	}
+
+	if index <= p.index {
+		return p.index, false
+	}
+
+	p.processed[index] = struct{}{}
+
+	advanced := false
+
+	for {
+		if _, ok := p.processed[p.index+1]; !ok {
+			break
+		}
+
+		delete(p.processed, p.index+1)
+		p.index++
+		advanced = true
+	}
+
+	return p.index, advanced
+}
+
+// saveSettleIndex remembers up to which settled invoice the payments of
+// a node were processed, so they are replayed after a restart if they
+// were missed. An invoice whose payment couldn't be processed holds the
+// index back, even if later invoices were processed.
+func (d *Dispenser) saveSettleIndex(nodeId string, invoice *lightning.Invoice) {
+	if invoice.SettleIndex == 0 {
+		return
+	}
+
+	d.settleProgressMu.Lock()
+	defer d.settleProgressMu.Unlock()
+
+	progress, ok := d.settleProgress[nodeId]
+	if !ok {
+		index, err := d.db.GetSettleIndex(nodeId)
+		if err != nil {
+			d.log.Errorf("could not get settle index of node %s: %v", nodeId, err)
+			return
+		}
+
+		progress = newSettleProgress(index)
+		d.settleProgress[nodeId] = progress
+	}
+
+	index, advanced := progress.complete(invoice.SettleIndex)
+	if !advanced {
+		return
+	}
+
+	err := d.db.SetSettleIndex(nodeId, index)
+	if err != nil {
+		d.log.Errorf("could not save settle index of node %s: %v", nodeId, err)
+	}
+}
@@ -0,0 +1,70 @@
+package dispenser
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/the-lightning-land/sweetd/lightning"
+	"github.com/the-lightning-land/sweetd/machine"
+	"testing"
+)
+
+func TestSettleProgress(t *testing.T) {
+	t.Parallel()
+
+	progress := newSettleProgress(3)
+
+	index, advanced := progress.complete(2)
+	assert.Equal(t, uint64(3), index)
+	assert.False(t, advanced)
+
+	// a later invoice doesn't advance past an unprocessed one
+	index, advanced = progress.complete(5)
+	assert.Equal(t, uint64(3), index)
+	assert.False(t, advanced)
+
+	index, advanced = progress.complete(4)
+	assert.Equal(t, uint64(5), index)
+	assert.True(t, advanced)
+
+	index, advanced = progress.complete(6)
+	assert.Equal(t, uint64(6), index)
+	assert.True(t, advanced)
+}
+
+func TestSettleProgressWithoutSavedIndex(t *testing.T) {
+	t.Parallel()
+
+	// the node has earlier invoices which it doesn't replay
+	progress := newSettleProgress(0)
+
+	index, advanced := progress.complete(7)
+	assert.Equal(t, uint64(7), index)
+	assert.True(t, advanced)
+
+	index, advanced = progress.complete(9)
+	assert.Equal(t, uint64(7), index)
+	assert.False(t, advanced)
+
+	index, advanced = progress.complete(8)
+	assert.Equal(t, uint64(9), index)
+	assert.True(t, advanced)
+	assert.Equal(t, 0, len(progress.processed))
+}
+
+func TestSaveSettleIndexWithoutSavedIndex(t *testing.T) {
+	t.Parallel()
+
+	d, cleanup := newTestDispenser(t, machine.NewMockMachine(""))
+	defer cleanup()
+
+	d.saveSettleIndex("node", &lightning.Invoice{SettleIndex: 12})
+
+	index, err := d.db.GetSettleIndex("node")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, uint64(12), index)
+
+	d.saveSettleIndex("node", &lightning.Invoice{SettleIndex: 13})
+
+	index, err = d.db.GetSettleIndex("node")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, uint64(13), index)
+}
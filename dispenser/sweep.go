@@ -0,0 +1,249 @@
+package dispenser
+
+import (
+	"github.com/go-errors/errors"
+	"github.com/the-lightning-land/sweetd/lightning"
+	"github.com/the-lightning-land/sweetd/lnurl"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"time"
+)
+
+const (
+	defaultSweepInterval = 24 * time.Hour
+	minSweepInterval     = time.Minute
+	// sweepCheckInterval is how often it is checked whether a sweep is due
+	sweepCheckInterval = time.Minute
+	// minSweepAmount in satoshis, smaller amounts are left on the node
+	minSweepAmount = 1000
+	// sweepTxSize is the estimated size of a sweep transaction in virtual
+	// bytes, which is used to keep the fee aside from the threshold
+	sweepTxSize = 250
+	// defaultSweepSatPerByte is assumed when the node estimates the fee
+	defaultSweepSatPerByte = 20
+)
+
+// validateSweepConfig makes sure that a sweep config can be applied
+func validateSweepConfig(config *sweetdb.SweepConfig) error {
+	if config.Threshold < 0 || config.SatPerByte < 0 || config.MaxFee < 0 {
+		return errors.Errorf("threshold and fees must not be negative")
+	}
+
+	if config.Interval != 0 && config.Interval < minSweepInterval {
+		return errors.Errorf("interval must be at least %v", minSweepInterval)
+	}
+
+	if config.LightningAddress != "" {
+		if _, err := lnurl.AddressUrl(config.LightningAddress); err != nil {
+			return err
+		}
+	}
+
+	if !config.Enabled {
+		return nil
+	}
+
+	if config.NodeId == "" {
+		return errors.Errorf("node must be set")
+	}
+
+	if (config.Address == "") == (config.LightningAddress == "") {
+		return errors.Errorf("either an address or a lightning address must be set")
+	}
+
+	if config.LightningAddress != "" && config.MaxFee == 0 {
+		return errors.Errorf("max fee must be set for sweeping to a lightning address")
+	}
+
+	return nil
+}
+
+// sweepAmount computes how many satoshis of a balance are swept, which is
+// zero if too little exceeds the threshold. Without a threshold all of the
+// on-chain balance is sent and the node takes the fee out of it, otherwise
+// an estimated fee is kept aside in addition to the threshold.
+func sweepAmount(config *sweetdb.SweepConfig, balance *lightning.WalletBalance) (amount int64, all bool) {
+	if config.LightningAddress != "" {
+		// routing fees are paid from the channel balance as well
+		amount = balance.Channels - config.Threshold - config.MaxFee
+	} else {
+		satPerByte := config.SatPerByte
+		if satPerByte == 0 {
+			satPerByte = defaultSweepSatPerByte
+		}
+
+		fee := satPerByte * sweepTxSize
+
+		if config.Threshold == 0 {
+			if balance.Confirmed-fee < minSweepAmount {
+				return 0, false
+			}
+
+			return balance.Confirmed, true
+		}
+
+		amount = balance.Confirmed - config.Threshold - fee
+	}
+
+	if amount < minSweepAmount {
+		return 0, false
+	}
+
+	return amount, false
+}
+
+// runSweeps is run as a goroutine and sweeps earnings whenever the
+// configured interval passed
+func (d *Dispenser) runSweeps() {
+	ticker := time.NewTicker(sweepCheckInterval)
+	defer ticker.Stop()
+
+	d.log.Infof("started running sweeps")
+
+	var lastSweep time.Time
+
+	sweeps, err := d.db.GetSweeps(1)
+	if err != nil {
+		d.log.Errorf("could not get last sweep: %v", err)
+	}
+
+	if len(sweeps) > 0 {
+		lastSweep = sweeps[0].Time
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			config := d.GetSweepConfig()
+			if config == nil || !config.Enabled {
+				continue
+			}
+
+			interval := config.Interval
+			if interval == 0 {
+				interval = defaultSweepInterval
+			}
+
+			if time.Since(lastSweep) < interval {
+				continue
+			}
+
+			lastSweep = time.Now()
+
+			_, err := d.Sweep()
+			if err != nil {
+				d.log.Errorf("could not sweep: %v", err)
+			}
+		case <-d.done:
+			d.log.Infof("stopped running sweeps")
+			return
+		}
+	}
+}
+
+// Sweep moves the earnings above the threshold to the configured
+// destination right away. It returns the logged sweep or nil if there
+// was nothing to sweep.
+func (d *Dispenser) Sweep() (*sweetdb.Sweep, error) {
+	d.sweepMu.Lock()
+	defer d.sweepMu.Unlock()
+
+	config := d.GetSweepConfig()
+	if config == nil || config.NodeId == "" {
+		return nil, errors.Errorf("no sweep configured")
+	}
+
+	err := validateSweepConfig(config)
+	if err != nil {
+		return nil, errors.Errorf("invalid sweep config: %v", err)
+	}
+
+	balance, err := d.nodeman.GetWalletBalance(config.NodeId)
+	if err != nil {
+		return nil, errors.Errorf("could not get balance: %v", err)
+	}
+
+	amount, all := sweepAmount(config, balance)
+	if amount == 0 {
+		d.log.Infof("nothing to sweep from node %s", config.NodeId)
+		return nil, nil
+	}
+
+	sweep := &sweetdb.Sweep{
+		Time:             time.Now(),
+		NodeId:           config.NodeId,
+		Amount:           amount,
+		Address:          config.Address,
+		LightningAddress: config.LightningAddress,
+	}
+
+	if config.LightningAddress != "" {
+		sweep.PaymentHash, err = d.sweepToLightningAddress(config, amount)
+	} else {
+		sweep.Txid, err = d.nodeman.SendCoins(config.NodeId, &lightning.SendCoinsRequest{
+			Address:    config.Address,
+			Amount:     amount,
+			SatPerByte: config.SatPerByte,
+			SendAll:    all,
+		})
+	}
+
+	if err != nil {
+		d.log.Errorf("could not sweep %d sat from node %s: %v", amount, config.NodeId, err)
+		sweep.Error = err.Error()
+	} else {
+		d.log.Infof("swept %d sat from node %s", amount, config.NodeId)
+	}
+
+	err = d.db.AddSweep(sweep)
+	if err != nil {
+		return nil, errors.Errorf("could not save sweep: %v", err)
+	}
+
+	return sweep, nil
+}
+
+func (d *Dispenser) sweepToLightningAddress(config *sweetdb.SweepConfig, amount int64) (string, error) {
+	msat := amount * 1000
+
+	paymentRequest, err := d.lnurl.FetchInvoice(config.LightningAddress, msat)
+	if err != nil {
+		return "", err
+	}
+
+	return d.nodeman.PayInvoice(config.NodeId, paymentRequest, msat, config.MaxFee)
+}
+
+func (d *Dispenser) GetSweepConfig() *sweetdb.SweepConfig {
+	d.sweepConfigMu.Lock()
+	defer d.sweepConfigMu.Unlock()
+
+	return d.sweepConfig
+}
+
+func (d *Dispenser) SetSweepConfig(config *sweetdb.SweepConfig) error {
+	d.log.Infof("Setting sweep config")
+
+	err := validateSweepConfig(config)
+	if err != nil {
+		return errors.Errorf("Invalid sweep config: %v", err)
+	}
+
+	if config.Enabled && d.nodeman.GetNode(config.NodeId) == nil {
+		return errors.Errorf("Invalid sweep config: node with id %s not found", config.NodeId)
+	}
+
+	err = d.db.SetSweepConfig(config)
+	if err != nil {
+		return errors.Errorf("Failed setting sweep config: %v", err)
+	}
+
+	d.sweepConfigMu.Lock()
+	d.sweepConfig = config
+	d.sweepConfigMu.Unlock()
+
+	return nil
+}
+
+func (d *Dispenser) GetSweeps(limit int) ([]*sweetdb.Sweep, error) {
+	return d.db.GetSweeps(limit)
+}
@@ -0,0 +1,61 @@
+package dispenser
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/the-lightning-land/sweetd/lightning"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"testing"
+)
+
+func TestSweepAmount(t *testing.T) {
+	t.Parallel()
+
+	balance := &lightning.WalletBalance{
+		Confirmed:   50000,
+		Unconfirmed: 10000,
+		Channels:    80000,
+	}
+
+	// the estimated fee is kept aside from the threshold
+	onChain := &sweetdb.SweepConfig{Address: "bc1q", Threshold: 20000, SatPerByte: 4}
+	amount, all := sweepAmount(onChain, balance)
+	assert.Equal(t, int64(29000), amount)
+	assert.False(t, all)
+
+	// without a threshold everything is sent and the fee taken out of it
+	onChain.Threshold = 0
+	amount, all = sweepAmount(onChain, balance)
+	assert.Equal(t, int64(50000), amount)
+	assert.True(t, all)
+
+	lightningAddress := &sweetdb.SweepConfig{LightningAddress: "sweets@example.com", Threshold: 20000, MaxFee: 100}
+	amount, all = sweepAmount(lightningAddress, balance)
+	assert.Equal(t, int64(59900), amount)
+	assert.False(t, all)
+
+	// too little above the threshold is left on the node
+	onChain.Threshold = 48500
+	amount, _ = sweepAmount(onChain, balance)
+	assert.Equal(t, int64(0), amount)
+}
+
+func TestValidateSweepConfig(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, nil, validateSweepConfig(&sweetdb.SweepConfig{}))
+
+	config := &sweetdb.SweepConfig{Enabled: true, NodeId: "node"}
+	assert.NotEqual(t, nil, validateSweepConfig(config))
+
+	config.Address = "bc1q"
+	assert.Equal(t, nil, validateSweepConfig(config))
+
+	config.LightningAddress = "sweets@example.com"
+	assert.NotEqual(t, nil, validateSweepConfig(config))
+
+	config.Address = ""
+	assert.NotEqual(t, nil, validateSweepConfig(config))
+
+	config.MaxFee = 100
+	assert.Equal(t, nil, validateSweepConfig(config))
+}
@@ -19,6 +19,7 @@ require (
 	go.etcd.io/bbolt v1.3.3
 	golang.org/x/sys v0.0.0-20191228213918-04cbcbbfeed8
 	google.golang.org/grpc v1.26.0
+	gopkg.in/macaroon.v2 v2.1.0
 	periph.io/x/periph v3.4.0+incompatible
 )
 
@@ -55,7 +56,6 @@ require (
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 	gopkg.in/errgo.v1 v1.0.1 // indirect
 	gopkg.in/macaroon-bakery.v2 v2.1.0 // indirect
-	gopkg.in/macaroon.v2 v2.1.0 // indirect
 	gopkg.in/yaml.v2 v2.2.7 // indirect
 )
 
@@ -0,0 +1,86 @@
+package lightning
+
+import (
+	"encoding/hex"
+	"github.com/go-errors/errors"
+	"strconv"
+	"strings"
+)
+
+// Peer is a node which is currently connected
+type Peer struct {
+	PubKey  string
+	Address string
+	// Inbound is set if the peer connected to us
+	Inbound bool
+	// PingTime is the latency in microseconds
+	PingTime int64
+}
+
+// Channel is an open or pending channel, all amounts are in satoshis
+type Channel struct {
+	// ChannelPoint is the funding outpoint, formatted as txid:index
+	ChannelPoint  string
+	RemotePubKey  string
+	Capacity      int64
+	LocalBalance  int64
+	RemoteBalance int64
+	Active        bool
+	Private       bool
+	// Pending is set while the funding transaction isn't confirmed
+	Pending bool
+}
+
+// OpenChannelRequest opens a channel funded from the on-chain wallet
+type OpenChannelRequest struct {
+	PubKey string
+	// Amount to fund the channel with in satoshis
+	Amount int64
+	// SatPerByte of the funding transaction, the node estimates the fee
+	// if zero
+	SatPerByte int64
+	Private    bool
+}
+
+// ChannelNode is implemented by nodes which manage their own channels
+type ChannelNode interface {
+	// ConnectPeer connects to a node at host, like 1.2.3.4:9735
+	ConnectPeer(pubKey string, host string) error
+	ListPeers() ([]*Peer, error)
+	// OpenChannel returns the channel point of the funding transaction
+	OpenChannel(req *OpenChannelRequest) (string, error)
+	ListChannels() ([]*Channel, error)
+	// CloseChannel returns the id of the closing transaction
+	CloseChannel(channelPoint string, force bool) (string, error)
+}
+
+// parseChannelPoint splits a channel point like txid:index
+func parseChannelPoint(channelPoint string) (string, uint32, error) {
+	parts := strings.Split(channelPoint, ":")
+	if len(parts) != 2 {
+		return "", 0, errors.Errorf("channel point %s is not formatted as txid:index", channelPoint)
+	}
+
+	txid, err := hex.DecodeString(parts[0])
+	if err != nil || len(txid) != 32 {
+		return "", 0, errors.Errorf("invalid funding txid %s", parts[0])
+	}
+
+	index, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return "", 0, errors.Errorf("invalid output index %s", parts[1])
+	}
+
+	return parts[0], uint32(index), nil
+}
+
+// txidString formats transaction ids, which are sent as bytes in reverse
+// order, like block explorers show them
+func txidString(txid []byte) string {
+	reversed := make([]byte, len(txid))
+	for i, b := range txid {
+		reversed[len(txid)-1-i] = b
+	}
+
+	return hex.EncodeToString(reversed)
+}
@@ -0,0 +1,32 @@
+package lightning
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseChannelPoint(t *testing.T) {
+	t.Parallel()
+
+	txid := "a3f1b2c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f80"
+
+	parsedTxid, index, err := parseChannelPoint(txid + ":1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, txid, parsedTxid)
+	assert.Equal(t, uint32(1), index)
+
+	_, _, err = parseChannelPoint(txid)
+	assert.NotEqual(t, nil, err)
+
+	_, _, err = parseChannelPoint("abcd:1")
+	assert.NotEqual(t, nil, err)
+
+	_, _, err = parseChannelPoint(txid + ":-1")
+	assert.NotEqual(t, nil, err)
+}
+
+func TestTxidString(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "030201", txidString([]byte{1, 2, 3}))
+}
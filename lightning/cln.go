@@ -0,0 +1,596 @@
+package lightning
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/go-errors/errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	clnDialTimeout = 10 * time.Second
+	clnCallTimeout = 30 * time.Second
+)
+
+type ClnNodeConfig struct {
+	// Uri of the JSON-RPC socket, either a unix socket path like
+	// unix:///root/.lightning/bitcoin/lightning-rpc or tcp://host:port
+	Uri string
+	// SettleIndex is the pay index of the last processed invoice,
+	// invoices paid later are replayed on start
+	SettleIndex uint64
+	// OnSettleIndex is called with the latest pay index of the node when
+	// it starts without a settle index, so it can be saved
+	OnSettleIndex func(index uint64)
+	// Network the node is expected to operate on, defaults to mainnet
+	Network Network
+	Logger  Logger
+}
+
+// ClnNode is a Core Lightning node which is controlled through its
+// JSON-RPC interface
+type ClnNode struct {
+	network            string
+	address            string
+	logger             Logger
+	nextId             uint64
+	nextIdMu           sync.Mutex
+	waitConn           net.Conn
+	waitConnMu         sync.Mutex
+	done               chan struct{}
+	invoicesClients    map[uint32]*InvoicesClient
+	nextInvoicesClient nextClient
+	statusClients      map[uint32]*StatusClient
+	nextStatusClient   nextClient
+	clientsMu          sync.Mutex
+	status             Status
+	settleIndex        uint64
+	onSettleIndex      func(index uint64)
+	bitcoinNetwork     Network
+}
+
+// Compile time check for protocol compatibility
+var _ Node = (*ClnNode)(nil)
+var _ TestableNode = (*ClnNode)(nil)
+
+type clnRequest struct {
+	JsonRpc string      `json:"jsonrpc"`
+	Id      uint64      `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type clnError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type clnResponse struct {
+	Id     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *clnError       `json:"error"`
+}
+
+// clnMSat parses amounts which CLN reports either as plain numbers or,
+// in older versions, as strings with an msat suffix
+type clnMSat int64
+
+func (m *clnMSat) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), "\"")
+	value = strings.TrimSuffix(value, "msat")
+
+	if value == "" || value == "null" {
+		*m = 0
+		return nil
+	}
+
+	msat, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return errors.Errorf("invalid msat amount %s", data)
+	}
+
+	*m = clnMSat(msat)
+
+	return nil
+}
+
+type clnInvoice struct {
+	Label              string  `json:"label"`
+	PaymentHash        string  `json:"payment_hash"`
+	Bolt11             string  `json:"bolt11"`
+	Status             string  `json:"status"`
+	Description        string  `json:"description"`
+	MSatoshi           clnMSat `json:"msatoshi"`
+	AmountMSat         clnMSat `json:"amount_msat"`
+	MSatoshiReceived   clnMSat `json:"msatoshi_received"`
+	AmountReceivedMSat clnMSat `json:"amount_received_msat"`
+	PayIndex           uint64  `json:"pay_index"`
+}
+
+type clnListInvoicesResponse struct {
+	Invoices []*clnInvoice `json:"invoices"`
+}
+
+type clnGetInfoResponse struct {
+	Id      string `json:"id"`
+	Alias   string `json:"alias"`
+	Network string `json:"network"`
+}
+
+type clnAddInvoiceResponse struct {
+	PaymentHash string `json:"payment_hash"`
+	Bolt11      string `json:"bolt11"`
+}
+
+func (i *clnInvoice) toInvoice() *Invoice {
+	msat := int64(i.AmountMSat)
+	if msat == 0 {
+		msat = int64(i.MSatoshi)
+	}
+
+	paidMSat := int64(i.AmountReceivedMSat)
+	if paidMSat == 0 {
+		paidMSat = int64(i.MSatoshiReceived)
+	}
+
+	return &Invoice{
+		RHash:          i.PaymentHash,
+		PaymentRequest: i.Bolt11,
+		Settled:        i.Status == "paid",
+		Canceled:       i.Status == "expired",
+		MSat:           msat,
+		PaidMSat:       paidMSat,
+		Memo:           i.Description,
+		SettleIndex:    i.PayIndex,
+	}
+}
+
+// parseClnUri splits a socket uri into the network and address to dial,
+// plain paths are treated as unix sockets
+func parseClnUri(uri string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(uri, "unix://"):
+		return "unix", strings.TrimPrefix(uri, "unix://"), nil
+	case strings.HasPrefix(uri, "tcp://"):
+		return "tcp", strings.TrimPrefix(uri, "tcp://"), nil
+	case strings.HasPrefix(uri, "/"):
+		return "unix", uri, nil
+	default:
+		return "", "", errors.Errorf("unsupported uri %s, expected unix:// or tcp://", uri)
+	}
+}
+
+func NewClnNode(config *ClnNodeConfig) (*ClnNode, error) {
+	network, address, err := parseClnUri(config.Uri)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &ClnNode{
+		network:         network,
+		address:         address,
+		logger:          config.Logger,
+		invoicesClients: make(map[uint32]*InvoicesClient),
+		statusClients:   make(map[uint32]*StatusClient),
+		status:          StatusStopped,
+		settleIndex:     config.SettleIndex,
+		onSettleIndex:   config.OnSettleIndex,
+		bitcoinNetwork:  config.Network,
+	}
+
+	if node.bitcoinNetwork == "" {
+		node.bitcoinNetwork = NetworkMainnet
+	}
+
+	if node.logger == nil {
+		node.logger = noopLogger{}
+	}
+
+	return node, nil
+}
+
+func (c *ClnNode) dial() (net.Conn, error) {
+	return net.DialTimeout(c.network, c.address, clnDialTimeout)
+}
+
+// callOn sends a single request over the given connection and decodes
+// the result of the response
+func (c *ClnNode) callOn(conn net.Conn, method string, params interface{}, result interface{}) error {
+	c.nextIdMu.Lock()
+	c.nextId++
+	id := c.nextId
+	c.nextIdMu.Unlock()
+
+	err := json.NewEncoder(conn).Encode(&clnRequest{
+		JsonRpc: "2.0",
+		Id:      id,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return errors.Errorf("unable to send %s request: %v", method, err)
+	}
+
+	res := clnResponse{}
+
+	err = json.NewDecoder(conn).Decode(&res)
+	if err != nil {
+		return errors.Errorf("unable to read %s response: %v", method, err)
+	}
+
+	if res.Error != nil {
+		return errors.Errorf("%s failed: %s (%d)", method, res.Error.Message, res.Error.Code)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	err = json.Unmarshal(res.Result, result)
+	if err != nil {
+		return errors.Errorf("unable to decode %s result: %v", method, err)
+	}
+
+	return nil
+}
+
+func (c *ClnNode) call(method string, params interface{}, result interface{}) error {
+	conn, err := c.dial()
+	if err != nil {
+		return errors.Errorf("Could not connect to lightning node: %v", err)
+	}
+
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(clnCallTimeout))
+	if err != nil {
+		return err
+	}
+
+	return c.callOn(conn, method, params, result)
+}
+
+func (c *ClnNode) Start() error {
+	c.logger.Infof("starting %s://%s", c.network, c.address)
+
+	info := clnGetInfoResponse{}
+
+	err := c.call("getinfo", map[string]interface{}{}, &info)
+	if err != nil {
+		c.updateStatus(StatusFailed)
+		return errors.Errorf("Could not get info: %v", err)
+	}
+
+	if info.Network != clnNetwork(c.bitcoinNetwork) {
+		c.updateStatus(StatusFailed)
+		return errors.Errorf("Node is on %s instead of %s", info.Network, clnNetwork(c.bitcoinNetwork))
+	}
+
+	if c.settleIndex == 0 {
+		// waiting from pay index 0 returns every invoice ever paid, so
+		// only invoices paid from now on are waited for
+		index, err := c.latestPayIndex()
+		if err != nil {
+			c.updateStatus(StatusFailed)
+			return errors.Errorf("Could not get latest pay index: %v", err)
+		}
+
+		c.settleIndex = index
+
+		if c.onSettleIndex != nil {
+			c.onSettleIndex(index)
+		}
+	}
+
+	c.done = make(chan struct{})
+
+	c.updateStatus(StatusStarted)
+
+	go c.run(c.done)
+
+	return nil
+}
+
+// Test calls getinfo on the node without starting it. The JSON-RPC
+// interface grants full access, so there are no permissions to check.
+func (c *ClnNode) Test() *Diagnosis {
+	diagnosis := &Diagnosis{}
+
+	conn, err := c.dial()
+	if err != nil {
+		return diagnosis.fail(ProblemUnreachable, err)
+	}
+
+	defer conn.Close()
+
+	diagnosis.Reachable = true
+
+	err = conn.SetDeadline(time.Now().Add(clnCallTimeout))
+	if err != nil {
+		return diagnosis.fail(ProblemUnexpected, err)
+	}
+
+	info := clnGetInfoResponse{}
+
+	err = c.callOn(conn, "getinfo", map[string]interface{}{}, &info)
+	if err != nil {
+		return diagnosis.fail(ProblemUnexpected, err)
+	}
+
+	diagnosis.PubKey = info.Id
+	diagnosis.Alias = info.Alias
+	diagnosis.Network = info.Network
+
+	if info.Network != clnNetwork(c.bitcoinNetwork) {
+		return diagnosis.fail(ProblemWrongChain, errors.Errorf("Node is on %s instead of %s", info.Network, clnNetwork(c.bitcoinNetwork)))
+	}
+
+	return diagnosis
+}
+
+// run waits for paid invoices in order of their pay index until the
+// node is stopped
+func (c *ClnNode) run(done chan struct{}) {
+	for {
+		invoice, err := c.waitAnyInvoice()
+
+		select {
+		case <-done:
+			c.logger.Infof("Stopping invoice listener")
+			return
+		default:
+		}
+
+		if err != nil {
+			c.logger.Errorf("Failed waiting for invoices: %v", err)
+			time.Sleep(1 * time.Second)
+			continue
+		}
+
+		c.notifyInvoice(invoice.toInvoice())
+
+		if invoice.PayIndex > c.settleIndex {
+			c.settleIndex = invoice.PayIndex
+		}
+	}
+}
+
+// latestPayIndex returns the highest pay index of all paid invoices
+func (c *ClnNode) latestPayIndex() (uint64, error) {
+	res := clnListInvoicesResponse{}
+
+	err := c.call("listinvoices", map[string]interface{}{}, &res)
+	if err != nil {
+		return 0, err
+	}
+
+	var index uint64
+
+	for _, invoice := range res.Invoices {
+		if invoice.PayIndex > index {
+			index = invoice.PayIndex
+		}
+	}
+
+	return index, nil
+}
+
+func (c *ClnNode) waitAnyInvoice() (*clnInvoice, error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	// keep the blocking connection around so stopping can interrupt it
+	c.waitConnMu.Lock()
+	c.waitConn = conn
+	c.waitConnMu.Unlock()
+
+	invoice := &clnInvoice{}
+
+	err = c.callOn(conn, "waitanyinvoice", []interface{}{c.settleIndex}, invoice)
+	if err != nil {
+		return nil, err
+	}
+
+	return invoice, nil
+}
+
+func (c *ClnNode) notifyInvoice(invoice *Invoice) {
+	c.clientsMu.Lock()
+	clients := make([]*InvoicesClient, 0, len(c.invoicesClients))
+	for _, client := range c.invoicesClients {
+		clients = append(clients, client)
+	}
+	c.clientsMu.Unlock()
+
+	for _, client := range clients {
+		select {
+		case client.Invoices <- invoice:
+		case <-client.cancelChan:
+		}
+	}
+}
+
+func (c *ClnNode) Stop() error {
+	c.updateStatus(StatusStopped)
+
+	if c.done != nil {
+		close(c.done)
+		c.done = nil
+	}
+
+	c.waitConnMu.Lock()
+	if c.waitConn != nil {
+		c.waitConn.Close()
+		c.waitConn = nil
+	}
+	c.waitConnMu.Unlock()
+
+	c.closeAllInvoiceSubscriptions()
+
+	return nil
+}
+
+func (c *ClnNode) GetInvoice(rHash string) (*Invoice, error) {
+	res := clnListInvoicesResponse{}
+
+	err := c.call("listinvoices", map[string]interface{}{
+		"payment_hash": rHash,
+	}, &res)
+	if err != nil {
+		return nil, errors.Errorf("Could not find invoice: %v", err)
+	}
+
+	if len(res.Invoices) == 0 {
+		return nil, errors.Errorf("Could not find invoice %s", rHash)
+	}
+
+	return res.Invoices[0].toInvoice(), nil
+}
+
+func (c *ClnNode) AddInvoice(req *InvoiceRequest) (*Invoice, error) {
+	if req.Hold {
+		c.logger.Errorf("Hold invoices not supported, adding regular invoice")
+	}
+
+	label := make([]byte, 16)
+
+	_, err := rand.Read(label)
+	if err != nil {
+		return nil, errors.Errorf("Could not generate label: %v", err)
+	}
+
+	res := clnAddInvoiceResponse{}
+
+	// positional parameters are understood by all versions, while the
+	// name of the amount parameter changed over time
+	err = c.call("invoice", []interface{}{
+		req.MSat,
+		"sweetd-" + hex.EncodeToString(label),
+		req.Memo,
+	}, &res)
+	if err != nil {
+		return nil, errors.Errorf("Could not add invoice: %v", err)
+	}
+
+	return &Invoice{
+		Settled:        false,
+		RHash:          res.PaymentHash,
+		PaymentRequest: res.Bolt11,
+		Memo:           req.Memo,
+		MSat:           req.MSat,
+	}, nil
+}
+
+func (c *ClnNode) SettleInvoice(preimage string) error {
+	return errors.Errorf("Hold invoices not supported")
+}
+
+func (c *ClnNode) CancelInvoice(rHash string) error {
+	return errors.Errorf("Hold invoices not supported")
+}
+
+func (c *ClnNode) SubscribeInvoices() (*InvoicesClient, error) {
+	client := &InvoicesClient{
+		Invoices:   make(chan *Invoice),
+		cancelChan: make(chan struct{}),
+		node:       c,
+	}
+
+	c.nextInvoicesClient.Lock()
+	client.Id = c.nextInvoicesClient.id
+	c.nextInvoicesClient.id++
+	c.nextInvoicesClient.Unlock()
+
+	c.clientsMu.Lock()
+	c.invoicesClients[client.Id] = client
+	c.clientsMu.Unlock()
+
+	return client, nil
+}
+
+func (c *ClnNode) closeAllInvoiceSubscriptions() {
+	c.clientsMu.Lock()
+	clients := make([]*InvoicesClient, 0, len(c.invoicesClients))
+	for _, client := range c.invoicesClients {
+		clients = append(clients, client)
+	}
+	c.clientsMu.Unlock()
+
+	for _, client := range clients {
+		client.Cancel()
+	}
+}
+
+func (c *ClnNode) unsubscribeInvoices(client *InvoicesClient) {
+	c.clientsMu.Lock()
+	delete(c.invoicesClients, client.Id)
+	c.clientsMu.Unlock()
+
+	close(client.cancelChan)
+}
+
+func (c *ClnNode) GenerateSeed() ([]string, error) {
+	return nil, errors.Errorf("Not supported by Core Lightning")
+}
+
+func (c *ClnNode) Init(password string, mnemonic []string) error {
+	return errors.Errorf("Not supported by Core Lightning")
+}
+
+func (c *ClnNode) Unlock(password string) error {
+	return errors.Errorf("Not supported by Core Lightning")
+}
+
+func (c *ClnNode) updateStatus(status Status) {
+	c.clientsMu.Lock()
+	defer c.clientsMu.Unlock()
+
+	c.status = status
+
+	for _, client := range c.statusClients {
+		client.publish(status)
+	}
+}
+
+func (c *ClnNode) Status() Status {
+	c.clientsMu.Lock()
+	defer c.clientsMu.Unlock()
+
+	return c.status
+}
+
+func (c *ClnNode) SubscribeStatus() *StatusClient {
+	client := &StatusClient{
+		Status:     make(chan Status, 1),
+		cancelChan: make(chan struct{}),
+		node:       c,
+	}
+
+	c.nextStatusClient.Lock()
+	client.Id = c.nextStatusClient.id
+	c.nextStatusClient.id++
+	c.nextStatusClient.Unlock()
+
+	c.clientsMu.Lock()
+	c.statusClients[client.Id] = client
+	c.clientsMu.Unlock()
+
+	return client
+}
+
+func (c *ClnNode) unsubscribeStatus(client *StatusClient) {
+	c.clientsMu.Lock()
+	delete(c.statusClients, client.Id)
+	c.clientsMu.Unlock()
+
+	close(client.cancelChan)
+}
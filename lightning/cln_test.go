@@ -0,0 +1,321 @@
+package lightning
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeCln answers JSON-RPC requests like a Core Lightning node, with
+// results provided per method
+type fakeCln struct {
+	listener net.Listener
+	results  map[string]func(params json.RawMessage) interface{}
+}
+
+func newFakeCln(t *testing.T, results map[string]func(params json.RawMessage) interface{}) *fakeCln {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, nil, err)
+
+	fake := &fakeCln{
+		listener: listener,
+		results:  results,
+	}
+
+	go fake.serve()
+
+	return fake
+}
+
+func (f *fakeCln) uri() string {
+	return "tcp://" + f.listener.Addr().String()
+}
+
+func (f *fakeCln) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+
+			req := struct {
+				Id     uint64          `json:"id"`
+				Method string          `json:"method"`
+				Params json.RawMessage `json:"params"`
+			}{}
+
+			if err := json.NewDecoder(conn).Decode(&req); err != nil {
+				return
+			}
+
+			res := map[string]interface{}{
+				"jsonrpc": "2.0",
+				"id":      req.Id,
+			}
+
+			if result, ok := f.results[req.Method]; ok {
+				res["result"] = result(req.Params)
+			} else {
+				res["error"] = map[string]interface{}{
+					"code":    -32601,
+					"message": "Unknown command",
+				}
+			}
+
+			json.NewEncoder(conn).Encode(res)
+		}()
+	}
+}
+
+func TestClnNodeInvoices(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeCln(t, map[string]func(params json.RawMessage) interface{}{
+		"getinfo": func(params json.RawMessage) interface{} {
+			return map[string]interface{}{"id": "02abc", "network": "bitcoin"}
+		},
+		"invoice": func(params json.RawMessage) interface{} {
+			return map[string]interface{}{
+				"payment_hash": "aabb",
+				"bolt11":       "lnbc1",
+			}
+		},
+		"listinvoices": func(params json.RawMessage) interface{} {
+			return map[string]interface{}{
+				"invoices": []interface{}{
+					map[string]interface{}{
+						"payment_hash":         "aabb",
+						"bolt11":               "lnbc1",
+						"status":               "paid",
+						"description":          "Candy",
+						"amount_msat":          "8000msat",
+						"amount_received_msat": 9000,
+						"pay_index":            3,
+					},
+				},
+			}
+		},
+	})
+	defer fake.listener.Close()
+
+	node, err := NewClnNode(&ClnNodeConfig{Uri: fake.uri()})
+	assert.Equal(t, nil, err)
+
+	invoice, err := node.AddInvoice(&InvoiceRequest{MSat: 8000, Memo: "Candy"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "aabb", invoice.RHash)
+	assert.Equal(t, "lnbc1", invoice.PaymentRequest)
+
+	invoice, err = node.GetInvoice("aabb")
+	assert.Equal(t, nil, err)
+	assert.True(t, invoice.Settled)
+	assert.Equal(t, int64(8000), invoice.MSat)
+	assert.Equal(t, int64(9000), invoice.PaidMSat)
+	assert.Equal(t, uint64(3), invoice.SettleIndex)
+}
+
+func TestClnNodeSubscribeInvoices(t *testing.T) {
+	t.Parallel()
+
+	waits := make(chan uint64, 10)
+	block := make(chan struct{})
+	defer close(block)
+
+	fake := newFakeCln(t, map[string]func(params json.RawMessage) interface{}{
+		"getinfo": func(params json.RawMessage) interface{} {
+			return map[string]interface{}{"id": "02abc", "network": "bitcoin"}
+		},
+		"waitanyinvoice": func(params json.RawMessage) interface{} {
+			var args []uint64
+			json.Unmarshal(params, &args)
+			waits <- args[0]
+
+			// only a single invoice gets paid
+			if args[0] > 7 {
+				<-block
+			}
+
+			return map[string]interface{}{
+				"payment_hash": "ccdd",
+				"status":       "paid",
+				"msatoshi":     5000,
+				"pay_index":    args[0] + 1,
+			}
+		},
+	})
+	defer fake.listener.Close()
+
+	node, err := NewClnNode(&ClnNodeConfig{Uri: fake.uri(), SettleIndex: 7})
+	assert.Equal(t, nil, err)
+
+	client, err := node.SubscribeInvoices()
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, nil, node.Start())
+	assert.Equal(t, StatusStarted, node.Status())
+
+	select {
+	case invoice := <-client.Invoices:
+		assert.Equal(t, "ccdd", invoice.RHash)
+		assert.True(t, invoice.Settled)
+		assert.Equal(t, int64(5000), invoice.MSat)
+		assert.Equal(t, uint64(8), invoice.SettleIndex)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no invoice received")
+	}
+
+	// waiting resumes after the pay index of the last invoice
+	assert.Equal(t, uint64(7), <-waits)
+	assert.Equal(t, uint64(8), <-waits)
+
+	assert.Equal(t, nil, node.Stop())
+}
+
+func TestClnNodeSkipsPaidInvoices(t *testing.T) {
+	t.Parallel()
+
+	// the node was paid 5 invoices before it was added
+	paid := 5
+	pay := make(chan struct{}, 1)
+	defer close(pay)
+	waits := make(chan uint64, 10)
+
+	invoice := func(index uint64) map[string]interface{} {
+		return map[string]interface{}{
+			"payment_hash": fmt.Sprintf("hash%d", index),
+			"status":       "paid",
+			"msatoshi":     5000,
+			"pay_index":    index,
+		}
+	}
+
+	fake := newFakeCln(t, map[string]func(params json.RawMessage) interface{}{
+		"getinfo": func(params json.RawMessage) interface{} {
+			return map[string]interface{}{"id": "02abc", "network": "bitcoin"}
+		},
+		"listinvoices": func(params json.RawMessage) interface{} {
+			invoices := []interface{}{}
+			for i := 1; i <= paid; i++ {
+				invoices = append(invoices, invoice(uint64(i)))
+			}
+			invoices = append(invoices, map[string]interface{}{"payment_hash": "unpaid", "status": "unpaid"})
+
+			return map[string]interface{}{"invoices": invoices}
+		},
+		"waitanyinvoice": func(params json.RawMessage) interface{} {
+			var args []uint64
+			json.Unmarshal(params, &args)
+			waits <- args[0]
+
+			// waiting returns the next paid invoice after the given index
+			if args[0] >= uint64(paid) {
+				<-pay
+			}
+
+			return invoice(args[0] + 1)
+		},
+	})
+	defer fake.listener.Close()
+
+	saved := make(chan uint64, 1)
+
+	node, err := NewClnNode(&ClnNodeConfig{
+		Uri: fake.uri(),
+		OnSettleIndex: func(index uint64) {
+			saved <- index
+		},
+	})
+	assert.Equal(t, nil, err)
+
+	client, err := node.SubscribeInvoices()
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, nil, node.Start())
+	assert.Equal(t, uint64(5), <-saved)
+	assert.Equal(t, uint64(5), <-waits)
+
+	// a single invoice gets paid after the node started
+	pay <- struct{}{}
+
+	select {
+	case invoice := <-client.Invoices:
+		assert.Equal(t, "hash6", invoice.RHash)
+		assert.Equal(t, uint64(6), invoice.SettleIndex)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no invoice received")
+	}
+
+	assert.Equal(t, nil, node.Stop())
+}
+
+func TestClnNodeUnavailable(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewClnNode(&ClnNodeConfig{Uri: "localhost:9735"})
+	assert.NotEqual(t, nil, err)
+
+	node, err := NewClnNode(&ClnNodeConfig{Uri: "unix:///nonexistent/lightning-rpc"})
+	assert.Equal(t, nil, err)
+	assert.NotEqual(t, nil, node.Start())
+	assert.Equal(t, StatusFailed, node.Status())
+}
+
+func TestClnNodeWrongNetwork(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeCln(t, map[string]func(params json.RawMessage) interface{}{
+		"getinfo": func(params json.RawMessage) interface{} {
+			return map[string]interface{}{"id": "02abc", "network": "testnet"}
+		},
+		"listinvoices": func(params json.RawMessage) interface{} {
+			return map[string]interface{}{"invoices": []interface{}{}}
+		},
+	})
+	defer fake.listener.Close()
+
+	node, err := NewClnNode(&ClnNodeConfig{Uri: fake.uri()})
+	assert.Equal(t, nil, err)
+	assert.NotEqual(t, nil, node.Start())
+	assert.Equal(t, StatusFailed, node.Status())
+
+	node, err = NewClnNode(&ClnNodeConfig{Uri: fake.uri(), Network: NetworkTestnet})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, node.Start())
+	assert.Equal(t, nil, node.Stop())
+}
+
+func TestClnNodeTest(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeCln(t, map[string]func(params json.RawMessage) interface{}{
+		"getinfo": func(params json.RawMessage) interface{} {
+			return map[string]interface{}{"id": "02abc", "alias": "candy", "network": "testnet"}
+		},
+	})
+	defer fake.listener.Close()
+
+	node, err := NewClnNode(&ClnNodeConfig{Uri: fake.uri(), Network: NetworkTestnet})
+	assert.Equal(t, nil, err)
+
+	diagnosis := node.Test()
+	assert.True(t, diagnosis.Ok())
+	assert.Equal(t, "02abc", diagnosis.PubKey)
+	assert.Equal(t, "testnet", diagnosis.Network)
+
+	node, err = NewClnNode(&ClnNodeConfig{Uri: fake.uri()})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ProblemWrongChain, node.Test().Problem)
+
+	node, err = NewClnNode(&ClnNodeConfig{Uri: "unix:///nonexistent/lightning-rpc"})
+	assert.Equal(t, nil, err)
+
+	diagnosis = node.Test()
+	assert.Equal(t, ProblemUnreachable, diagnosis.Problem)
+	assert.False(t, diagnosis.Reachable)
+}
@@ -0,0 +1,39 @@
+package lightning
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// Dialer opens a connection to an address through a proxy like Tor
+type Dialer func(ctx context.Context, address string) (net.Conn, error)
+
+// Connection is the state of the connection to a remote node
+type Connection struct {
+	// State is one of idle, connecting, ready, transient-failure,
+	// shutdown or disconnected if the node isn't started
+	State string
+	// Tor is set if the connection is routed through Tor
+	Tor bool
+}
+
+// ConnectionNode is implemented by nodes which keep a connection to a
+// remote host
+type ConnectionNode interface {
+	// Connection returns the current state of the connection
+	Connection() *Connection
+	// Reconnect retries a failed connection right away instead of
+	// waiting for the next backoff, after the network changed
+	Reconnect()
+}
+
+// isOnion tells whether a host:port address is a Tor onion service
+func isOnion(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+
+	return strings.HasSuffix(strings.ToLower(host), ".onion")
+}
@@ -0,0 +1,51 @@
+package lightning
+
+import (
+	"context"
+	"github.com/go-errors/errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestIsOnion(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isOnion("abcdefghijklmnop.onion:10009"))
+	assert.True(t, isOnion("ABCDEFGHIJKLMNOP.ONION"))
+	assert.False(t, isOnion("node.example.com:10009"))
+	assert.False(t, isOnion("127.0.0.1:10009"))
+}
+
+func TestLndNodeTorDialer(t *testing.T) {
+	t.Parallel()
+
+	// onion hosts can't be reached without Tor
+	node, err := NewLndNode(&LndNodeConfig{Uri: "abcdefghijklmnop.onion:10009"})
+	assert.Equal(t, nil, err)
+	assert.True(t, node.Connection().Tor)
+	assert.Equal(t, ProblemUnreachable, node.Test().Problem)
+
+	dialed := make(chan string, 10)
+	dialer := func(ctx context.Context, address string) (net.Conn, error) {
+		dialed <- address
+		return nil, errors.New("no circuit")
+	}
+
+	node, err = NewLndNode(&LndNodeConfig{Uri: "abcdefghijklmnop.onion:10009", TorDialer: dialer})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ProblemUnreachable, node.Test().Problem)
+	assert.Equal(t, "abcdefghijklmnop.onion:10009", <-dialed)
+
+	// other hosts only use Tor if configured
+	node, err = NewLndNode(&LndNodeConfig{Uri: "127.0.0.1:1", TorDialer: dialer})
+	assert.Equal(t, nil, err)
+	assert.False(t, node.Connection().Tor)
+	assert.Equal(t, "disconnected", node.Connection().State)
+
+	node, err = NewLndNode(&LndNodeConfig{Uri: "127.0.0.1:1", TorDialer: dialer, Tor: true})
+	assert.Equal(t, nil, err)
+	assert.True(t, node.Connection().Tor)
+	assert.Equal(t, ProblemUnreachable, node.Test().Problem)
+	assert.Equal(t, "127.0.0.1:1", <-dialed)
+}
@@ -0,0 +1,97 @@
+package lightning
+
+import (
+	"strings"
+)
+
+// Problem is why a connection test failed
+type Problem string
+
+const (
+	// ProblemUnreachable is set if the node couldn't be dialed
+	ProblemUnreachable Problem = "unreachable"
+	// ProblemTls is set if the certificate of the node wasn't accepted
+	ProblemTls Problem = "tls"
+	// ProblemAuth is set if the node rejected the credentials
+	ProblemAuth Problem = "auth"
+	// ProblemPermissions is set if the credentials don't allow creating
+	// and reading invoices
+	ProblemPermissions Problem = "permissions"
+	// ProblemWrongChain is set if the node is on another network than
+	// the configured one
+	ProblemWrongChain Problem = "wrong-chain"
+	// ProblemUnexpected is set if the node answered in an unexpected way
+	ProblemUnexpected Problem = "unexpected"
+)
+
+// Diagnosis is the result of a connection test, which tells whether a
+// node can be used before it is saved
+type Diagnosis struct {
+	// Problem is empty if the node can be used
+	Problem Problem
+	// Error of the failed step of the test
+	Error     string
+	Reachable bool
+	PubKey    string
+	Alias     string
+	// Network reported by the node, empty if it couldn't be read
+	Network     string
+	Permissions *MacaroonPermissions
+	// Warnings about a node that can be used anyway
+	Warnings []string
+}
+
+// TestableNode is implemented by nodes which can be tested without being
+// started
+type TestableNode interface {
+	// Test dials the node, checks its credentials and network and
+	// returns a diagnosis without side effects
+	Test() *Diagnosis
+}
+
+// Ok tells whether the node can be used
+func (d *Diagnosis) Ok() bool {
+	return d.Problem == ""
+}
+
+func (d *Diagnosis) fail(problem Problem, err error) *Diagnosis {
+	d.Problem = problem
+	d.Error = err.Error()
+	return d
+}
+
+func (d *Diagnosis) warn(warning string) {
+	d.Warnings = append(d.Warnings, warning)
+}
+
+// checkPermissions requires permissions for invoices and warns about
+// credentials that can spend funds
+func (d *Diagnosis) checkPermissions(permissions *MacaroonPermissions) {
+	d.Permissions = permissions
+
+	writeInvoices := permissions.WriteInvoices || permissions.IsUnknown("invoices:write")
+
+	if !permissions.ReadInvoices || !writeInvoices {
+		d.Problem = ProblemPermissions
+		d.Error = "macaroon must allow creating and reading invoices"
+		return
+	}
+
+	for _, permission := range permissions.Unknown {
+		d.warn("could not check permission " + permission + " of the macaroon")
+	}
+
+	if permissions.Admin {
+		d.warn("macaroon is over-privileged, an invoice macaroon is sufficient")
+	}
+}
+
+// isTlsError tells whether a connection failed because of the
+// certificate rather than because the peer wasn't reached
+func isTlsError(err error) bool {
+	message := err.Error()
+
+	return strings.Contains(message, "x509:") ||
+		strings.Contains(message, "tls:") ||
+		strings.Contains(message, "authentication handshake failed")
+}
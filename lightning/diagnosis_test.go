@@ -0,0 +1,42 @@
+package lightning
+
+import (
+	"github.com/go-errors/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestDiagnosisCheckPermissions(t *testing.T) {
+	t.Parallel()
+
+	diagnosis := &Diagnosis{}
+	diagnosis.checkPermissions(&MacaroonPermissions{ReadInvoices: true, WriteInvoices: true})
+	assert.True(t, diagnosis.Ok())
+	assert.Equal(t, 0, len(diagnosis.Warnings))
+
+	diagnosis = &Diagnosis{}
+	diagnosis.checkPermissions(&MacaroonPermissions{ReadInvoices: true, WriteInvoices: true, Admin: true})
+	assert.True(t, diagnosis.Ok())
+	assert.Equal(t, 1, len(diagnosis.Warnings))
+
+	diagnosis = &Diagnosis{}
+	diagnosis.checkPermissions(&MacaroonPermissions{ReadInvoices: true})
+	assert.Equal(t, ProblemPermissions, diagnosis.Problem)
+
+	diagnosis = &Diagnosis{}
+	diagnosis.checkPermissions(&MacaroonPermissions{ReadInvoices: true, Unknown: []string{"invoices:write"}})
+	assert.True(t, diagnosis.Ok())
+	assert.Equal(t, 1, len(diagnosis.Warnings))
+}
+
+func TestLndProblem(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, ProblemTls, lndProblem(status.Error(codes.Unavailable, "connection error: desc = \"transport: authentication handshake failed: x509: certificate signed by unknown authority\"")))
+	assert.Equal(t, ProblemUnreachable, lndProblem(status.Error(codes.Unavailable, "connection error: desc = \"transport: Error while dialing dial tcp 127.0.0.1:10009: connect: connection refused\"")))
+	assert.Equal(t, ProblemAuth, lndProblem(status.Error(codes.Unknown, "verification failed: signature mismatch after caveat verification")))
+	assert.Equal(t, ProblemAuth, lndProblem(status.Error(codes.Unknown, "expected 1 macaroon, got 0")))
+	assert.Equal(t, ProblemUnexpected, lndProblem(errors.New("unknown")))
+}
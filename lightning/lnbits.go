@@ -0,0 +1,691 @@
+package lightning
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/go-errors/errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLnbitsPollInterval = 2 * time.Second
+	// lnbitsWatchDuration is how long an unpaid invoice is polled for
+	// and how far back payments are caught up with on start
+	lnbitsWatchDuration = 1 * time.Hour
+	lnbitsMinBackoff    = 1 * time.Second
+	lnbitsMaxBackoff    = 2 * time.Minute
+	// lnbitsCatchUpLimit is the number of recent payments checked on start
+	lnbitsCatchUpLimit = 100
+)
+
+type LnbitsNodeConfig struct {
+	// Url of the LNbits instance, like https://legend.lnbits.com
+	Url string
+	// InvoiceKey of the wallet, which only allows creating and
+	// checking invoices
+	InvoiceKey string
+	// Client used for requests, defaults to a client with a timeout
+	Client *http.Client
+	// PollInterval at which open invoices are checked
+	PollInterval time.Duration
+	Logger       Logger
+}
+
+// LnbitsNode is a custodial wallet accessed through an LNbits compatible
+// REST API. Incoming payments arrive through the server-sent events of
+// the wallet, open invoices are also polled in case the stream is down.
+type LnbitsNode struct {
+	url          string
+	invoiceKey   string
+	client       *http.Client
+	pollInterval time.Duration
+	logger       Logger
+	done         chan struct{}
+	// watched are the open invoices by their creation time
+	watched map[string]time.Time
+	// settled are the reported payments by the time they were reported,
+	// so that the stream and the polling don't both report them
+	settled            map[string]time.Time
+	watchedMu          sync.Mutex
+	invoicesClients    map[uint32]*InvoicesClient
+	nextInvoicesClient nextClient
+	statusClients      map[uint32]*StatusClient
+	nextStatusClient   nextClient
+	clientsMu          sync.Mutex
+	status             Status
+}
+
+// Compile time check for protocol compatibility
+var _ Node = (*LnbitsNode)(nil)
+var _ TestableNode = (*LnbitsNode)(nil)
+
+type lnbitsCreateInvoiceRequest struct {
+	Out    bool   `json:"out"`
+	Amount int64  `json:"amount"`
+	Memo   string `json:"memo"`
+}
+
+type lnbitsCreateInvoiceResponse struct {
+	PaymentHash    string `json:"payment_hash"`
+	PaymentRequest string `json:"payment_request"`
+}
+
+type lnbitsPaymentDetails struct {
+	PaymentHash string `json:"payment_hash"`
+	Bolt11      string `json:"bolt11"`
+	Memo        string `json:"memo"`
+	// Amount in millisatoshis, negative for outgoing payments
+	Amount int64 `json:"amount"`
+}
+
+type lnbitsPaymentResponse struct {
+	Paid    bool                  `json:"paid"`
+	Details *lnbitsPaymentDetails `json:"details"`
+}
+
+// lnbitsPayment is an entry of the payment list and of the payment events
+type lnbitsPayment struct {
+	PaymentHash string `json:"payment_hash"`
+	Bolt11      string `json:"bolt11"`
+	Memo        string `json:"memo"`
+	// Amount in millisatoshis, negative for outgoing payments
+	Amount  int64      `json:"amount"`
+	Pending bool       `json:"pending"`
+	Time    lnbitsTime `json:"time"`
+}
+
+// lnbitsTime is reported either as unix seconds or, by newer versions,
+// as an ISO 8601 string
+type lnbitsTime time.Time
+
+func (t *lnbitsTime) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), "\"")
+
+	if value == "" || value == "null" {
+		*t = lnbitsTime{}
+		return nil
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		*t = lnbitsTime(time.Unix(int64(seconds), 0))
+		return nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999", "2006-01-02 15:04:05.999999"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			*t = lnbitsTime(parsed)
+			return nil
+		}
+	}
+
+	return errors.Errorf("invalid time %s", data)
+}
+
+type lnbitsWalletResponse struct {
+	Name string `json:"name"`
+}
+
+type lnbitsErrorResponse struct {
+	Detail string `json:"detail"`
+}
+
+func NewLnbitsNode(config *LnbitsNodeConfig) (*LnbitsNode, error) {
+	if !strings.HasPrefix(config.Url, "http://") && !strings.HasPrefix(config.Url, "https://") {
+		return nil, errors.Errorf("unsupported url %s, expected http:// or https://", config.Url)
+	}
+
+	if config.InvoiceKey == "" {
+		return nil, errors.Errorf("invoice key must not be empty")
+	}
+
+	node := &LnbitsNode{
+		url:             strings.TrimSuffix(config.Url, "/"),
+		invoiceKey:      config.InvoiceKey,
+		client:          config.Client,
+		pollInterval:    config.PollInterval,
+		logger:          config.Logger,
+		watched:         make(map[string]time.Time),
+		settled:         make(map[string]time.Time),
+		invoicesClients: make(map[uint32]*InvoicesClient),
+		statusClients:   make(map[uint32]*StatusClient),
+		status:          StatusStopped,
+	}
+
+	if node.client == nil {
+		node.client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	if node.pollInterval == 0 {
+		node.pollInterval = defaultLnbitsPollInterval
+	}
+
+	if node.logger == nil {
+		node.logger = noopLogger{}
+	}
+
+	return node, nil
+}
+
+func (l *LnbitsNode) request(method string, path string, body interface{}, result interface{}) error {
+	var payload bytes.Buffer
+
+	if body != nil {
+		err := json.NewEncoder(&payload).Encode(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, l.url+path, &payload)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("X-Api-Key", l.invoiceKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		errorRes := lnbitsErrorResponse{}
+		json.NewDecoder(res.Body).Decode(&errorRes)
+
+		if errorRes.Detail != "" {
+			return errors.Errorf("%s (%d)", errorRes.Detail, res.StatusCode)
+		}
+
+		return errors.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+func (l *LnbitsNode) Start() error {
+	l.logger.Infof("starting %s", l.url)
+
+	err := l.request(http.MethodGet, "/api/v1/wallet", nil, nil)
+	if err != nil {
+		l.updateStatus(StatusFailed)
+		return errors.Errorf("Could not get wallet: %v", err)
+	}
+
+	err = l.catchUp()
+	if err != nil {
+		l.logger.Errorf("Could not catch up with recent payments: %v", err)
+	}
+
+	l.done = make(chan struct{})
+
+	l.updateStatus(StatusStarted)
+
+	go l.run(l.done)
+	go l.streamPayments(l.done)
+
+	return nil
+}
+
+// catchUp watches the incoming payments of the last watch duration, so
+// that payments made while sweetd was offline or the node was being
+// re-created are reported by the next poll. Payments reported before a
+// restart are reported again, the dispenser only dispenses them once.
+func (l *LnbitsNode) catchUp() error {
+	payments := []*lnbitsPayment{}
+
+	err := l.request(http.MethodGet, "/api/v1/payments?limit="+strconv.Itoa(lnbitsCatchUpLimit), nil, &payments)
+	if err != nil {
+		return err
+	}
+
+	l.watchedMu.Lock()
+	defer l.watchedMu.Unlock()
+
+	for _, payment := range payments {
+		created := time.Time(payment.Time)
+
+		if payment.Amount <= 0 || time.Since(created) > lnbitsWatchDuration {
+			continue
+		}
+
+		if _, ok := l.settled[payment.PaymentHash]; !ok {
+			l.watched[payment.PaymentHash] = created
+		}
+	}
+
+	return nil
+}
+
+// Test reads the wallet of the invoice key without starting the node.
+// Custodial wallets have no network to check.
+func (l *LnbitsNode) Test() *Diagnosis {
+	diagnosis := &Diagnosis{}
+
+	req, err := http.NewRequest(http.MethodGet, l.url+"/api/v1/wallet", nil)
+	if err != nil {
+		return diagnosis.fail(ProblemUnexpected, err)
+	}
+
+	req.Header.Set("X-Api-Key", l.invoiceKey)
+
+	res, err := l.client.Do(req)
+	if err != nil {
+		if isTlsError(err) {
+			return diagnosis.fail(ProblemTls, err)
+		}
+
+		return diagnosis.fail(ProblemUnreachable, err)
+	}
+
+	defer res.Body.Close()
+
+	diagnosis.Reachable = true
+
+	switch {
+	case res.StatusCode == http.StatusUnauthorized, res.StatusCode == http.StatusForbidden:
+		return diagnosis.fail(ProblemAuth, errors.Errorf("invoice key was rejected (%d)", res.StatusCode))
+	case res.StatusCode < 200 || res.StatusCode >= 300:
+		return diagnosis.fail(ProblemUnexpected, errors.Errorf("unexpected status %d", res.StatusCode))
+	}
+
+	wallet := lnbitsWalletResponse{}
+
+	err = json.NewDecoder(res.Body).Decode(&wallet)
+	if err != nil {
+		return diagnosis.fail(ProblemUnexpected, errors.Errorf("unable to decode wallet: %v", err))
+	}
+
+	diagnosis.Alias = wallet.Name
+
+	return diagnosis
+}
+
+// run polls the open invoices created by this node until it is stopped
+func (l *LnbitsNode) run(done chan struct{}) {
+	ticker := time.NewTicker(l.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.pollWatchedInvoices()
+		case <-done:
+			l.logger.Infof("Stopping invoice listener")
+			return
+		}
+	}
+}
+
+func (l *LnbitsNode) pollWatchedInvoices() {
+	l.watchedMu.Lock()
+	hashes := make([]string, 0, len(l.watched))
+	for hash, created := range l.watched {
+		if time.Since(created) > lnbitsWatchDuration {
+			delete(l.watched, hash)
+			continue
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	for hash, reported := range l.settled {
+		if time.Since(reported) > lnbitsWatchDuration {
+			delete(l.settled, hash)
+		}
+	}
+	l.watchedMu.Unlock()
+
+	for _, hash := range hashes {
+		invoice, err := l.GetInvoice(hash)
+		if err != nil {
+			l.logger.Errorf("Could not check invoice %s: %v", hash, err)
+			continue
+		}
+
+		if !invoice.Settled || !l.markSettled(hash) {
+			continue
+		}
+
+		l.notifyInvoice(invoice)
+	}
+}
+
+// markSettled stops watching a paid invoice and tells whether it still
+// has to be reported
+func (l *LnbitsNode) markSettled(hash string) bool {
+	l.watchedMu.Lock()
+	defer l.watchedMu.Unlock()
+
+	delete(l.watched, hash)
+
+	if _, ok := l.settled[hash]; ok {
+		return false
+	}
+
+	l.settled[hash] = time.Now()
+
+	return true
+}
+
+// streamPayments follows the incoming payments of the wallet until the
+// node is stopped, reconnecting with an exponential backoff
+func (l *LnbitsNode) streamPayments(done chan struct{}) {
+	backoff := lnbitsMinBackoff
+
+	for {
+		err := l.subscribePayments(done, func() {
+			backoff = lnbitsMinBackoff
+		})
+
+		select {
+		case <-done:
+			l.logger.Infof("Stopping payment stream")
+			return
+		default:
+		}
+
+		l.logger.Errorf("Payment stream broke, reconnecting in %v: %v", backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-done:
+			l.logger.Infof("Stopping payment stream")
+			return
+		}
+
+		backoff *= 2
+		if backoff > lnbitsMaxBackoff {
+			backoff = lnbitsMaxBackoff
+		}
+	}
+}
+
+// subscribePayments reads the server-sent payment events of the wallet
+// until the stream breaks or the node is stopped
+func (l *LnbitsNode) subscribePayments(done chan struct{}, connected func()) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := http.NewRequest(http.MethodGet, l.url+"/api/v1/payments/sse", nil)
+	if err != nil {
+		return err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Api-Key", l.invoiceKey)
+	req.Header.Set("Accept", "text/event-stream")
+
+	// the timeout of the regular client would end the stream
+	client := &http.Client{Transport: l.client.Transport}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	connected()
+
+	event := ""
+	scanner := bufio.NewScanner(res.Body)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "":
+			event = ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:") && event == "payment-received":
+			payment := lnbitsPayment{}
+
+			err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &payment)
+			if err != nil {
+				l.logger.Errorf("Could not decode payment event: %v", err)
+				continue
+			}
+
+			l.receivePayment(&payment)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return errors.Errorf("stream ended")
+}
+
+func (l *LnbitsNode) receivePayment(payment *lnbitsPayment) {
+	if payment.Amount <= 0 || payment.Pending || !l.markSettled(payment.PaymentHash) {
+		return
+	}
+
+	l.notifyInvoice(&Invoice{
+		Settled:        true,
+		RHash:          payment.PaymentHash,
+		PaymentRequest: payment.Bolt11,
+		Memo:           payment.Memo,
+		MSat:           payment.Amount,
+		PaidMSat:       payment.Amount,
+	})
+}
+
+func (l *LnbitsNode) notifyInvoice(invoice *Invoice) {
+	l.clientsMu.Lock()
+	clients := make([]*InvoicesClient, 0, len(l.invoicesClients))
+	for _, client := range l.invoicesClients {
+		clients = append(clients, client)
+	}
+	l.clientsMu.Unlock()
+
+	for _, client := range clients {
+		select {
+		case client.Invoices <- invoice:
+		case <-client.cancelChan:
+		}
+	}
+}
+
+func (l *LnbitsNode) Stop() error {
+	l.updateStatus(StatusStopped)
+
+	if l.done != nil {
+		close(l.done)
+		l.done = nil
+	}
+
+	l.closeAllInvoiceSubscriptions()
+
+	return nil
+}
+
+func (l *LnbitsNode) GetInvoice(rHash string) (*Invoice, error) {
+	res := lnbitsPaymentResponse{}
+
+	err := l.request(http.MethodGet, "/api/v1/payments/"+rHash, nil, &res)
+	if err != nil {
+		return nil, errors.Errorf("Could not find invoice: %v", err)
+	}
+
+	invoice := &Invoice{
+		RHash:   rHash,
+		Settled: res.Paid,
+	}
+
+	if res.Details != nil {
+		invoice.PaymentRequest = res.Details.Bolt11
+		invoice.Memo = res.Details.Memo
+		invoice.MSat = res.Details.Amount
+
+		if res.Paid {
+			invoice.PaidMSat = res.Details.Amount
+		}
+	}
+
+	return invoice, nil
+}
+
+func (l *LnbitsNode) AddInvoice(req *InvoiceRequest) (*Invoice, error) {
+	if req.Hold {
+		l.logger.Errorf("Hold invoices not supported, adding regular invoice")
+	}
+
+	// invoices can only be created for whole satoshis
+	sat := (req.MSat + 999) / 1000
+
+	res := lnbitsCreateInvoiceResponse{}
+
+	err := l.request(http.MethodPost, "/api/v1/payments", &lnbitsCreateInvoiceRequest{
+		Out:    false,
+		Amount: sat,
+		Memo:   req.Memo,
+	}, &res)
+	if err != nil {
+		return nil, errors.Errorf("Could not add invoice: %v", err)
+	}
+
+	l.watchedMu.Lock()
+	l.watched[res.PaymentHash] = time.Now()
+	l.watchedMu.Unlock()
+
+	return &Invoice{
+		Settled:        false,
+		RHash:          res.PaymentHash,
+		PaymentRequest: res.PaymentRequest,
+		Memo:           req.Memo,
+		MSat:           sat * 1000,
+	}, nil
+}
+
+func (l *LnbitsNode) SettleInvoice(preimage string) error {
+	return errors.Errorf("Hold invoices not supported")
+}
+
+func (l *LnbitsNode) CancelInvoice(rHash string) error {
+	return errors.Errorf("Hold invoices not supported")
+}
+
+func (l *LnbitsNode) SubscribeInvoices() (*InvoicesClient, error) {
+	client := &InvoicesClient{
+		Invoices:   make(chan *Invoice),
+		cancelChan: make(chan struct{}),
+		node:       l,
+	}
+
+	l.nextInvoicesClient.Lock()
+	client.Id = l.nextInvoicesClient.id
+	l.nextInvoicesClient.id++
+	l.nextInvoicesClient.Unlock()
+
+	l.clientsMu.Lock()
+	l.invoicesClients[client.Id] = client
+	l.clientsMu.Unlock()
+
+	return client, nil
+}
+
+func (l *LnbitsNode) closeAllInvoiceSubscriptions() {
+	l.clientsMu.Lock()
+	clients := make([]*InvoicesClient, 0, len(l.invoicesClients))
+	for _, client := range l.invoicesClients {
+		clients = append(clients, client)
+	}
+	l.clientsMu.Unlock()
+
+	for _, client := range clients {
+		client.Cancel()
+	}
+}
+
+func (l *LnbitsNode) unsubscribeInvoices(client *InvoicesClient) {
+	l.clientsMu.Lock()
+	delete(l.invoicesClients, client.Id)
+	l.clientsMu.Unlock()
+
+	close(client.cancelChan)
+}
+
+func (l *LnbitsNode) GenerateSeed() ([]string, error) {
+	return nil, errors.Errorf("Not supported by LNbits")
+}
+
+func (l *LnbitsNode) Init(password string, mnemonic []string) error {
+	return errors.Errorf("Not supported by LNbits")
+}
+
+func (l *LnbitsNode) Unlock(password string) error {
+	return errors.Errorf("Not supported by LNbits")
+}
+
+func (l *LnbitsNode) updateStatus(status Status) {
+	l.clientsMu.Lock()
+	defer l.clientsMu.Unlock()
+
+	l.status = status
+
+	for _, client := range l.statusClients {
+		client.publish(status)
+	}
+}
+
+func (l *LnbitsNode) Status() Status {
+	l.clientsMu.Lock()
+	defer l.clientsMu.Unlock()
+
+	return l.status
+}
+
+func (l *LnbitsNode) SubscribeStatus() *StatusClient {
+	client := &StatusClient{
+		Status:     make(chan Status, 1),
+		cancelChan: make(chan struct{}),
+		node:       l,
+	}
+
+	l.nextStatusClient.Lock()
+	client.Id = l.nextStatusClient.id
+	l.nextStatusClient.id++
+	l.nextStatusClient.Unlock()
+
+	l.clientsMu.Lock()
+	l.statusClients[client.Id] = client
+	l.clientsMu.Unlock()
+
+	return client
+}
+
+func (l *LnbitsNode) unsubscribeStatus(client *StatusClient) {
+	l.clientsMu.Lock()
+	delete(l.statusClients, client.Id)
+	l.clientsMu.Unlock()
+
+	close(client.cancelChan)
+}
@@ -0,0 +1,257 @@
+package lightning
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newFakeLnbits(t *testing.T, paid func() bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "invoicekey" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"detail": "Invalid key"})
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/wallet":
+			json.NewEncoder(w).Encode(map[string]interface{}{"name": "sweetd", "balance": 0})
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/payments":
+			req := lnbitsCreateInvoiceRequest{}
+			json.NewDecoder(r.Body).Decode(&req)
+
+			assert.False(t, req.Out)
+			assert.Equal(t, int64(9), req.Amount)
+
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"payment_hash":    "aabb",
+				"payment_request": "lnbc1",
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/payments":
+			json.NewEncoder(w).Encode([]map[string]interface{}{{
+				"payment_hash": "aabb",
+				"bolt11":       "lnbc1",
+				"memo":         "Candy",
+				"amount":       9000,
+				"pending":      !paid(),
+				"time":         time.Now().Unix(),
+			}, {
+				"payment_hash": "ccdd",
+				"amount":       -1000,
+				"time":         time.Now().Unix(),
+			}})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/payments/aabb":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"paid": paid(),
+				"details": map[string]interface{}{
+					"payment_hash": "aabb",
+					"bolt11":       "lnbc1",
+					"memo":         "Candy",
+					"amount":       9000,
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestLnbitsNodeInvoices(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	isPaid := false
+
+	server := newFakeLnbits(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return isPaid
+	})
+	defer server.Close()
+
+	node, err := NewLnbitsNode(&LnbitsNodeConfig{
+		Url:          server.URL,
+		InvoiceKey:   "invoicekey",
+		PollInterval: 10 * time.Millisecond,
+	})
+	assert.Equal(t, nil, err)
+
+	client, err := node.SubscribeInvoices()
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, nil, node.Start())
+	assert.Equal(t, StatusStarted, node.Status())
+
+	// amounts are rounded up to whole satoshis
+	invoice, err := node.AddInvoice(&InvoiceRequest{MSat: 8500, Memo: "Candy"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "aabb", invoice.RHash)
+	assert.Equal(t, int64(9000), invoice.MSat)
+
+	invoice, err = node.GetInvoice("aabb")
+	assert.Equal(t, nil, err)
+	assert.False(t, invoice.Settled)
+
+	mu.Lock()
+	isPaid = true
+	mu.Unlock()
+
+	select {
+	case invoice := <-client.Invoices:
+		assert.Equal(t, "aabb", invoice.RHash)
+		assert.True(t, invoice.Settled)
+		assert.Equal(t, int64(9000), invoice.PaidMSat)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no invoice received")
+	}
+
+	assert.Equal(t, nil, node.Stop())
+}
+
+func TestLnbitsNodeInvalidKey(t *testing.T) {
+	t.Parallel()
+
+	server := newFakeLnbits(t, func() bool { return false })
+	defer server.Close()
+
+	node, err := NewLnbitsNode(&LnbitsNodeConfig{
+		Url:        server.URL,
+		InvoiceKey: "wrongkey",
+	})
+	assert.Equal(t, nil, err)
+
+	err = node.Start()
+	assert.NotEqual(t, nil, err)
+	assert.Contains(t, err.Error(), "Invalid key")
+	assert.Equal(t, StatusFailed, node.Status())
+}
+
+func TestLnbitsNodeTest(t *testing.T) {
+	t.Parallel()
+
+	server := newFakeLnbits(t, func() bool { return false })
+	defer server.Close()
+
+	node, err := NewLnbitsNode(&LnbitsNodeConfig{Url: server.URL, InvoiceKey: "invoicekey"})
+	assert.Equal(t, nil, err)
+
+	diagnosis := node.Test()
+	assert.True(t, diagnosis.Ok())
+	assert.Equal(t, "sweetd", diagnosis.Alias)
+
+	node, err = NewLnbitsNode(&LnbitsNodeConfig{Url: server.URL, InvoiceKey: "wrongkey"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ProblemAuth, node.Test().Problem)
+
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+
+	node, err = NewLnbitsNode(&LnbitsNodeConfig{Url: tlsServer.URL, InvoiceKey: "invoicekey"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ProblemTls, node.Test().Problem)
+}
+
+func TestLnbitsNodeCatchUp(t *testing.T) {
+	t.Parallel()
+
+	server := newFakeLnbits(t, func() bool { return true })
+	defer server.Close()
+
+	// a new node did not add the invoice paid while sweetd was offline
+	node, err := NewLnbitsNode(&LnbitsNodeConfig{
+		Url:          server.URL,
+		InvoiceKey:   "invoicekey",
+		PollInterval: 10 * time.Millisecond,
+	})
+	assert.Equal(t, nil, err)
+
+	client, err := node.SubscribeInvoices()
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, nil, node.Start())
+
+	select {
+	case invoice := <-client.Invoices:
+		assert.Equal(t, "aabb", invoice.RHash)
+		assert.True(t, invoice.Settled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no invoice received")
+	}
+
+	assert.Equal(t, nil, node.Stop())
+}
+
+func TestLnbitsNodePaymentStream(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan string, 2)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/wallet":
+			json.NewEncoder(w).Encode(map[string]interface{}{"name": "sweetd"})
+		case "/api/v1/payments":
+			json.NewEncoder(w).Encode([]interface{}{})
+		case "/api/v1/payments/sse":
+			assert.Equal(t, "invoicekey", r.Header.Get("X-Api-Key"))
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+
+			for {
+				select {
+				case event := <-events:
+					fmt.Fprint(w, event)
+					w.(http.Flusher).Flush()
+				case <-r.Context().Done():
+					return
+				}
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	node, err := NewLnbitsNode(&LnbitsNodeConfig{
+		Url:          server.URL,
+		InvoiceKey:   "invoicekey",
+		PollInterval: time.Hour,
+	})
+	assert.Equal(t, nil, err)
+
+	client, err := node.SubscribeInvoices()
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, nil, node.Start())
+
+	// outgoing payments are not reported, the same payment only once
+	events <- "event: payment-received\ndata: {\"payment_hash\": \"ccdd\", \"amount\": -1000}\n\n"
+	events <- "event: payment-received\ndata: {\"payment_hash\": \"aabb\", \"amount\": 9000, \"time\": \"2020-01-01T00:00:00\"}\n\n" +
+		"event: payment-received\ndata: {\"payment_hash\": \"aabb\", \"amount\": 9000}\n\n"
+
+	select {
+	case invoice := <-client.Invoices:
+		assert.Equal(t, "aabb", invoice.RHash)
+		assert.True(t, invoice.Settled)
+		assert.Equal(t, int64(9000), invoice.PaidMSat)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no invoice received")
+	}
+
+	select {
+	case invoice := <-client.Invoices:
+		t.Fatalf("unexpected invoice %s", invoice.RHash)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.Equal(t, nil, node.Stop())
+}
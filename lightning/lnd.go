@@ -2,15 +2,23 @@ package lightning
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/hex"
+	"fmt"
 	"github.com/go-errors/errors"
 	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/invoicesrpc"
+	"github.com/lightningnetwork/lnd/macaroons"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	macaroon "gopkg.in/macaroon.v2"
 	"io"
+	"strings"
 	"sync"
 	"time"
 )
@@ -20,6 +28,22 @@ var (
 	endCertificateBlock   = []byte("\n-----END CERTIFICATE-----")
 )
 
+const (
+	defaultLndHealthInterval = 30 * time.Second
+	lndHealthTimeout         = 10 * time.Second
+	lndUnlockTimeout         = 2 * time.Minute
+	lndMinBackoff            = 1 * time.Second
+	lndMaxBackoff            = 2 * time.Minute
+	// lndHealthFailures is the number of health checks in a row which
+	// have to fail before the node is considered failed
+	lndHealthFailures = 3
+	// lndTorTimeout allows for building a circuit to an onion service
+	lndTorTimeout = 30 * time.Second
+	// lndRecoveryWindow is the number of addresses scanned for funds
+	// when restoring a wallet
+	lndRecoveryWindow = 2500
+)
+
 type nextClient struct {
 	sync.Mutex
 	id uint32
@@ -29,7 +53,24 @@ type LndNodeConfig struct {
 	Uri           string
 	CertBytes     []byte
 	MacaroonBytes []byte
-	Logger        Logger
+	// SettleIndex of the last processed invoice, invoices settled later
+	// are replayed on start
+	SettleIndex uint64
+	// Network the node is expected to operate on, defaults to mainnet
+	Network Network
+	// HealthInterval at which the node is checked once started
+	HealthInterval time.Duration
+	// AutoUnlock returns the wallet password if a locked wallet should be
+	// unlocked on start, or an empty password if it shouldn't
+	AutoUnlock func() (string, error)
+	// OnChannelBackup is called with the latest multi channel backup
+	// whenever channels change
+	OnChannelBackup func(backup []byte)
+	// TorDialer connects to .onion hosts, which can't be reached without
+	// it, and to all other hosts if Tor is set
+	TorDialer Dialer
+	Tor       bool
+	Logger    Logger
 }
 
 type LndNode struct {
@@ -39,174 +80,1239 @@ type LndNode struct {
 	conn               *grpc.ClientConn
 	client             lnrpc.LightningClient
 	unlocker           lnrpc.WalletUnlockerClient
+	invoices           invoicesrpc.InvoicesClient
 	logger             Logger
 	invoicesClients    map[uint32]*InvoicesClient
 	nextInvoicesClient nextClient
 	statusClients      map[uint32]*StatusClient
 	nextStatusClient   nextClient
+	clientsMu          sync.Mutex
 	locked             bool
 	status             Status
+	settleIndex        uint64
+	addIndex           uint64
+	streaming          bool
+	network            Network
+	healthInterval     time.Duration
+	autoUnlock         func() (string, error)
+	onChannelBackup    func(backup []byte)
+	torDialer          Dialer
+	tor                bool
+	health             *Health
+	healthMu           sync.Mutex
+	heldInvoices       map[string]struct{}
+	heldInvoicesMu     sync.Mutex
+	done               chan struct{}
+}
+
+// Compile time check for protocol compatibility
+var _ Node = (*LndNode)(nil)
+var _ LiquidityNode = (*LndNode)(nil)
+var _ HealthNode = (*LndNode)(nil)
+var _ BackupNode = (*LndNode)(nil)
+var _ ChannelNode = (*LndNode)(nil)
+var _ WalletNode = (*LndNode)(nil)
+var _ MacaroonNode = (*LndNode)(nil)
+var _ TestableNode = (*LndNode)(nil)
+var _ ConnectionNode = (*LndNode)(nil)
+var _ HoldInvoiceNode = (*LndNode)(nil)
+
+func NewLndNode(config *LndNodeConfig) (*LndNode, error) {
+	node := &LndNode{
+		logger:          config.Logger,
+		invoicesClients: make(map[uint32]*InvoicesClient),
+		statusClients:   make(map[uint32]*StatusClient),
+		heldInvoices:    make(map[string]struct{}),
+		status:          StatusStopped,
+		settleIndex:     config.SettleIndex,
+		network:         config.Network,
+		healthInterval:  config.HealthInterval,
+		autoUnlock:      config.AutoUnlock,
+		onChannelBackup: config.OnChannelBackup,
+		torDialer:       config.TorDialer,
+		tor:             config.Tor,
+	}
+
+	if node.network == "" {
+		node.network = NetworkMainnet
+	}
+
+	if node.healthInterval == 0 {
+		node.healthInterval = defaultLndHealthInterval
+	}
+
+	if config.Uri != "" {
+		node.setUri(config.Uri)
+	}
+
+	if len(config.CertBytes) > 0 {
+		err := node.setTlsCredentials(config.CertBytes, false)
+		if err != nil {
+			return nil, errors.Errorf("unable to set certificate: %v", err)
+		}
+	} else {
+		// certificates issued by a trusted authority need no pinning
+		node.tlsCredentials = credentials.NewClientTLSFromCert(nil, "")
+	}
+
+	if config.MacaroonBytes != nil {
+		node.setMacaroon(config.MacaroonBytes)
+	}
+
+	return node, nil
+}
+
+func (r *LndNode) setUri(uri string) {
+	r.uri = uri
+}
+
+func (r *LndNode) setTlsCredentials(certBytes []byte, wrapped bool) error {
+	cert := x509.NewCertPool()
+
+	fullCertBytes := certBytes
+
+	if !wrapped {
+		fullCertBytes = append(beginCertificateBlock, fullCertBytes...)
+		fullCertBytes = append(fullCertBytes, endCertificateBlock...)
+	}
+
+	if ok := cert.AppendCertsFromPEM(fullCertBytes); !ok {
+		return errors.Errorf("unable to append")
+	}
+
+	r.tlsCredentials = credentials.NewClientTLSFromCert(cert, "")
+
+	return nil
+}
+
+// usesTor tells whether connections are routed through Tor
+func (r *LndNode) usesTor() bool {
+	return r.tor || isOnion(r.uri)
+}
+
+// dial connects to the node, through Tor if required
+func (r *LndNode) dial() (*grpc.ClientConn, error) {
+	options := []grpc.DialOption{
+		grpc.WithTransportCredentials(r.tlsCredentials),
+	}
+
+	if r.usesTor() {
+		if r.torDialer == nil {
+			return nil, errors.Errorf("Tor is required for %s but not available", r.uri)
+		}
+
+		options = append(options, grpc.WithContextDialer(r.torDialer))
+	}
+
+	return grpc.Dial(r.uri, options...)
+}
+
+// timeout of a single call, which takes longer over Tor
+func (r *LndNode) timeout() time.Duration {
+	if r.usesTor() {
+		return lndTorTimeout
+	}
+
+	return lndHealthTimeout
+}
+
+// Connection reports the state of the grpc connection
+func (r *LndNode) Connection() *Connection {
+	connection := &Connection{
+		State: "disconnected",
+		Tor:   r.usesTor(),
+	}
+
+	if r.conn != nil {
+		state := strings.ToLower(r.conn.GetState().String())
+		connection.State = strings.Replace(state, "_", "-", -1)
+	}
+
+	return connection
+}
+
+// Reconnect skips the backoff of a failed connection
+func (r *LndNode) Reconnect() {
+	if r.conn != nil {
+		r.conn.ResetConnectBackoff()
+	}
+}
+
+func (r *LndNode) setMacaroon(macaroonBytes []byte) {
+	hexMacaroon := hex.EncodeToString(macaroonBytes)
+	r.macaroonMetadata = metadata.Pairs("macaroon", hexMacaroon)
+}
+
+func (r *LndNode) Start() error {
+	var err error
+
+	r.logger.Infof("starting %s", r.uri)
+
+	r.conn, err = r.dial()
+	if err != nil {
+		return errors.Errorf("Could not connect to lightning node: %v", err)
+	}
+
+	r.client = lnrpc.NewLightningClient(r.conn)
+	r.unlocker = lnrpc.NewWalletUnlockerClient(r.conn)
+	r.invoices = invoicesrpc.NewInvoicesClient(r.conn)
+
+	ctx := context.Background()
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+	info, err := r.client.GetInfo(ctx, &lnrpc.GetInfoRequest{})
+	if err != nil {
+		// try to unlock to find out whether there's an existing wallet
+		_, err = r.unlocker.UnlockWallet(ctx, &lnrpc.UnlockWalletRequest{WalletPassword: []byte{}})
+		if status, ok := status.FromError(err); err != nil && ok {
+			if status.Message() == "wallet not found" {
+				r.updateStatus(StatusUninitialized)
+				return nil
+			}
+		}
+
+		info, err = r.unlockOnStart()
+		if err != nil {
+			r.logger.Errorf("Could not unlock automatically: %v", err)
+		}
+
+		if info == nil {
+			r.updateStatus(StatusLocked)
+			return nil
+		}
+	}
+
+	if !onNetwork(info, r.network) {
+		r.updateStatus(StatusFailed)
+		return errors.Errorf("Node is not on bitcoin %s", r.network)
+	}
+
+	r.setHealth(healthFromInfo(info))
+
+	if info.SyncedToChain {
+		r.updateStatus(StatusStarted)
+	} else {
+		r.updateStatus(StatusSyncing)
+	}
+
+	r.watch()
+
+	return nil
+}
+
+// watch starts following invoices, health and channel backups of an
+// unlocked wallet until the node is stopped
+func (r *LndNode) watch() {
+	if r.done != nil {
+		return
+	}
+
+	r.done = make(chan struct{})
+
+	go r.run(r.done)
+	go r.monitorHealth(r.done)
+
+	if r.onChannelBackup != nil {
+		go r.watchChannelBackups(r.done)
+	}
+}
+
+// unlockOnStart unlocks the wallet if automatic unlocking is enabled and
+// waits for lnd to come up, it returns no info if the wallet stays locked
+func (r *LndNode) unlockOnStart() (*lnrpc.GetInfoResponse, error) {
+	if r.autoUnlock == nil {
+		return nil, nil
+	}
+
+	password, err := r.autoUnlock()
+	if err != nil || password == "" {
+		return nil, err
+	}
+
+	r.logger.Infof("Unlocking wallet automatically")
+
+	_, err = r.unlocker.UnlockWallet(context.Background(), &lnrpc.UnlockWalletRequest{
+		WalletPassword: []byte(password),
+	})
+	if err != nil {
+		return nil, errors.Errorf("unable to unlock: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lndUnlockTimeout)
+	defer cancel()
+
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+
+	// the rpc server restarts after unlocking, which takes a while
+	for {
+		info, err := r.client.GetInfo(ctx, &lnrpc.GetInfoRequest{})
+		if err == nil {
+			return info, nil
+		}
+
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return nil, errors.Errorf("node didn't come up after unlocking: %v", err)
+		}
+	}
+}
+
+// onNetwork checks whether a node operates on the given bitcoin network
+func onNetwork(info *lnrpc.GetInfoResponse, network Network) bool {
+	for _, chain := range info.Chains {
+		if chain.Chain == "bitcoin" && chain.Network == string(network) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func healthFromInfo(info *lnrpc.GetInfoResponse) *Health {
+	return &Health{
+		Time:           time.Now(),
+		SyncedToChain:  info.SyncedToChain,
+		BlockHeight:    info.BlockHeight,
+		Peers:          info.NumPeers,
+		ActiveChannels: info.NumActiveChannels,
+		Uris:           info.Uris,
+	}
+}
+
+// monitorHealth checks the node periodically until it is stopped
+func (r *LndNode) monitorHealth(done chan struct{}) {
+	ticker := time.NewTicker(r.healthInterval)
+	defer ticker.Stop()
+
+	failures := 0
+
+	for {
+		select {
+		case <-ticker.C:
+			failures = r.checkHealth(done, failures)
+		case <-done:
+			return
+		}
+	}
+}
+
+// checkHealth queries the node and publishes a status change if it
+// became unreachable, fell behind the chain or recovered. It returns the
+// number of checks in a row which failed, given the previous number.
+func (r *LndNode) checkHealth(done chan struct{}, failures int) int {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout())
+	defer cancel()
+
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+
+	info, err := r.client.GetInfo(ctx, &lnrpc.GetInfoRequest{})
+
+	// the node might have been stopped while it was queried
+	select {
+	case <-done:
+		return failures
+	default:
+	}
+
+	if err != nil {
+		failures++
+
+		r.logger.Errorf("Health check failed %d times in a row: %v", failures, err)
+
+		r.setHealth(&Health{
+			Time:  time.Now(),
+			Error: err.Error(),
+		})
+
+		// a single check can fail while the connection recovers
+		if failures < lndHealthFailures {
+			r.transitionStatus(StatusDegraded)
+		} else {
+			r.transitionStatus(StatusFailed)
+		}
+
+		return failures
+	}
+
+	health := healthFromInfo(info)
+
+	inbound, err := r.InboundLiquidity()
+	if err != nil {
+		health.Error = err.Error()
+	} else {
+		health.Inbound = inbound
+	}
+
+	r.setHealth(health)
+	r.transitionStatus(r.healthyStatus())
+
+	return 0
+}
+
+// healthyStatus returns the status of a reachable node, which can only
+// take payments while it is synced and delivers invoice updates
+func (r *LndNode) healthyStatus() Status {
+	r.healthMu.Lock()
+	defer r.healthMu.Unlock()
+
+	if !r.streaming {
+		return StatusDegraded
+	}
+
+	if r.health != nil && !r.health.SyncedToChain {
+		return StatusSyncing
+	}
+
+	return StatusStarted
+}
+
+func (r *LndNode) setStreaming(streaming bool) {
+	r.healthMu.Lock()
+	r.streaming = streaming
+	r.healthMu.Unlock()
+}
+
+func (r *LndNode) setHealth(health *Health) {
+	r.healthMu.Lock()
+	r.health = health
+	r.healthMu.Unlock()
+}
+
+func (r *LndNode) Health() *Health {
+	r.healthMu.Lock()
+	defer r.healthMu.Unlock()
+
+	return r.health
+}
+
+// nextBackoff doubles the time to wait before reconnecting up to a limit
+func nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > lndMaxBackoff {
+		backoff = lndMaxBackoff
+	}
+
+	return backoff
+}
+
+// run keeps the invoice subscription alive until the node is stopped,
+// reconnecting with an exponential backoff whenever the stream breaks
+func (r *LndNode) run(done chan struct{}) {
+	backoff := lndMinBackoff
+
+	for {
+		err := r.subscribeInvoices(done, func() {
+			backoff = lndMinBackoff
+		})
+
+		select {
+		case <-done:
+			r.logger.Infof("Stopping invoice listener")
+			return
+		default:
+		}
+
+		r.setStreaming(false)
+		r.transitionStatus(StatusDegraded)
+
+		r.logger.Errorf("Invoice subscription failed, reconnecting in %v: %v", backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-done:
+			r.logger.Infof("Stopping invoice listener")
+			return
+		}
+
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// streamContext returns a context for streams which is canceled once the
+// node is stopped
+func (r *LndNode) streamContext(done chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return metadata.NewOutgoingContext(ctx, r.macaroonMetadata), cancel
+}
+
+// watchChannelBackups passes on the latest multi channel backup whenever
+// channels change, until the node is stopped
+func (r *LndNode) watchChannelBackups(done chan struct{}) {
+	backoff := lndMinBackoff
+
+	for {
+		err := r.subscribeChannelBackups(done, func() {
+			backoff = lndMinBackoff
+		})
+
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		r.logger.Errorf("Channel backup subscription failed, retrying in %v: %v", backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-done:
+			return
+		}
+
+		backoff = nextBackoff(backoff)
+	}
+}
+
+func (r *LndNode) subscribeChannelBackups(done chan struct{}, connected func()) error {
+	ctx, cancel := r.streamContext(done)
+	defer cancel()
+
+	backups, err := r.client.SubscribeChannelBackups(ctx, &lnrpc.ChannelBackupSubscription{})
+	if err != nil {
+		return errors.Errorf("Could not subscribe to channel backups: %v", err)
+	}
+
+	connected()
+
+	// the stream only sends changes, so start with the current backup
+	snapshot, err := r.client.ExportAllChannelBackups(ctx, &lnrpc.ChanBackupExportRequest{})
+	if err != nil {
+		return errors.Errorf("Could not export channel backups: %v", err)
+	}
+
+	r.notifyChannelBackup(snapshot)
+
+	for {
+		snapshot, err := backups.Recv()
+		if err != nil {
+			return errors.Errorf("Failed receiving channel backups: %v", err)
+		}
+
+		r.notifyChannelBackup(snapshot)
+	}
+}
+
+func (r *LndNode) notifyChannelBackup(snapshot *lnrpc.ChanBackupSnapshot) {
+	if snapshot.MultiChanBackup == nil || len(snapshot.MultiChanBackup.MultiChanBackup) == 0 {
+		return
+	}
+
+	r.onChannelBackup(snapshot.MultiChanBackup.MultiChanBackup)
+}
+
+// subscribeInvoices forwards invoice updates until the stream fails or
+// the node is stopped, connected is called once the stream is established
+func (r *LndNode) subscribeInvoices(done chan struct{}, connected func()) error {
+	ctx, cancel := r.streamContext(done)
+	defer cancel()
+
+	// resume after the last invoice that was forwarded, so no update is
+	// missed while not subscribed
+	invoices, err := r.client.SubscribeInvoices(ctx, &lnrpc.InvoiceSubscription{
+		AddIndex:    r.addIndex,
+		SettleIndex: r.settleIndex,
+	})
+	if err != nil {
+		return errors.Errorf("Could not subscribe to invoices: %v", err)
+	}
+
+	// hold invoices are watched by separate streams which broke as well
+	r.watchOpenHoldInvoices(done)
+
+	connected()
+	r.setStreaming(true)
+	r.transitionStatus(r.healthyStatus())
+
+	for {
+		invoice, err := invoices.Recv()
+		if err == io.EOF {
+			return errors.Errorf("Invoice stream closed")
+		}
+
+		if err != nil {
+			return errors.Errorf("Failed receiving subscription items: %v", err)
+		}
+
+		r.notifyInvoice(toInvoice(invoice))
+
+		if invoice.AddIndex > r.addIndex {
+			r.addIndex = invoice.AddIndex
+		}
+
+		if invoice.SettleIndex > r.settleIndex {
+			r.settleIndex = invoice.SettleIndex
+		}
+	}
+}
+
+func toInvoice(invoice *lnrpc.Invoice) *Invoice {
+	return &Invoice{
+		RHash:          hex.EncodeToString(invoice.RHash),
+		PaymentRequest: invoice.PaymentRequest,
+		MSat:           invoice.ValueMsat,
+		PaidMSat:       invoice.AmtPaidMsat,
+		Settled:        invoice.State == lnrpc.Invoice_SETTLED,
+		Accepted:       invoice.State == lnrpc.Invoice_ACCEPTED,
+		Canceled:       invoice.State == lnrpc.Invoice_CANCELED,
+		Memo:           invoice.Memo,
+		SettleIndex:    invoice.SettleIndex,
+	}
+}
+
+func (r *LndNode) notifyInvoice(invoice *Invoice) {
+	r.clientsMu.Lock()
+	clients := make([]*InvoicesClient, 0, len(r.invoicesClients))
+	for _, client := range r.invoicesClients {
+		clients = append(clients, client)
+	}
+	r.clientsMu.Unlock()
+
+	for _, client := range clients {
+		select {
+		case client.Invoices <- invoice:
+		case <-client.cancelChan:
+		}
+	}
+}
+
+// watchOpenHoldInvoices resumes watching hold invoices which were created
+// before the node was started, or whose stream broke, and are neither
+// settled nor canceled yet
+func (r *LndNode) watchOpenHoldInvoices(done chan struct{}) {
+	ctx, cancel := r.streamContext(done)
+	defer cancel()
+
+	res, err := r.client.ListInvoices(ctx, &lnrpc.ListInvoiceRequest{
+		PendingOnly:    true,
+		NumMaxInvoices: 1000,
+	})
+	if err != nil {
+		r.logger.Errorf("Could not list pending invoices: %v", err)
+		return
+	}
+
+	for _, invoice := range res.Invoices {
+		// the preimage of hold invoices is unknown to the node
+		if len(invoice.RPreimage) == 0 {
+			go r.watchHoldInvoice(done, invoice.RHash)
+		}
+	}
+}
+
+// watchHoldInvoice notifies about state changes of a single hold invoice,
+// as they aren't part of the general invoice subscription until settled.
+// Invoices which are watched already are skipped.
+func (r *LndNode) watchHoldInvoice(done chan struct{}, rHash []byte) {
+	hash := hex.EncodeToString(rHash)
+
+	r.heldInvoicesMu.Lock()
+	if _, ok := r.heldInvoices[hash]; ok {
+		r.heldInvoicesMu.Unlock()
+		return
+	}
+	r.heldInvoices[hash] = struct{}{}
+	r.heldInvoicesMu.Unlock()
+
+	defer func() {
+		r.heldInvoicesMu.Lock()
+		delete(r.heldInvoices, hash)
+		r.heldInvoicesMu.Unlock()
+	}()
+
+	ctx, cancel := r.streamContext(done)
+	defer cancel()
+
+	updates, err := r.invoices.SubscribeSingleInvoice(ctx, &invoicesrpc.SubscribeSingleInvoiceRequest{
+		RHash: rHash,
+	})
+	if err != nil {
+		r.logger.Errorf("Could not subscribe to hold invoice %x: %v", rHash, err)
+		return
+	}
+
+	for {
+		invoice, err := updates.Recv()
+		if err != nil {
+			r.logger.Infof("Stopped watching hold invoice %x: %v", rHash, err)
+			return
+		}
+
+		r.notifyInvoice(toInvoice(invoice))
+
+		if invoice.State == lnrpc.Invoice_SETTLED || invoice.State == lnrpc.Invoice_CANCELED {
+			return
+		}
+	}
+}
+
+func (r *LndNode) Stop() error {
+	r.updateStatus(StatusStopped)
+
+	if r.done != nil {
+		close(r.done)
+		r.done = nil
+	}
+
+	r.setStreaming(false)
+
+	if r.conn != nil {
+		err := r.conn.Close()
+		if err != nil {
+			return errors.Errorf("Could not close connection: %v", err)
+		}
+	}
+
+	r.closeAllInvoiceSubscriptions()
+
+	return nil
+}
+
+func (r *LndNode) GetInvoice(rHash string) (*Invoice, error) {
+	if r.client == nil {
+		return nil, errors.Errorf("Node not started")
+	}
+
+	ctx := context.Background()
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+
+	res, err := r.client.LookupInvoice(ctx, &lnrpc.PaymentHash{
+		RHashStr: rHash,
+	})
+	if err != nil {
+		return nil, errors.Errorf("Could not find invoice: %v", err)
+	}
+
+	return toInvoice(res), nil
+}
+
+func (r *LndNode) InboundLiquidity() (int64, error) {
+	if r.client == nil {
+		return 0, errors.Errorf("Node not started")
+	}
+
+	ctx := context.Background()
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+
+	res, err := r.client.ListChannels(ctx, &lnrpc.ListChannelsRequest{
+		ActiveOnly: true,
+	})
+	if err != nil {
+		return 0, errors.Errorf("Could not list channels: %v", err)
+	}
+
+	var inbound int64
+
+	for _, channel := range res.Channels {
+		// the remote side has to keep its reserve in the channel
+		receivable := channel.RemoteBalance - channel.RemoteChanReserveSat
+		if receivable > 0 {
+			inbound += receivable
+		}
+	}
+
+	return inbound * 1000, nil
+}
+
+func (r *LndNode) ConnectPeer(pubKey string, host string) error {
+	if r.client == nil {
+		return errors.Errorf("Node not started")
+	}
+
+	ctx := context.Background()
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+
+	_, err := r.client.ConnectPeer(ctx, &lnrpc.ConnectPeerRequest{
+		Addr: &lnrpc.LightningAddress{
+			Pubkey: pubKey,
+			Host:   host,
+		},
+		// reconnect whenever the connection drops
+		Perm: true,
+	})
+	if err != nil {
+		return errors.Errorf("Could not connect to peer: %v", err)
+	}
+
+	return nil
+}
+
+func (r *LndNode) ListPeers() ([]*Peer, error) {
+	if r.client == nil {
+		return nil, errors.Errorf("Node not started")
+	}
+
+	ctx := context.Background()
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+
+	res, err := r.client.ListPeers(ctx, &lnrpc.ListPeersRequest{})
+	if err != nil {
+		return nil, errors.Errorf("Could not list peers: %v", err)
+	}
+
+	peers := make([]*Peer, 0, len(res.Peers))
+
+	for _, peer := range res.Peers {
+		peers = append(peers, &Peer{
+			PubKey:   peer.PubKey,
+			Address:  peer.Address,
+			Inbound:  peer.Inbound,
+			PingTime: peer.PingTime,
+		})
+	}
+
+	return peers, nil
+}
+
+func (r *LndNode) OpenChannel(req *OpenChannelRequest) (string, error) {
+	if r.client == nil {
+		return "", errors.Errorf("Node not started")
+	}
+
+	pubKey, err := hex.DecodeString(req.PubKey)
+	if err != nil {
+		return "", errors.Errorf("Invalid public key %s", req.PubKey)
+	}
+
+	if req.Amount <= 0 {
+		return "", errors.Errorf("Amount must be positive")
+	}
+
+	ctx := context.Background()
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+
+	channelPoint, err := r.client.OpenChannelSync(ctx, &lnrpc.OpenChannelRequest{
+		NodePubkey:         pubKey,
+		LocalFundingAmount: req.Amount,
+		SatPerByte:         req.SatPerByte,
+		Private:            req.Private,
+	})
+	if err != nil {
+		return "", errors.Errorf("Could not open channel: %v", err)
+	}
+
+	var txid string
+
+	switch fundingTxid := channelPoint.FundingTxid.(type) {
+	case *lnrpc.ChannelPoint_FundingTxidBytes:
+		txid = txidString(fundingTxid.FundingTxidBytes)
+	case *lnrpc.ChannelPoint_FundingTxidStr:
+		txid = fundingTxid.FundingTxidStr
+	}
+
+	return fmt.Sprintf("%s:%d", txid, channelPoint.OutputIndex), nil
+}
+
+// ListChannels returns the open channels followed by the ones which are
+// still being opened
+func (r *LndNode) ListChannels() ([]*Channel, error) {
+	if r.client == nil {
+		return nil, errors.Errorf("Node not started")
+	}
+
+	ctx := context.Background()
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+
+	res, err := r.client.ListChannels(ctx, &lnrpc.ListChannelsRequest{})
+	if err != nil {
+		return nil, errors.Errorf("Could not list channels: %v", err)
+	}
+
+	pending, err := r.client.PendingChannels(ctx, &lnrpc.PendingChannelsRequest{})
+	if err != nil {
+		return nil, errors.Errorf("Could not list pending channels: %v", err)
+	}
+
+	channels := make([]*Channel, 0, len(res.Channels)+len(pending.PendingOpenChannels))
+
+	for _, channel := range res.Channels {
+		channels = append(channels, &Channel{
+			ChannelPoint:  channel.ChannelPoint,
+			RemotePubKey:  channel.RemotePubkey,
+			Capacity:      channel.Capacity,
+			LocalBalance:  channel.LocalBalance,
+			RemoteBalance: channel.RemoteBalance,
+			Active:        channel.Active,
+			Private:       channel.Private,
+		})
+	}
+
+	for _, channel := range pending.PendingOpenChannels {
+		if channel.Channel == nil {
+			continue
+		}
+
+		channels = append(channels, &Channel{
+			ChannelPoint:  channel.Channel.ChannelPoint,
+			RemotePubKey:  channel.Channel.RemoteNodePub,
+			Capacity:      channel.Channel.Capacity,
+			LocalBalance:  channel.Channel.LocalBalance,
+			RemoteBalance: channel.Channel.RemoteBalance,
+			Pending:       true,
+		})
+	}
+
+	return channels, nil
 }
 
-// Compile time check for protocol compatibility
-var _ Node = (*LndNode)(nil)
+// CloseChannel closes a channel cooperatively or, if forced, unilaterally
+// and returns once the closing transaction was broadcast
+func (r *LndNode) CloseChannel(channelPoint string, force bool) (string, error) {
+	if r.client == nil {
+		return "", errors.Errorf("Node not started")
+	}
 
-func NewLndNode(config *LndNodeConfig) (*LndNode, error) {
-	node := &LndNode{
-		logger:          config.Logger,
-		invoicesClients: make(map[uint32]*InvoicesClient),
-		statusClients:   make(map[uint32]*StatusClient),
-		status:          StatusStopped,
+	txid, index, err := parseChannelPoint(channelPoint)
+	if err != nil {
+		return "", err
 	}
 
-	if config.Uri != "" {
-		node.setUri(config.Uri)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+
+	updates, err := r.client.CloseChannel(ctx, &lnrpc.CloseChannelRequest{
+		ChannelPoint: &lnrpc.ChannelPoint{
+			FundingTxid: &lnrpc.ChannelPoint_FundingTxidStr{
+				FundingTxidStr: txid,
+			},
+			OutputIndex: index,
+		},
+		Force: force,
+	})
+	if err != nil {
+		return "", errors.Errorf("Could not close channel: %v", err)
 	}
 
-	if config.CertBytes != nil {
-		err := node.setTlsCredentials(config.CertBytes, false)
+	// lnd keeps closing the channel after the stream is canceled
+	for {
+		update, err := updates.Recv()
 		if err != nil {
-			return nil, errors.Errorf("unable to set certificate: %v", err)
+			return "", errors.Errorf("Could not close channel: %v", err)
 		}
-	}
 
-	if config.MacaroonBytes != nil {
-		node.setMacaroon(config.MacaroonBytes)
+		switch update := update.Update.(type) {
+		case *lnrpc.CloseStatusUpdate_ClosePending:
+			return txidString(update.ClosePending.Txid), nil
+		case *lnrpc.CloseStatusUpdate_ChanClose:
+			return txidString(update.ChanClose.ClosingTxid), nil
+		}
 	}
-
-	return node, nil
 }
 
-func (r *LndNode) setUri(uri string) {
-	r.uri = uri
-}
+func (r *LndNode) WalletBalance() (*WalletBalance, error) {
+	if r.client == nil {
+		return nil, errors.Errorf("Node not started")
+	}
 
-func (r *LndNode) setTlsCredentials(certBytes []byte, wrapped bool) error {
-	cert := x509.NewCertPool()
+	ctx := context.Background()
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
 
-	fullCertBytes := certBytes
+	wallet, err := r.client.WalletBalance(ctx, &lnrpc.WalletBalanceRequest{})
+	if err != nil {
+		return nil, errors.Errorf("Could not get wallet balance: %v", err)
+	}
 
-	if !wrapped {
-		fullCertBytes = append(beginCertificateBlock, fullCertBytes...)
-		fullCertBytes = append(fullCertBytes, endCertificateBlock...)
+	channels, err := r.client.ListChannels(ctx, &lnrpc.ListChannelsRequest{
+		ActiveOnly: true,
+	})
+	if err != nil {
+		return nil, errors.Errorf("Could not list channels: %v", err)
 	}
 
-	if ok := cert.AppendCertsFromPEM(fullCertBytes); !ok {
-		return errors.Errorf("unable to append")
+	balance := &WalletBalance{
+		Confirmed:   wallet.ConfirmedBalance,
+		Unconfirmed: wallet.UnconfirmedBalance,
 	}
 
-	r.tlsCredentials = credentials.NewClientTLSFromCert(cert, "")
+	for _, channel := range channels.Channels {
+		// our side has to keep its reserve in the channel
+		sendable := channel.LocalBalance - channel.LocalChanReserveSat
+		if sendable > 0 {
+			balance.Channels += sendable
+		}
+	}
 
-	return nil
+	return balance, nil
 }
 
-func (r *LndNode) setMacaroon(macaroonBytes []byte) {
-	hexMacaroon := hex.EncodeToString(macaroonBytes)
-	r.macaroonMetadata = metadata.Pairs("macaroon", hexMacaroon)
+func (r *LndNode) NewAddress() (string, error) {
+	if r.client == nil {
+		return "", errors.Errorf("Node not started")
+	}
+
+	ctx := context.Background()
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+
+	res, err := r.client.NewAddress(ctx, &lnrpc.NewAddressRequest{
+		Type: lnrpc.AddressType_WITNESS_PUBKEY_HASH,
+	})
+	if err != nil {
+		return "", errors.Errorf("Could not create address: %v", err)
+	}
+
+	return res.Address, nil
 }
 
-func (r *LndNode) Start() error {
-	var err error
+func (r *LndNode) SendCoins(req *SendCoinsRequest) (string, error) {
+	if r.client == nil {
+		return "", errors.Errorf("Node not started")
+	}
 
-	r.logger.Infof("starting %s", r.uri)
+	if !req.SendAll && req.Amount <= 0 {
+		return "", errors.Errorf("Amount must be positive")
+	}
+
+	ctx := context.Background()
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+
+	sendReq := &lnrpc.SendCoinsRequest{
+		Addr:       req.Address,
+		SatPerByte: req.SatPerByte,
+		SendAll:    req.SendAll,
+	}
+
+	if !req.SendAll {
+		sendReq.Amount = req.Amount
+	}
 
-	r.conn, err = grpc.Dial(r.uri, grpc.WithTransportCredentials(r.tlsCredentials))
+	res, err := r.client.SendCoins(ctx, sendReq)
 	if err != nil {
-		return errors.Errorf("Could not connect to lightning node: %v", err)
+		return "", errors.Errorf("Could not send coins: %v", err)
 	}
 
-	r.client = lnrpc.NewLightningClient(r.conn)
-	r.unlocker = lnrpc.NewWalletUnlockerClient(r.conn)
+	return res.Txid, nil
+}
+
+func (r *LndNode) PayInvoice(paymentRequest string, msat int64, maxFeeSat int64) (string, error) {
+	if r.client == nil {
+		return "", errors.Errorf("Node not started")
+	}
 
 	ctx := context.Background()
 	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
-	info, err := r.client.GetInfo(ctx, &lnrpc.GetInfoRequest{})
+
+	// never pay more than was asked for, even if the invoice says so
+	payReq, err := r.client.DecodePayReq(ctx, &lnrpc.PayReqString{
+		PayReq: paymentRequest,
+	})
 	if err != nil {
-		// try to unlock to find out whether there's an existing wallet
-		_, err = r.unlocker.UnlockWallet(ctx, &lnrpc.UnlockWalletRequest{WalletPassword: []byte{}})
-		if status, ok := status.FromError(err); err != nil && ok {
-			if status.Message() == "wallet not found" {
-				r.updateStatus(StatusUninitialized)
-				return nil
-			}
-		}
+		return "", errors.Errorf("Could not decode invoice: %v", err)
+	}
 
-		r.updateStatus(StatusLocked)
-		return nil
+	if payReq.NumMsat != msat {
+		return "", errors.Errorf("Invoice is for %d msat instead of %d msat", payReq.NumMsat, msat)
 	}
 
-	if info.SyncedToChain {
-		r.updateStatus(StatusStarted)
-	} else {
-		r.updateStatus(StatusStarted)
+	res, err := r.client.SendPaymentSync(ctx, &lnrpc.SendRequest{
+		PaymentRequest: paymentRequest,
+		FeeLimit: &lnrpc.FeeLimit{
+			Limit: &lnrpc.FeeLimit_Fixed{
+				Fixed: maxFeeSat,
+			},
+		},
+	})
+	if err != nil {
+		return "", errors.Errorf("Could not pay invoice: %v", err)
 	}
 
-	go r.run()
+	if res.PaymentError != "" {
+		return "", errors.Errorf("Could not pay invoice: %s", res.PaymentError)
+	}
 
-	return nil
+	return payReq.PaymentHash, nil
 }
 
-func (r *LndNode) run() {
+func (r *LndNode) BakeMacaroon(permissions []MacaroonPermission, expiry time.Duration) ([]byte, error) {
+	if r.client == nil {
+		return nil, errors.Errorf("Node not started")
+	}
+
+	if len(permissions) == 0 {
+		return nil, errors.Errorf("At least one permission is required")
+	}
+
 	ctx := context.Background()
 	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
 
-	invoices, err := r.client.SubscribeInvoices(ctx, &lnrpc.InvoiceSubscription{})
+	req := &lnrpc.BakeMacaroonRequest{}
+	for _, permission := range permissions {
+		req.Permissions = append(req.Permissions, &lnrpc.MacaroonPermission{
+			Entity: permission.Entity,
+			Action: permission.Action,
+		})
+	}
+
+	res, err := r.client.BakeMacaroon(ctx, req)
 	if err != nil {
-		r.logger.Errorf("Could not subscribe to invoices: %v", err)
-		return
+		return nil, errors.Errorf("Could not bake macaroon: %v", err)
 	}
 
-	for {
-		invoice, err := invoices.Recv()
-		if err == io.EOF {
-			r.logger.Errorf("Got EOF from invoices stream: %v", err)
-			time.Sleep(1 * time.Second)
-			continue
-		}
+	macaroonBytes, err := hex.DecodeString(res.Macaroon)
+	if err != nil {
+		return nil, errors.Errorf("Could not decode macaroon: %v", err)
+	}
 
-		if err != nil {
-			errStatus, ok := status.FromError(err)
-			if !ok {
-				r.logger.Errorf("Could not get status from err: %v", err)
-			}
+	if expiry <= 0 {
+		return macaroonBytes, nil
+	}
 
-			if errStatus.Code() == 1 {
-				r.logger.Infof("Stopping invoice listener")
-				break
-			} else if err != nil {
-				r.logger.Errorf("Failed receiving subscription items: %v", err)
-				break
-			}
+	mac := &macaroon.Macaroon{}
+	err = mac.UnmarshalBinary(macaroonBytes)
+	if err != nil {
+		return nil, errors.Errorf("Could not decode macaroon: %v", err)
+	}
+
+	// the caveat has a precision of seconds, so round up
+	seconds := int64((expiry + time.Second - 1) / time.Second)
+
+	mac, err = macaroons.AddConstraints(mac, macaroons.TimeoutConstraint(seconds))
+	if err != nil {
+		return nil, errors.Errorf("Could not add expiry: %v", err)
+	}
+
+	return mac.MarshalBinary()
+}
+
+// probeMacaroon tells the permissions of the macaroon sent along with ctx
+// by calls that are either read only or fail without side effects once
+// the macaroon was accepted
+func probeMacaroon(ctx context.Context, conn *grpc.ClientConn) (*MacaroonPermissions, error) {
+	client := lnrpc.NewLightningClient(conn)
+	invoices := invoicesrpc.NewInvoicesClient(conn)
+
+	permissions := &MacaroonPermissions{}
+
+	probes := []struct {
+		permission string
+		granted    *bool
+		call       func() error
+	}{
+		{"invoices:read", &permissions.ReadInvoices, func() error {
+			_, err := client.ListInvoices(ctx, &lnrpc.ListInvoiceRequest{NumMaxInvoices: 1})
+			return err
+		}},
+		{"invoices:write", &permissions.WriteInvoices, func() error {
+			// no invoice has an all zero payment hash
+			_, err := invoices.CancelInvoice(ctx, &invoicesrpc.CancelInvoiceMsg{PaymentHash: make([]byte, 32)})
+			return err
+		}},
+		{"offchain:write", &permissions.Admin, func() error {
+			// a payment without destination or payment request is
+			// rejected before anything is sent
+			_, err := client.SendPaymentSync(ctx, &lnrpc.SendRequest{})
+			return err
+		}},
+		{"onchain:write", &permissions.Admin, func() error {
+			// an empty address can't be decoded, so nothing is sent
+			_, err := client.SendCoins(ctx, &lnrpc.SendCoinsRequest{})
+			return err
+		}},
+	}
+
+	for _, probe := range probes {
+		err := probe.call()
+		if err != nil && isPermissionError(err) {
+			continue
 		}
 
-		for _, client := range r.invoicesClients {
-			client.Invoices <- &Invoice{
-				RHash:          hex.EncodeToString(invoice.RHash),
-				PaymentRequest: invoice.PaymentRequest,
-				MSat:           invoice.Value,
-				Settled:        invoice.Settled,
-				Memo:           invoice.Memo,
-			}
+		switch status.Code(err) {
+		case codes.Unavailable, codes.DeadlineExceeded:
+			return nil, errors.Errorf("Could not reach lightning node: %v", err)
+		case codes.Unimplemented:
+			// the sub-server serving the call isn't compiled into lnd
+			permissions.Unknown = append(permissions.Unknown, probe.permission)
+			continue
 		}
+
+		*probe.granted = true
 	}
+
+	return permissions, nil
 }
 
-func (r *LndNode) Stop() error {
-	r.updateStatus(StatusStopped)
+// Test dials the node without starting it and checks its certificate,
+// macaroon and network
+func (r *LndNode) Test() *Diagnosis {
+	diagnosis := &Diagnosis{}
 
-	if r.conn != nil {
-		err := r.conn.Close()
-		if err != nil {
-			return errors.Errorf("Could not close connection: %v", err)
+	conn, err := r.dial()
+	if err != nil {
+		return diagnosis.fail(ProblemUnreachable, err)
+	}
+
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout())
+	defer cancel()
+
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+
+	client := lnrpc.NewLightningClient(conn)
+
+	info, err := client.GetInfo(ctx, &lnrpc.GetInfoRequest{})
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+		// only the wallet unlocker is served while the wallet is locked
+		diagnosis.Reachable = true
+		diagnosis.warn("wallet is locked, macaroon and network could not be verified")
+		return diagnosis
+	case err != nil && isPermissionError(err) && !isAuthError(err):
+		// invoice macaroons can't read node info
+		diagnosis.Reachable = true
+		diagnosis.warn("macaroon can't read node info, network could not be verified")
+	case err != nil:
+		return diagnosis.fail(lndProblem(err), err)
+	default:
+		diagnosis.Reachable = true
+		diagnosis.PubKey = info.IdentityPubkey
+		diagnosis.Alias = info.Alias
+
+		for _, chain := range info.Chains {
+			if chain.Chain == "bitcoin" {
+				diagnosis.Network = chain.Network
+			}
+		}
+
+		if !onNetwork(info, r.network) {
+			return diagnosis.fail(ProblemWrongChain, errors.Errorf("Node is not on bitcoin %s", r.network))
 		}
 	}
 
-	r.closeAllInvoiceSubscriptions()
+	permissions, err := probeMacaroon(ctx, conn)
+	if err != nil {
+		return diagnosis.fail(lndProblem(err), err)
+	}
 
-	return nil
+	diagnosis.checkPermissions(permissions)
+
+	return diagnosis
 }
 
-func (r *LndNode) GetInvoice(rHash string) (*Invoice, error) {
+// lndProblem classifies a failed call to lnd
+func lndProblem(err error) Problem {
+	switch {
+	case isTlsError(err):
+		return ProblemTls
+	case isAuthError(err):
+		return ProblemAuth
+	case status.Code(err) == codes.Unavailable, status.Code(err) == codes.DeadlineExceeded:
+		return ProblemUnreachable
+	default:
+		return ProblemUnexpected
+	}
+}
+
+func (r *LndNode) AddInvoice(req *InvoiceRequest) (*Invoice, error) {
 	if r.client == nil {
 		return nil, errors.Errorf("Node not started")
 	}
@@ -214,47 +1320,124 @@ func (r *LndNode) GetInvoice(rHash string) (*Invoice, error) {
 	ctx := context.Background()
 	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
 
-	res, err := r.client.LookupInvoice(ctx, &lnrpc.PaymentHash{
-		RHashStr: rHash,
+	if req.Hold {
+		invoice, err := r.addHoldInvoice(ctx, req)
+		if err == nil {
+			return invoice, nil
+		}
+
+		if status.Code(err) != codes.Unimplemented {
+			return nil, errors.Errorf("Could not add hold invoice: %v", err)
+		}
+
+		// fall back to regular invoices if lnd was built without the
+		// invoices sub-server
+		r.logger.Errorf("Hold invoices not supported, adding regular invoice")
+	}
+
+	res, err := r.client.AddInvoice(ctx, &lnrpc.Invoice{
+		Memo:      req.Memo,
+		ValueMsat: req.MSat,
 	})
 	if err != nil {
-		return nil, errors.Errorf("Could not find invoice: %v", err)
+		return nil, errors.Errorf("Could not add invoice: %v", err)
 	}
 
 	return &Invoice{
-		Settled:        res.Settled,
+		Settled:        false,
 		RHash:          hex.EncodeToString(res.RHash),
 		PaymentRequest: res.PaymentRequest,
-		Memo:           res.Memo,
-		MSat:           res.Value,
+		Memo:           req.Memo,
+		MSat:           req.MSat,
 	}, nil
 }
 
-func (r *LndNode) AddInvoice(req *InvoiceRequest) (*Invoice, error) {
-	if r.client == nil {
-		return nil, errors.Errorf("Node not started")
+// addHoldInvoice returns the unwrapped grpc error, so callers can tell
+// whether the invoices sub-server is available
+func (r *LndNode) addHoldInvoice(ctx context.Context, req *InvoiceRequest) (*Invoice, error) {
+	preimage := make([]byte, 32)
+
+	_, err := rand.Read(preimage)
+	if err != nil {
+		return nil, err
 	}
 
-	ctx := context.Background()
-	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+	hash := sha256.Sum256(preimage)
 
-	res, err := r.client.AddInvoice(ctx, &lnrpc.Invoice{
-		Memo:  "Candy for 8 satoshis",
-		Value: 8,
+	res, err := r.invoices.AddHoldInvoice(ctx, &invoicesrpc.AddHoldInvoiceRequest{
+		Memo:      req.Memo,
+		Hash:      hash[:],
+		ValueMsat: req.MSat,
 	})
 	if err != nil {
-		return nil, errors.Errorf("Could not add invoice: %v", err)
+		return nil, err
 	}
 
+	go r.watchHoldInvoice(r.done, hash[:])
+
 	return &Invoice{
 		Settled:        false,
-		RHash:          hex.EncodeToString(res.RHash),
+		RHash:          hex.EncodeToString(hash[:]),
 		PaymentRequest: res.PaymentRequest,
 		Memo:           req.Memo,
 		MSat:           req.MSat,
+		Preimage:       hex.EncodeToString(preimage),
 	}, nil
 }
 
+func (r *LndNode) SupportsHoldInvoices() bool {
+	return true
+}
+
+// SettleInvoice settles an accepted hold invoice with its preimage
+func (r *LndNode) SettleInvoice(preimage string) error {
+	if r.invoices == nil {
+		return errors.Errorf("Node not started")
+	}
+
+	preimageBytes, err := hex.DecodeString(preimage)
+	if err != nil {
+		return errors.Errorf("Could not decode preimage: %v", err)
+	}
+
+	ctx := context.Background()
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+
+	_, err = r.invoices.SettleInvoice(ctx, &invoicesrpc.SettleInvoiceMsg{
+		Preimage: preimageBytes,
+	})
+	if err != nil {
+		return errors.Errorf("Could not settle invoice: %v", err)
+	}
+
+	return nil
+}
+
+// CancelInvoice cancels a hold invoice, which returns the held payment
+// to the payer
+func (r *LndNode) CancelInvoice(rHash string) error {
+	if r.invoices == nil {
+		return errors.Errorf("Node not started")
+	}
+
+	hash, err := hex.DecodeString(rHash)
+	if err != nil {
+		return errors.Errorf("Could not decode payment hash: %v", err)
+	}
+
+	ctx := context.Background()
+	ctx = metadata.NewOutgoingContext(ctx, r.macaroonMetadata)
+
+	_, err = r.invoices.CancelInvoice(ctx, &invoicesrpc.CancelInvoiceMsg{
+		PaymentHash: hash,
+	})
+	if err != nil {
+		return errors.Errorf("Could not cancel invoice: %v", err)
+	}
+
+	return nil
+}
+
 func (r *LndNode) SubscribeInvoices() (*InvoicesClient, error) {
 	client := &InvoicesClient{
 		Invoices:   make(chan *Invoice),
@@ -267,19 +1450,31 @@ func (r *LndNode) SubscribeInvoices() (*InvoicesClient, error) {
 	r.nextInvoicesClient.id++
 	r.nextInvoicesClient.Unlock()
 
+	r.clientsMu.Lock()
 	r.invoicesClients[client.Id] = client
+	r.clientsMu.Unlock()
 
 	return client, nil
 }
 
 func (r *LndNode) closeAllInvoiceSubscriptions() {
+	r.clientsMu.Lock()
+	clients := make([]*InvoicesClient, 0, len(r.invoicesClients))
 	for _, client := range r.invoicesClients {
+		clients = append(clients, client)
+	}
+	r.clientsMu.Unlock()
+
+	for _, client := range clients {
 		client.Cancel()
 	}
 }
 
 func (r *LndNode) unsubscribeInvoices(client *InvoicesClient) {
+	r.clientsMu.Lock()
 	delete(r.invoicesClients, client.Id)
+	r.clientsMu.Unlock()
+
 	close(client.cancelChan)
 }
 
@@ -299,12 +1494,31 @@ func (r *LndNode) GenerateSeed() ([]string, error) {
 }
 
 func (r *LndNode) Init(password string, mnemonic []string) error {
-	client := lnrpc.NewWalletUnlockerClient(r.conn)
+	return r.initWallet(&lnrpc.InitWalletRequest{
+		WalletPassword:     []byte(password),
+		CipherSeedMnemonic: mnemonic,
+	})
+}
 
-	_, err := client.InitWallet(context.Background(), &lnrpc.InitWalletRequest{
+// InitFromBackup creates the wallet from an existing seed and recovers the
+// funds of the channels in a multi channel backup
+func (r *LndNode) InitFromBackup(password string, mnemonic []string, backup []byte) error {
+	return r.initWallet(&lnrpc.InitWalletRequest{
 		WalletPassword:     []byte(password),
 		CipherSeedMnemonic: mnemonic,
+		RecoveryWindow:     lndRecoveryWindow,
+		ChannelBackups: &lnrpc.ChanBackupSnapshot{
+			MultiChanBackup: &lnrpc.MultiChanBackup{
+				MultiChanBackup: backup,
+			},
+		},
 	})
+}
+
+func (r *LndNode) initWallet(req *lnrpc.InitWalletRequest) error {
+	client := lnrpc.NewWalletUnlockerClient(r.conn)
+
+	_, err := client.InitWallet(context.Background(), req)
 	if status, ok := status.FromError(err); err != nil && ok {
 		if status.Message() == "wallet already exists" {
 			return errors.New("wallet already exists")
@@ -315,6 +1529,8 @@ func (r *LndNode) Init(password string, mnemonic []string) error {
 
 	r.updateStatus(StatusStarted)
 
+	r.watch()
+
 	return nil
 }
 
@@ -330,10 +1546,14 @@ func (r *LndNode) Unlock(password string) error {
 
 	r.updateStatus(StatusStarted)
 
+	r.watch()
+
 	return nil
 }
 
-func (r *LndNode) Restore() error {
+// Restore recovers the funds of the channels in a multi channel backup
+// into the running wallet
+func (r *LndNode) Restore(backup []byte) error {
 	if r.client == nil {
 		return errors.Errorf("Node not started")
 	}
@@ -343,7 +1563,7 @@ func (r *LndNode) Restore() error {
 
 	_, err := r.client.RestoreChannelBackups(ctx, &lnrpc.RestoreChanBackupRequest{
 		Backup: &lnrpc.RestoreChanBackupRequest_MultiChanBackup{
-			MultiChanBackup: []byte{},
+			MultiChanBackup: backup,
 		},
 	})
 	if err != nil {
@@ -354,20 +1574,41 @@ func (r *LndNode) Restore() error {
 }
 
 func (r *LndNode) updateStatus(status Status) {
+	r.clientsMu.Lock()
+	defer r.clientsMu.Unlock()
+
+	r.publishStatus(status)
+}
+
+// transitionStatus only publishes the status if it changed
+func (r *LndNode) transitionStatus(status Status) {
+	r.clientsMu.Lock()
+	defer r.clientsMu.Unlock()
+
+	if r.status != status {
+		r.publishStatus(status)
+	}
+}
+
+// publishStatus has to be called with clientsMu held
+func (r *LndNode) publishStatus(status Status) {
 	r.status = status
 
 	for _, client := range r.statusClients {
-		client.Status <- status
+		client.publish(status)
 	}
 }
 
 func (r *LndNode) Status() Status {
+	r.clientsMu.Lock()
+	defer r.clientsMu.Unlock()
+
 	return r.status
 }
 
 func (r *LndNode) SubscribeStatus() *StatusClient {
 	client := &StatusClient{
-		Status:     make(chan Status),
+		Status:     make(chan Status, 1),
 		cancelChan: make(chan struct{}),
 		node:       r,
 	}
@@ -377,12 +1618,17 @@ func (r *LndNode) SubscribeStatus() *StatusClient {
 	r.nextStatusClient.id++
 	r.nextStatusClient.Unlock()
 
+	r.clientsMu.Lock()
 	r.statusClients[client.Id] = client
+	r.clientsMu.Unlock()
 
 	return client
 }
 
 func (r *LndNode) unsubscribeStatus(client *StatusClient) {
+	r.clientsMu.Lock()
 	delete(r.statusClients, client.Id)
+	r.clientsMu.Unlock()
+
 	close(client.cancelChan)
 }
@@ -0,0 +1,40 @@
+package lightning
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNextBackoff(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 2*time.Second, nextBackoff(lndMinBackoff))
+	assert.Equal(t, 4*time.Second, nextBackoff(2*time.Second))
+	assert.Equal(t, lndMaxBackoff, nextBackoff(90*time.Second))
+	assert.Equal(t, lndMaxBackoff, nextBackoff(lndMaxBackoff))
+}
+
+func TestStatusDoesNotBlock(t *testing.T) {
+	t.Parallel()
+
+	node, err := NewLndNode(&LndNodeConfig{})
+	assert.Equal(t, nil, err)
+
+	client := node.SubscribeStatus()
+	defer client.Cancel()
+
+	// a subscriber that doesn't receive only gets the latest status
+	node.updateStatus(StatusStarted)
+	node.transitionStatus(StatusDegraded)
+	node.transitionStatus(StatusDegraded)
+
+	assert.Equal(t, StatusDegraded, node.Status())
+	assert.Equal(t, StatusDegraded, <-client.Status)
+
+	select {
+	case status := <-client.Status:
+		t.Fatalf("unexpected status %v", status)
+	default:
+	}
+}
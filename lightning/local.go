@@ -13,6 +13,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 //var sizeRegexp = regexp.MustCompile("BTCN: Processed 33870 blocks in the last 10.43s (height 267301, 2013-11-01 15:49:51 +0100 CET)")
@@ -26,10 +27,157 @@ var walletOpenedRegexp = regexp.MustCompile(`LNWL: Opened wallet`)
 //var sizeRegexp = regexp.MustCompile("LTND: Waiting for wallet encryption password")
 //var sizeRegexp = regexp.MustCompile("RPCS: Done generating TLS certificates")
 
+// localExitTimeout is how long lnd is waited for to exit once killed
+const localExitTimeout = 30 * time.Second
+
+// neutrinoPeers serve compact block filters for the light client
+var neutrinoPeers = map[Network]string{
+	NetworkMainnet: "btcd-mainnet.lightning.computer:8333",
+	NetworkTestnet: "btcd-testnet.lightning.computer:18333",
+}
+
+// BackendKind is the chain backend the embedded lnd gets blocks from
+type BackendKind string
+
+const (
+	BackendNeutrino BackendKind = "neutrino"
+	BackendBitcoind BackendKind = "bitcoind"
+	BackendBtcd     BackendKind = "btcd"
+)
+
+// Backend configures how the embedded lnd follows the chain
+type Backend struct {
+	Kind BackendKind
+	// Peers neutrino connects to, defaults to a known peer of the network
+	Peers []string
+	// RpcHost of a bitcoind or btcd node, like 10.0.0.2:8332
+	RpcHost string
+	RpcUser string
+	RpcPass string
+	// ZmqPubRawBlock and ZmqPubRawTx are the endpoints bitcoind
+	// publishes blocks and transactions on, like tcp://10.0.0.2:28332
+	ZmqPubRawBlock string
+	ZmqPubRawTx    string
+}
+
+// ValidateBackend makes sure that lnd can be started with a backend
+func ValidateBackend(backend *Backend) error {
+	switch backend.Kind {
+	case BackendNeutrino:
+	case BackendBitcoind:
+		if backend.RpcHost == "" {
+			return errors.Errorf("bitcoind needs an rpc host")
+		}
+
+		if backend.ZmqPubRawBlock == "" || backend.ZmqPubRawTx == "" {
+			return errors.Errorf("bitcoind needs zmq endpoints for blocks and transactions")
+		}
+	case BackendBtcd:
+		if backend.RpcHost == "" {
+			return errors.Errorf("btcd needs an rpc host")
+		}
+	default:
+		return errors.Errorf("unknown backend %s", backend.Kind)
+	}
+
+	return nil
+}
+
+// ValidateLocalNetwork makes sure that the embedded lnd can follow the
+// chain of a network with a backend
+func ValidateLocalNetwork(network Network, backend *Backend) error {
+	switch network {
+	case NetworkSignet:
+		// signet is only supported by later versions of lnd
+		return errors.Errorf("local nodes don't support signet")
+	case NetworkRegtest:
+		// there are no known neutrino peers for regtest
+		if backend == nil || (backend.Kind == BackendNeutrino && len(backend.Peers) == 0) {
+			return errors.Errorf("regtest needs neutrino peers or a bitcoind or btcd backend")
+		}
+	}
+
+	return nil
+}
+
+// backendArgs returns the lnd flags for a backend, which is neutrino with
+// a known peer if none is configured
+func backendArgs(backend *Backend, network Network) []string {
+	if backend == nil {
+		backend = &Backend{Kind: BackendNeutrino}
+	}
+
+	args := []string{"--bitcoin.node", string(backend.Kind)}
+
+	switch backend.Kind {
+	case BackendNeutrino:
+		peers := backend.Peers
+		if len(peers) == 0 {
+			if peer, ok := neutrinoPeers[network]; ok {
+				peers = []string{peer}
+			}
+		}
+
+		for _, peer := range peers {
+			args = append(args, "--neutrino.connect", peer)
+		}
+	case BackendBitcoind:
+		args = append(args, "--bitcoind.rpchost", backend.RpcHost)
+		args = append(args, "--bitcoind.rpcuser", backend.RpcUser)
+		args = append(args, "--bitcoind.rpcpass", backend.RpcPass)
+		args = append(args, "--bitcoind.zmqpubrawblock", backend.ZmqPubRawBlock)
+		args = append(args, "--bitcoind.zmqpubrawtx", backend.ZmqPubRawTx)
+	case BackendBtcd:
+		args = append(args, "--btcd.rpchost", backend.RpcHost)
+		args = append(args, "--btcd.rpcuser", backend.RpcUser)
+		args = append(args, "--btcd.rpcpass", backend.RpcPass)
+	}
+
+	return args
+}
+
+// TorConfig points lnd to a running Tor instance
+type TorConfig struct {
+	// SocksAddress of the proxy for outgoing connections
+	SocksAddress string
+	// ControlAddress used for creating the onion service of the p2p port
+	ControlAddress string
+}
+
+// torArgs returns the lnd flags which route all peer connections through
+// Tor and make lnd reachable only by its own onion address
+func torArgs(tor *TorConfig) []string {
+	if tor == nil {
+		return nil
+	}
+
+	return []string{
+		"--tor.active",
+		"--tor.socks", tor.SocksAddress,
+		"--tor.control", tor.ControlAddress,
+		"--tor.v3",
+		"--tor.streamisolation",
+		"--listen", "localhost",
+	}
+}
+
 type LocalNodeConfig struct {
 	DataDir  string
 	Logger   Logger
 	OnionSvc *onion.Service
+	// SettleIndex of the last processed invoice
+	SettleIndex uint64
+	// Network lnd runs on, defaults to mainnet
+	Network Network
+	// Backend lnd follows the chain with, defaults to neutrino
+	Backend *Backend
+	// Tor is optional and keeps all peer connections off clearnet
+	Tor *TorConfig
+	// AutoUnlock returns the wallet password if it should be unlocked
+	// on start
+	AutoUnlock func() (string, error)
+	// OnChannelBackup is called with the latest multi channel backup
+	OnChannelBackup func(backup []byte)
 }
 
 type LocalNode struct {
@@ -38,9 +186,13 @@ type LocalNode struct {
 	log           Logger
 	version       string
 	cmd           *exec.Cmd
+	exited        chan struct{}
 	grpcPort      int
 	rpcPort       int
 	onionSvc      *onion.Service
+	network       Network
+	backend       *Backend
+	tor           *TorConfig
 	cert          string
 	adminMacaroon string
 }
@@ -67,8 +219,22 @@ func NewLocalNode(config *LocalNodeConfig) (*LocalNode, error) {
 
 	log.Infof("using lnd version %s", version)
 
+	network := config.Network
+	if network == "" {
+		network = NetworkMainnet
+	}
+
+	err = ValidateLocalNetwork(network, config.Backend)
+	if err != nil {
+		return nil, err
+	}
+
 	lndNode, err := NewLndNode(&LndNodeConfig{
-		Logger: log,
+		Logger:          log,
+		SettleIndex:     config.SettleIndex,
+		Network:         network,
+		AutoUnlock:      config.AutoUnlock,
+		OnChannelBackup: config.OnChannelBackup,
 	})
 	if err != nil {
 		return nil, errors.Errorf("unable to create lnd node: %v", err)
@@ -80,22 +246,38 @@ func NewLocalNode(config *LocalNodeConfig) (*LocalNode, error) {
 		log:      log,
 		version:  version,
 		onionSvc: config.OnionSvc,
+		network:  network,
+		backend:  config.Backend,
+		tor:      config.Tor,
 	}, nil
 }
 
+// Backend returns the configured chain backend or nil for the default
+func (n *LocalNode) Backend() *Backend {
+	return n.backend
+}
+
+// SetBackend changes the chain backend, which is used on the next start
+func (n *LocalNode) SetBackend(backend *Backend) {
+	n.backend = backend
+}
+
+// macaroonPath is where lnd stores the admin macaroon of the network
+func (n *LocalNode) macaroonPath() string {
+	return filepath.Join(n.dataDir, "data/chain/bitcoin", string(n.network), "admin.macaroon")
+}
+
 func (n *LocalNode) Start() error {
 	var args []string
 
 	args = append(args, "--lnddir", n.dataDir)
 	args = append(args, "--bitcoin.active")
-	args = append(args, "--bitcoin.mainnet")
-	args = append(args, "--bitcoin.node", "neutrino")
-	args = append(args, "--neutrino.connect", "btcd-mainnet.lightning.computer:8333")
+	args = append(args, "--bitcoin."+string(n.network))
+	args = append(args, backendArgs(n.backend, n.network)...)
 	args = append(args, "--tlsextradomain", n.Uri())
-	//args = append(args, "--listen", "127.0.0.1:0")
+	args = append(args, torArgs(n.tor)...)
 	//args = append(args, "--rpclisten", "127.0.0.1:0")
 	//args = append(args, "--restlisten", "0.0.0.0:8080")
-	// TODO(davidknezic) add tor support
 
 	n.cmd = exec.Command("lnd", args...)
 
@@ -145,7 +327,7 @@ func (n *LocalNode) Start() error {
 			}
 
 			if matches := walletOpenedRegexp.FindStringSubmatch(text); len(matches) == 1 {
-				adminMacaroonBytes, err := ioutil.ReadFile(filepath.Join(n.dataDir, "data/chain/bitcoin/mainnet/admin.macaroon"))
+				adminMacaroonBytes, err := ioutil.ReadFile(n.macaroonPath())
 				if err != nil {
 					n.log.Errorf("unable to read macaroon: %v", err)
 				} else {
@@ -178,7 +360,12 @@ func (n *LocalNode) Start() error {
 		return errors.Errorf("unable to start: %v", err)
 	}
 
+	exited := make(chan struct{})
+	n.exited = exited
+
 	go func() {
+		defer close(exited)
+
 		err := n.cmd.Wait()
 		if err != nil {
 			n.log.Errorf("exited with error: %v", err)
@@ -208,7 +395,7 @@ func (n *LocalNode) Start() error {
 
 	n.setUri(fmt.Sprintf("localhost:%d", n.grpcPort))
 
-	adminMacaroonBytes, err := ioutil.ReadFile(filepath.Join(n.dataDir, "data/chain/bitcoin/mainnet/admin.macaroon"))
+	adminMacaroonBytes, err := ioutil.ReadFile(n.macaroonPath())
 	n.setMacaroon(adminMacaroonBytes)
 	n.adminMacaroon = base64.StdEncoding.EncodeToString(adminMacaroonBytes)
 
@@ -234,11 +421,19 @@ func (n *LocalNode) Stop() error {
 		return errors.Errorf("unable to stop: %v", err)
 	}
 
-	if n.cmd.Process != nil {
+	if n.cmd != nil && n.cmd.Process != nil {
 		err = n.cmd.Process.Kill()
 		if err != nil {
 			return errors.Errorf("unable to kill process: %v", err)
 		}
+
+		// the database lock and ports are only released once lnd exited,
+		// which has to happen before it can be started again
+		select {
+		case <-n.exited:
+		case <-time.After(localExitTimeout):
+			return errors.Errorf("process did not exit within %v", localExitTimeout)
+		}
 	}
 
 	return nil
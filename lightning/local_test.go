@@ -0,0 +1,89 @@
+package lightning
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBackendArgsNeutrino(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{
+		"--bitcoin.node", "neutrino",
+		"--neutrino.connect", "btcd-mainnet.lightning.computer:8333",
+	}, backendArgs(nil, NetworkMainnet))
+
+	assert.Equal(t, []string{
+		"--bitcoin.node", "neutrino",
+	}, backendArgs(nil, NetworkRegtest))
+
+	assert.Equal(t, []string{
+		"--bitcoin.node", "neutrino",
+		"--neutrino.connect", "10.0.0.2:18333",
+		"--neutrino.connect", "10.0.0.3:18333",
+	}, backendArgs(&Backend{
+		Kind:  BackendNeutrino,
+		Peers: []string{"10.0.0.2:18333", "10.0.0.3:18333"},
+	}, NetworkTestnet))
+}
+
+func TestBackendArgsBitcoind(t *testing.T) {
+	t.Parallel()
+
+	backend := &Backend{
+		Kind:           BackendBitcoind,
+		RpcHost:        "10.0.0.2:8332",
+		RpcUser:        "user",
+		RpcPass:        "pass",
+		ZmqPubRawBlock: "tcp://10.0.0.2:28332",
+		ZmqPubRawTx:    "tcp://10.0.0.2:28333",
+	}
+
+	assert.Equal(t, nil, ValidateBackend(backend))
+	assert.Equal(t, []string{
+		"--bitcoin.node", "bitcoind",
+		"--bitcoind.rpchost", "10.0.0.2:8332",
+		"--bitcoind.rpcuser", "user",
+		"--bitcoind.rpcpass", "pass",
+		"--bitcoind.zmqpubrawblock", "tcp://10.0.0.2:28332",
+		"--bitcoind.zmqpubrawtx", "tcp://10.0.0.2:28333",
+	}, backendArgs(backend, NetworkMainnet))
+
+	backend.ZmqPubRawTx = ""
+	assert.NotEqual(t, nil, ValidateBackend(backend))
+	assert.NotEqual(t, nil, ValidateBackend(&Backend{Kind: "electrum"}))
+}
+
+func TestTorArgs(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 0, len(torArgs(nil)))
+	assert.Equal(t, []string{
+		"--tor.active",
+		"--tor.socks", "127.0.0.1:9050",
+		"--tor.control", "127.0.0.1:9051",
+		"--tor.v3",
+		"--tor.streamisolation",
+		"--listen", "localhost",
+	}, torArgs(&TorConfig{
+		SocksAddress:   "127.0.0.1:9050",
+		ControlAddress: "127.0.0.1:9051",
+	}))
+}
+
+func TestValidateLocalNetwork(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, nil, ValidateLocalNetwork(NetworkMainnet, nil))
+	assert.Equal(t, nil, ValidateLocalNetwork(NetworkTestnet, nil))
+	assert.NotEqual(t, nil, ValidateLocalNetwork(NetworkSignet, nil))
+
+	// regtest has no default neutrino peer
+	assert.NotEqual(t, nil, ValidateLocalNetwork(NetworkRegtest, nil))
+	assert.NotEqual(t, nil, ValidateLocalNetwork(NetworkRegtest, &Backend{Kind: BackendNeutrino}))
+	assert.Equal(t, nil, ValidateLocalNetwork(NetworkRegtest, &Backend{
+		Kind:  BackendNeutrino,
+		Peers: []string{"10.0.0.2:18444"},
+	}))
+	assert.Equal(t, nil, ValidateLocalNetwork(NetworkRegtest, &Backend{Kind: BackendBtcd, RpcHost: "10.0.0.2:18334"}))
+}
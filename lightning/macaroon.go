@@ -0,0 +1,94 @@
+package lightning
+
+import (
+	"github.com/go-errors/errors"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+// MacaroonPermission allows an action on an entity, like invoices:read
+type MacaroonPermission struct {
+	Entity string
+	Action string
+}
+
+// InvoicePermissions are granted by default to exported connections and
+// match the invoice macaroon of lnd
+var InvoicePermissions = []MacaroonPermission{
+	{Entity: "invoices", Action: "read"},
+	{Entity: "invoices", Action: "write"},
+	{Entity: "address", Action: "read"},
+	{Entity: "address", Action: "write"},
+	{Entity: "onchain", Action: "read"},
+}
+
+// MacaroonPermissions tells which permissions relevant to sweetd are
+// granted by a macaroon
+type MacaroonPermissions struct {
+	ReadInvoices  bool
+	WriteInvoices bool
+	// Admin is set if the macaroon allows spending the funds of the node,
+	// which sweetd never needs
+	Admin bool
+	// Unknown lists the permissions that couldn't be probed because the
+	// node doesn't serve the probing call, like invoices:write
+	Unknown []string
+}
+
+// IsUnknown tells whether a permission couldn't be probed
+func (p *MacaroonPermissions) IsUnknown(permission string) bool {
+	for _, unknown := range p.Unknown {
+		if unknown == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MacaroonNode is implemented by nodes which authenticate with macaroons
+type MacaroonNode interface {
+	// BakeMacaroon creates a macaroon with the given permissions, which
+	// expires after expiry unless it's zero
+	BakeMacaroon(permissions []MacaroonPermission, expiry time.Duration) ([]byte, error)
+}
+
+func (p MacaroonPermission) String() string {
+	return p.Entity + ":" + p.Action
+}
+
+// ParseMacaroonPermission reads a permission like invoices:read
+func ParseMacaroonPermission(permission string) (MacaroonPermission, error) {
+	parts := strings.Split(permission, ":")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return MacaroonPermission{}, errors.Errorf("permission %s is not formatted as entity:action", permission)
+	}
+
+	return MacaroonPermission{
+		Entity: parts[0],
+		Action: parts[1],
+	}, nil
+}
+
+// isPermissionError tells whether a call was rejected because of the
+// macaroon rather than because of invalid arguments
+func isPermissionError(err error) bool {
+	message := err.Error()
+	if s, ok := status.FromError(err); ok {
+		message = s.Message()
+	}
+
+	return strings.Contains(message, "permission denied") || strings.Contains(message, "verification failed")
+}
+
+// isAuthError tells whether a macaroon was rejected as a whole rather
+// than only lacking a permission
+func isAuthError(err error) bool {
+	message := err.Error()
+	if s, ok := status.FromError(err); ok {
+		message = s.Message()
+	}
+
+	return strings.Contains(message, "verification failed") || strings.Contains(message, "macaroon")
+}
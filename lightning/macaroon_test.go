@@ -0,0 +1,33 @@
+package lightning
+
+import (
+	"github.com/go-errors/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestParseMacaroonPermission(t *testing.T) {
+	t.Parallel()
+
+	permission, err := ParseMacaroonPermission("invoices:read")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, MacaroonPermission{Entity: "invoices", Action: "read"}, permission)
+	assert.Equal(t, "invoices:read", permission.String())
+
+	_, err = ParseMacaroonPermission("invoices")
+	assert.NotEqual(t, nil, err)
+
+	_, err = ParseMacaroonPermission("invoices:")
+	assert.NotEqual(t, nil, err)
+}
+
+func TestIsPermissionError(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isPermissionError(status.Error(codes.Unknown, "permission denied")))
+	assert.True(t, isPermissionError(status.Error(codes.Unknown, "verification failed: signature mismatch after caveat verification")))
+	assert.False(t, isPermissionError(status.Error(codes.Unknown, "unable to locate invoice")))
+	assert.False(t, isPermissionError(errors.New("connection refused")))
+}
@@ -0,0 +1,34 @@
+package lightning
+
+import "github.com/go-errors/errors"
+
+// Network is the bitcoin chain a node operates on
+type Network string
+
+const (
+	NetworkMainnet Network = "mainnet"
+	NetworkTestnet Network = "testnet"
+	NetworkSignet  Network = "signet"
+	NetworkRegtest Network = "regtest"
+)
+
+// ParseNetwork validates a network name, nodes without one are on mainnet
+func ParseNetwork(network string) (Network, error) {
+	switch Network(network) {
+	case "":
+		return NetworkMainnet, nil
+	case NetworkMainnet, NetworkTestnet, NetworkSignet, NetworkRegtest:
+		return Network(network), nil
+	default:
+		return "", errors.Errorf("unknown network %s", network)
+	}
+}
+
+// clnNetwork is the name Core Lightning uses for a network
+func clnNetwork(network Network) string {
+	if network == NetworkMainnet {
+		return "bitcoin"
+	}
+
+	return string(network)
+}
@@ -0,0 +1,21 @@
+package lightning
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseNetwork(t *testing.T) {
+	t.Parallel()
+
+	network, err := ParseNetwork("")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, NetworkMainnet, network)
+
+	network, err = ParseNetwork("regtest")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, NetworkRegtest, network)
+
+	_, err = ParseNetwork("litecoin")
+	assert.NotEqual(t, nil, err)
+}
@@ -1,11 +1,22 @@
 package lightning
 
+import "time"
+
 type Invoice struct {
 	RHash          string
 	PaymentRequest string
 	Settled        bool
-	MSat           int64
-	Memo           string
+	// Accepted is set once a hold invoice was paid but is not settled yet
+	Accepted bool
+	Canceled bool
+	MSat     int64
+	PaidMSat int64
+	Memo     string
+	// SettleIndex orders settled invoices and is zero for unsettled ones
+	SettleIndex uint64
+	// Preimage is only known when creating a hold invoice, which has
+	// to be settled by revealing it
+	Preimage string
 }
 
 type InvoicesClient struct {
@@ -30,9 +41,26 @@ func (c *StatusClient) Cancel() {
 	c.node.unsubscribeStatus(c)
 }
 
+// publish hands a status to the subscriber without waiting for it, a
+// status which it didn't receive yet is replaced. Publishers have to be
+// serialized.
+func (c *StatusClient) publish(status Status) {
+	select {
+	case <-c.Status:
+	default:
+	}
+
+	select {
+	case c.Status <- status:
+	default:
+	}
+}
+
 type InvoiceRequest struct {
 	MSat int64
 	Memo string
+	// Hold requests an invoice which is only settled explicitly
+	Hold bool
 }
 
 type Status int
@@ -43,6 +71,11 @@ const (
 	StatusLocked
 	StatusStarted
 	StatusFailed
+	// StatusSyncing is set while a started node catches up with the chain
+	StatusSyncing
+	// StatusDegraded is set while a started node doesn't deliver invoice
+	// updates and is being reconnected
+	StatusDegraded
 )
 
 type Node interface {
@@ -50,6 +83,8 @@ type Node interface {
 	Stop() error
 	GetInvoice(rHash string) (*Invoice, error)
 	AddInvoice(request *InvoiceRequest) (*Invoice, error)
+	SettleInvoice(preimage string) error
+	CancelInvoice(rHash string) error
 	SubscribeInvoices() (*InvoicesClient, error)
 	SubscribeStatus() *StatusClient
 	unsubscribeInvoices(client *InvoicesClient)
@@ -59,3 +94,49 @@ type Node interface {
 	Init(password string, mnemonics []string) error
 	GenerateSeed() ([]string, error)
 }
+
+// LiquidityNode is implemented by nodes which know how much they are
+// able to receive through their channels
+type LiquidityNode interface {
+	// InboundLiquidity returns the amount in millisatoshis which can be
+	// received over all active channels
+	InboundLiquidity() (int64, error)
+}
+
+// HoldInvoiceNode is implemented by nodes which can create hold invoices.
+// Other nodes create regular invoices, which are settled when paid.
+type HoldInvoiceNode interface {
+	// SupportsHoldInvoices tells whether hold invoices can be created
+	SupportsHoldInvoices() bool
+}
+
+// Health is the result of the last periodic check of a node
+type Health struct {
+	Time           time.Time
+	SyncedToChain  bool
+	BlockHeight    uint32
+	Peers          uint32
+	ActiveChannels uint32
+	// Uris the node is reachable at by peers, like an onion address
+	Uris []string
+	// Inbound liquidity in millisatoshis
+	Inbound int64
+	// Error of the last check, if the node couldn't be queried
+	Error string
+}
+
+// HealthNode is implemented by nodes which periodically check their health
+type HealthNode interface {
+	// Health returns the result of the last check or nil if the node
+	// wasn't checked yet
+	Health() *Health
+}
+
+// BackupNode is implemented by nodes which can recover channel funds from
+// a static channel backup
+type BackupNode interface {
+	// InitFromBackup creates the wallet from a seed and the backup
+	InitFromBackup(password string, mnemonic []string, backup []byte) error
+	// Restore recovers the channels of a backup into a running wallet
+	Restore(backup []byte) error
+}
@@ -0,0 +1,34 @@
+package lightning
+
+// WalletBalance holds the funds of a node in satoshis
+type WalletBalance struct {
+	// Confirmed on-chain funds
+	Confirmed int64
+	// Unconfirmed on-chain funds
+	Unconfirmed int64
+	// Channels is the amount which can be sent over active channels
+	Channels int64
+}
+
+// SendCoinsRequest sends on-chain funds from the wallet
+type SendCoinsRequest struct {
+	Address string
+	// Amount in satoshis, ignored if all funds are sent
+	Amount int64
+	// SatPerByte of the transaction, the node estimates the fee if zero
+	SatPerByte int64
+	// SendAll sends all confirmed funds minus the fee
+	SendAll bool
+}
+
+// WalletNode is implemented by nodes which hold their own funds
+type WalletNode interface {
+	WalletBalance() (*WalletBalance, error)
+	// NewAddress returns an unused on-chain address for receiving funds
+	NewAddress() (string, error)
+	// SendCoins returns the id of the broadcast transaction
+	SendCoins(req *SendCoinsRequest) (string, error)
+	// PayInvoice pays an invoice of exactly msat and at most maxFeeSat in
+	// fees and returns the payment hash
+	PayInvoice(paymentRequest string, msat int64, maxFeeSat int64) (string, error)
+}
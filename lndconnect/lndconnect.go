@@ -0,0 +1,96 @@
+// Package lndconnect reads and writes lndconnect:// URIs, which hold
+// everything needed to connect to the gRPC interface of an lnd node
+package lndconnect
+
+import (
+	"encoding/base64"
+	"github.com/go-errors/errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+const (
+	scheme      = "lndconnect"
+	defaultPort = "10009"
+)
+
+// Connection to an lnd node
+type Connection struct {
+	// Host and port of the gRPC interface
+	Host string
+	// Cert is the DER encoded TLS certificate of the node, which is
+	// omitted for certificates issued by a trusted authority
+	Cert     []byte
+	Macaroon []byte
+}
+
+// Parse reads a URI like lndconnect://host:port?cert=...&macaroon=...
+// where cert and macaroon are base64url encoded without padding
+func Parse(uri string) (*Connection, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, errors.Errorf("invalid lndconnect uri: %v", err)
+	}
+
+	if u.Scheme != scheme {
+		return nil, errors.Errorf("unsupported scheme %s, expected %s://", u.Scheme, scheme)
+	}
+
+	if u.Hostname() == "" {
+		return nil, errors.Errorf("lndconnect uri has no host")
+	}
+
+	conn := &Connection{
+		Host: u.Host,
+	}
+
+	if u.Port() == "" {
+		conn.Host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+
+	query := u.Query()
+
+	if cert := query.Get("cert"); cert != "" {
+		conn.Cert, err = decode(cert)
+		if err != nil {
+			return nil, errors.Errorf("unable to decode cert: %v", err)
+		}
+	}
+
+	macaroon := query.Get("macaroon")
+	if macaroon == "" {
+		return nil, errors.Errorf("lndconnect uri has no macaroon")
+	}
+
+	conn.Macaroon, err = decode(macaroon)
+	if err != nil {
+		return nil, errors.Errorf("unable to decode macaroon: %v", err)
+	}
+
+	return conn, nil
+}
+
+// decode tolerates padding, which some wallets add anyway
+func decode(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// String encodes the connection as lndconnect:// URI
+func (c *Connection) String() string {
+	query := url.Values{}
+
+	if len(c.Cert) > 0 {
+		query.Set("cert", base64.RawURLEncoding.EncodeToString(c.Cert))
+	}
+
+	query.Set("macaroon", base64.RawURLEncoding.EncodeToString(c.Macaroon))
+
+	u := url.URL{
+		Scheme:   scheme,
+		Host:     c.Host,
+		RawQuery: query.Encode(),
+	}
+
+	return u.String()
+}
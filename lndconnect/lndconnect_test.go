@@ -0,0 +1,52 @@
+package lndconnect
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	conn, err := Parse("lndconnect://node.example.com:10001?cert=MIIC-_8&macaroon=AgED")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "node.example.com:10001", conn.Host)
+	assert.Equal(t, []byte{0x30, 0x82, 0x02, 0xfb, 0xff}, conn.Cert)
+	assert.Equal(t, []byte{0x02, 0x01, 0x03}, conn.Macaroon)
+
+	conn, err = Parse("lndconnect://abcdef.onion?macaroon=AgED==")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "abcdef.onion:10009", conn.Host)
+	assert.Equal(t, []byte(nil), conn.Cert)
+	assert.Equal(t, []byte{0x02, 0x01, 0x03}, conn.Macaroon)
+}
+
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := Parse("https://node.example.com?macaroon=AgED")
+	assert.NotEqual(t, nil, err)
+
+	_, err = Parse("lndconnect://node.example.com:10009?cert=MIIC")
+	assert.NotEqual(t, nil, err)
+
+	_, err = Parse("lndconnect://node.example.com:10009?macaroon=%%%")
+	assert.NotEqual(t, nil, err)
+}
+
+func TestString(t *testing.T) {
+	t.Parallel()
+
+	conn := &Connection{
+		Host:     "abcdef.onion:8080",
+		Cert:     []byte{0x30, 0x82, 0x02, 0xfb, 0xff},
+		Macaroon: []byte{0x02, 0x01, 0x03},
+	}
+
+	uri := conn.String()
+	assert.Equal(t, "lndconnect://abcdef.onion:8080?cert=MIIC-_8&macaroon=AgED", uri)
+
+	parsed, err := Parse(uri)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, conn, parsed)
+}
@@ -0,0 +1,185 @@
+package lnurl
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/cretz/bine/tor"
+	"github.com/go-errors/errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const requestTimeout = 30 * time.Second
+
+// Client requests invoices from LNURL-pay services, which are found
+// through Lightning addresses like satoshi@example.com
+type Client struct {
+	client   *http.Client
+	tor      *tor.Tor
+	dialer   *tor.Dialer
+	dialerMu sync.Mutex
+}
+
+type Config struct {
+	// Client used for requests, uses a client with a timeout which
+	// reaches onion services through Tor by default
+	Client *http.Client
+	// Tor is needed to reach Lightning addresses of onion services
+	Tor *tor.Tor
+}
+
+// payResponse is the first step of LNURL-pay, amounts are in
+// millisatoshis
+type payResponse struct {
+	Tag         string `json:"tag"`
+	Callback    string `json:"callback"`
+	MinSendable int64  `json:"minSendable"`
+	MaxSendable int64  `json:"maxSendable"`
+	Status      string `json:"status"`
+	Reason      string `json:"reason"`
+}
+
+type invoiceResponse struct {
+	Pr     string `json:"pr"`
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+func New(config *Config) *Client {
+	client := &Client{
+		client: config.Client,
+		tor:    config.Tor,
+	}
+
+	if client.client == nil {
+		client.client = &http.Client{
+			Timeout: requestTimeout,
+			Transport: &http.Transport{
+				DialContext: client.dial,
+			},
+		}
+	}
+
+	return client
+}
+
+// dial connects to onion services through Tor and to all other hosts
+// directly. The bine dialer is created on first use, as that waits for
+// Tor to enable its network.
+func (c *Client) dial(ctx context.Context, network string, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(host, ".onion") {
+		dialer := &net.Dialer{Timeout: requestTimeout}
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	if c.tor == nil {
+		return nil, errors.Errorf("unable to reach %s without tor", host)
+	}
+
+	c.dialerMu.Lock()
+	if c.dialer == nil {
+		dialer, err := c.tor.Dialer(ctx, nil)
+		if err != nil {
+			c.dialerMu.Unlock()
+			return nil, errors.Errorf("unable to create tor dialer: %v", err)
+		}
+
+		c.dialer = dialer
+	}
+	dialer := c.dialer
+	c.dialerMu.Unlock()
+
+	return dialer.DialContext(ctx, network, address)
+}
+
+// AddressUrl returns where the LNURL-pay service of a Lightning address
+// is found. Onion services are reached over http, all others over https.
+func AddressUrl(address string) (string, error) {
+	parts := strings.Split(address, "@")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", errors.Errorf("%s is not a lightning address", address)
+	}
+
+	scheme := "https"
+	if strings.HasSuffix(parts[1], ".onion") {
+		scheme = "http"
+	}
+
+	return scheme + "://" + parts[1] + "/.well-known/lnurlp/" + url.PathEscape(parts[0]), nil
+}
+
+// FetchInvoice requests an invoice for msat from a Lightning address
+func (c *Client) FetchInvoice(address string, msat int64) (string, error) {
+	addressUrl, err := AddressUrl(address)
+	if err != nil {
+		return "", err
+	}
+
+	pay := payResponse{}
+	err = c.get(addressUrl, &pay)
+	if err != nil {
+		return "", errors.Errorf("unable to resolve %s: %v", address, err)
+	}
+
+	if pay.Status == "ERROR" {
+		return "", errors.Errorf("unable to resolve %s: %s", address, pay.Reason)
+	}
+
+	if pay.Tag != "payRequest" {
+		return "", errors.Errorf("%s is not a pay request but %s", address, pay.Tag)
+	}
+
+	if msat < pay.MinSendable || (pay.MaxSendable > 0 && msat > pay.MaxSendable) {
+		return "", errors.Errorf("%d msat is not within %d and %d msat accepted by %s", msat, pay.MinSendable, pay.MaxSendable, address)
+	}
+
+	callback, err := url.Parse(pay.Callback)
+	if err != nil {
+		return "", errors.Errorf("invalid callback %s: %v", pay.Callback, err)
+	}
+
+	query := callback.Query()
+	query.Set("amount", strconv.FormatInt(msat, 10))
+	callback.RawQuery = query.Encode()
+
+	invoice := invoiceResponse{}
+	err = c.get(callback.String(), &invoice)
+	if err != nil {
+		return "", errors.Errorf("unable to get invoice from %s: %v", address, err)
+	}
+
+	if invoice.Status == "ERROR" {
+		return "", errors.Errorf("unable to get invoice from %s: %s", address, invoice.Reason)
+	}
+
+	if invoice.Pr == "" {
+		return "", errors.Errorf("no invoice received from %s", address)
+	}
+
+	return invoice.Pr, nil
+}
+
+func (c *Client) get(url string, v interface{}) error {
+	res, err := c.client.Get(url)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status %s", res.Status)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
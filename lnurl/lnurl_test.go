@@ -0,0 +1,63 @@
+package lnurl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAddressUrl(t *testing.T) {
+	t.Parallel()
+
+	addressUrl, err := AddressUrl("sweets@example.com")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "https://example.com/.well-known/lnurlp/sweets", addressUrl)
+
+	addressUrl, err = AddressUrl("sweets@abcdef.onion")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "http://abcdef.onion/.well-known/lnurlp/sweets", addressUrl)
+
+	_, err = AddressUrl("example.com")
+	assert.NotEqual(t, nil, err)
+}
+
+func TestFetchInvoice(t *testing.T) {
+	t.Parallel()
+
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/.well-known/lnurlp/sweets":
+			w.Write([]byte(`{"tag":"payRequest","callback":"` + server.URL + `/pay?id=1","minSendable":1000,"maxSendable":100000000}`))
+		case "/pay":
+			assert.Equal(t, "1", r.URL.Query().Get("id"))
+			w.Write([]byte(`{"pr":"lnbc` + r.URL.Query().Get("amount") + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := New(&Config{Client: server.Client()})
+	address := "sweets@" + strings.TrimPrefix(server.URL, "https://")
+
+	invoice, err := client.FetchInvoice(address, 50000)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "lnbc50000", invoice)
+
+	_, err = client.FetchInvoice(address, 500)
+	assert.NotEqual(t, nil, err)
+}
+
+func TestFetchInvoiceFromOnionWithoutTor(t *testing.T) {
+	t.Parallel()
+
+	// onion services are never dialed directly
+	_, err := New(&Config{}).FetchInvoice("sweets@abcdef.onion", 1000)
+	assert.NotEqual(t, nil, err)
+	assert.True(t, strings.Contains(err.Error(), "without tor"))
+}
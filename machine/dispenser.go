@@ -9,21 +9,28 @@ import (
 	"time"
 )
 
+type motorEvent struct {
+	slot int
+	on   bool
+}
+
 type DispenserMachine struct {
 	touchPin          string
-	motorPin          string
+	motorPins         []string // Motor pins by slot
 	buzzerPin         string
-	motorEvents       chan bool      // Internal motor events channel
-	buzzerEvents      chan bool      // Internal buzzer events channel
-	done              chan bool      // Internal done channel
-	waitGroup         sync.WaitGroup // Internal goroutine WaitGroup
+	motorEvents       chan motorEvent // Internal motor events channel
+	buzzerEvents      chan bool       // Internal buzzer events channel
+	done              chan bool       // Internal done channel
+	waitGroup         sync.WaitGroup  // Internal goroutine WaitGroup
 	touchesClients    map[uint32]*TouchesClient
 	nextTouchesClient nextTouchesClient
 }
 
 type DispenserMachineConfig struct {
-	TouchPin  string
-	MotorPin  string
+	TouchPin string
+	// MotorPins holds one motor pin for each dispensing slot, starting
+	// with slot 0
+	MotorPins []string
 	BuzzerPin string
 }
 
@@ -33,9 +40,9 @@ var _ Machine = (*DispenserMachine)(nil)
 func NewDispenserMachine(config *DispenserMachineConfig) *DispenserMachine {
 	m := &DispenserMachine{
 		touchPin:          config.TouchPin,
-		motorPin:          config.MotorPin,
+		motorPins:         config.MotorPins,
 		buzzerPin:         config.BuzzerPin,
-		motorEvents:       make(chan bool),
+		motorEvents:       make(chan motorEvent),
 		buzzerEvents:      make(chan bool),
 		touchesClients:    make(map[uint32]*TouchesClient),
 		nextTouchesClient: nextTouchesClient{id: 0},
@@ -74,9 +81,13 @@ func (m *DispenserMachine) Stop() error {
 	return nil
 }
 
-func (m *DispenserMachine) ToggleMotor(on bool) {
-	log.Infof("Toggling motor %v", on)
-	m.motorEvents <- on
+func (m *DispenserMachine) ToggleMotor(slot int, on bool) {
+	log.Infof("Toggling motor of slot %d %v", slot, on)
+	m.motorEvents <- motorEvent{slot: slot, on: on}
+}
+
+func (m *DispenserMachine) Slots() int {
+	return len(m.motorPins)
 }
 
 func (m *DispenserMachine) ToggleBuzzer(on bool) {
@@ -157,22 +168,32 @@ func (m *DispenserMachine) driveMotor() {
 	m.waitGroup.Add(1)
 	defer m.waitGroup.Done()
 
-	p := gpioreg.ByName(m.motorPin)
+	var pins []gpio.PinIO
+	for _, motorPin := range m.motorPins {
+		pins = append(pins, gpioreg.ByName(motorPin))
+	}
 
 	for {
 		select {
-		case on := <-m.motorEvents:
-			log.WithField("pin", "motor").WithField("on", on).Info("Received motor event")
+		case event := <-m.motorEvents:
+			log.WithField("pin", "motor").WithField("slot", event.slot).WithField("on", event.on).Info("Received motor event")
 
-			if on {
-				p.Out(gpio.High)
+			if event.slot < 0 || event.slot >= len(pins) {
+				log.Errorf("No motor pin for slot %d", event.slot)
+				continue
+			}
+
+			if event.on {
+				pins[event.slot].Out(gpio.High)
 			} else {
-				p.Out(gpio.Low)
+				pins[event.slot].Out(gpio.Low)
 			}
 		case <-m.done:
 			log.Info("Got done event in driveMotor")
 
-			p.Out(gpio.Low)
+			for _, p := range pins {
+				p.Out(gpio.Low)
+			}
 			return
 		}
 	}
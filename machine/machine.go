@@ -3,9 +3,10 @@ package machine
 type Machine interface {
 	Start() error
 	Stop() error
-	ToggleMotor(on bool)
+	ToggleMotor(slot int, on bool)
+	Slots() int
 	ToggleBuzzer(on bool)
 	DiagnosticNoise()
 	SubscribeTouches() *TouchesClient
 	unsubscribeTouches(client *TouchesClient)
-}
\ No newline at end of file
+}
@@ -4,6 +4,9 @@ import (
 	"net/http"
 )
 
+// mockMachineSlots is the number of dispensing slots a mock machine has
+const mockMachineSlots = 4
+
 type MockMachine struct {
 	listen            string
 	touchesClients    map[uint32]*TouchesClient
@@ -44,10 +47,14 @@ func (m *MockMachine) Stop() error {
 	return nil
 }
 
-func (m *MockMachine) ToggleMotor(on bool) {
+func (m *MockMachine) ToggleMotor(slot int, on bool) {
 	// nothing
 }
 
+func (m *MockMachine) Slots() int {
+	return mockMachineSlots
+}
+
 func (m *MockMachine) ToggleBuzzer(on bool) {
 	// nothing
 }
@@ -10,8 +10,11 @@ import (
 	"github.com/the-lightning-land/sweetd/network"
 	"github.com/the-lightning-land/sweetd/nodeman"
 	"github.com/the-lightning-land/sweetd/pairing"
+	"github.com/the-lightning-land/sweetd/pricing"
+	"github.com/the-lightning-land/sweetd/seal"
 	"github.com/the-lightning-land/sweetd/sweetdb"
 	"github.com/the-lightning-land/sweetd/sweetlog"
+	"github.com/the-lightning-land/sweetd/sysid"
 	"github.com/the-lightning-land/sweetd/updater"
 	"net/http"
 	"os"
@@ -95,6 +98,21 @@ func sweetdMain() error {
 		}
 	}()
 
+	// sealer protects secrets saved in sweet.db, like wallet passwords
+	// for unlocking nodes automatically
+	deviceId, err := sysid.GetId()
+	if err != nil {
+		log.Warnf("Could not identify device, sealing with key file only: %v", err)
+	}
+
+	sealer, err := seal.New(&seal.Config{
+		KeyFile:  filepath.Join(cfg.DataDir, "seal.key"),
+		DeviceId: deviceId,
+	})
+	if err != nil {
+		log.Errorf("Could not create sealer, nodes can't be unlocked automatically: %v", err)
+	}
+
 	// network, which acts as the core connectivity
 	// provider for all other components
 	var net network.Network
@@ -155,14 +173,16 @@ func sweetdMain() error {
 
 	switch cfg.Machine {
 	case "raspberry":
+		motorPins := append([]string{cfg.Raspberry.MotorPin}, cfg.Raspberry.SlotPins...)
+
 		m = machine.NewDispenserMachine(&machine.DispenserMachineConfig{
 			TouchPin:  cfg.Raspberry.TouchPin,
-			MotorPin:  cfg.Raspberry.MotorPin,
+			MotorPins: motorPins,
 			BuzzerPin: cfg.Raspberry.BuzzerPin,
 		})
 
-		log.Infof("Created Raspberry Pi machine on touch pin %v, motor pin %v and buzzer pin %v.",
-			cfg.Raspberry.TouchPin, cfg.Raspberry.MotorPin, cfg.Raspberry.BuzzerPin)
+		log.Infof("Created Raspberry Pi machine on touch pin %v, motor pins %v and buzzer pin %v.",
+			cfg.Raspberry.TouchPin, motorPins, cfg.Raspberry.BuzzerPin)
 	case "mock":
 		m = machine.NewMockMachine(cfg.Mock.Listen)
 
@@ -213,6 +233,7 @@ func sweetdMain() error {
 		NodesDataDir: filepath.Join(cfg.DataDir, "nodes"),
 		DB:           sweetDB,
 		Tor:          t,
+		Sealer:       sealer,
 		LogCreator: func(node string) nodeman.Logger {
 			logger := log.WithField("system", "nodeman")
 
@@ -224,6 +245,21 @@ func sweetdMain() error {
 		},
 	})
 
+	// exchange rates for converting fiat prices
+	var rates pricing.RateProvider
+
+	if cfg.Rates.File != "" {
+		rates = pricing.NewFileRateProvider(cfg.Rates.File)
+
+		log.Infof("using exchange rates from file %s", cfg.Rates.File)
+	} else {
+		rates = pricing.NewHttpRateProvider(&pricing.HttpRateProviderConfig{
+			Url: cfg.Rates.Url,
+		})
+
+		log.Infof("using exchange rates from %s", cfg.Rates.Url)
+	}
+
 	// pairingAdapter adapts the dispenser API to one compatible
 	// with the pairing controller
 	pairingAdapter := &dispenser.PairingAdapter{}
@@ -256,6 +292,7 @@ func sweetdMain() error {
 		Tor:      t,
 		Network:  net,
 		Pairing:  pairingAdapter.Pairing,
+		Rates:    rates,
 	})
 
 	pairingAdapter.Dispenser = dispenser
@@ -0,0 +1,73 @@
+package nodeman
+
+import (
+	"github.com/go-errors/errors"
+	"github.com/the-lightning-land/sweetd/lightning"
+)
+
+// channelNode returns the node with the given id if it manages its own
+// channels
+func (n *Nodeman) channelNode(id string) (lightning.ChannelNode, error) {
+	node := n.GetNode(id)
+	if node == nil {
+		return nil, errors.Errorf("node with id %s not found", id)
+	}
+
+	channelNode, ok := node.(lightning.ChannelNode)
+	if !ok {
+		return nil, errors.Errorf("node with id %s can not manage channels", id)
+	}
+
+	return channelNode, nil
+}
+
+func (n *Nodeman) ConnectPeer(id string, pubKey string, host string) error {
+	node, err := n.channelNode(id)
+	if err != nil {
+		return err
+	}
+
+	n.log.Infof("connecting node %s to peer %s@%s", id, pubKey, host)
+
+	return node.ConnectPeer(pubKey, host)
+}
+
+func (n *Nodeman) GetPeers(id string) ([]*lightning.Peer, error) {
+	node, err := n.channelNode(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.ListPeers()
+}
+
+func (n *Nodeman) OpenChannel(id string, req *lightning.OpenChannelRequest) (string, error) {
+	node, err := n.channelNode(id)
+	if err != nil {
+		return "", err
+	}
+
+	n.log.Infof("opening channel of %d sat from node %s to %s", req.Amount, id, req.PubKey)
+
+	return node.OpenChannel(req)
+}
+
+func (n *Nodeman) GetChannels(id string) ([]*lightning.Channel, error) {
+	node, err := n.channelNode(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.ListChannels()
+}
+
+func (n *Nodeman) CloseChannel(id string, channelPoint string, force bool) (string, error) {
+	node, err := n.channelNode(id)
+	if err != nil {
+		return "", err
+	}
+
+	n.log.Infof("closing channel %s of node %s, force %v", channelPoint, id, force)
+
+	return node.CloseChannel(channelPoint, force)
+}
@@ -1,14 +1,21 @@
 package nodeman
 
 import (
+	"context"
 	"crypto/x509"
+	"fmt"
 	"github.com/cretz/bine/tor"
 	"github.com/go-errors/errors"
 	"github.com/google/uuid"
 	"github.com/the-lightning-land/sweetd/lightning"
 	"github.com/the-lightning-land/sweetd/onion"
+	"github.com/the-lightning-land/sweetd/seal"
 	"github.com/the-lightning-land/sweetd/sweetdb"
+	"net"
 	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 )
 
 type Nodeman struct {
@@ -30,6 +37,20 @@ type Nodeman struct {
 
 	// tor instance for nodes to expose services through
 	tor *tor.Tor
+
+	// dialer through tor for remote nodes, created on first use
+	dialer   *tor.Dialer
+	dialerMu sync.Mutex
+
+	// sealer protects wallet passwords which are saved for unlocking
+	sealer *seal.Sealer
+
+	// decisions of the last node selection by node id
+	decisions   map[string]*Decision
+	decisionsMu sync.Mutex
+
+	// turn of the next round-robin node selection
+	turn int
 }
 
 type Config struct {
@@ -42,6 +63,10 @@ type Config struct {
 	// Tor instance for nodes to expose services through
 	Tor *tor.Tor
 
+	// Sealer protects saved wallet passwords, automatic unlocking isn't
+	// available without it
+	Sealer *seal.Sealer
+
 	// LogCreator
 	LogCreator LogCreator
 }
@@ -54,7 +79,9 @@ func New(config *Config) *Nodeman {
 		nodesDataDir: config.NodesDataDir,
 		db:           config.DB,
 		tor:          config.Tor,
+		sealer:       config.Sealer,
 		logCreator:   config.LogCreator,
+		decisions:    make(map[string]*Decision),
 	}
 
 	if config.LogCreator != nil {
@@ -75,11 +102,22 @@ func (n *Nodeman) Load() {
 	for _, node := range nodes {
 		switch node := node.(type) {
 		case *sweetdb.RemoteLndNode:
+			network, err := lightning.ParseNetwork(node.Network)
+			if err != nil {
+				n.log.Errorf("unable to create node: %v", err)
+				continue
+			}
+
 			lndNode, err := lightning.NewLndNode(&lightning.LndNodeConfig{
-				Uri:           node.Url,
-				CertBytes:     node.Cert,
-				MacaroonBytes: node.Macaroon,
-				Logger:        n.log,
+				Uri:             node.Url,
+				CertBytes:       node.Cert,
+				MacaroonBytes:   node.Macaroon,
+				SettleIndex:     n.getSettleIndex(node.Id),
+				Network:         network,
+				Logger:          n.log,
+				OnChannelBackup: n.saveChannelBackup(node.Id),
+				TorDialer:       n.torDialer(),
+				Tor:             node.Tor,
 			})
 			if err != nil {
 				n.log.Errorf("unable to create node: %v", err)
@@ -87,13 +125,69 @@ func (n *Nodeman) Load() {
 			}
 
 			n.nodes = append(n.nodes, &RemoteLndNode{
-				LndNode: lndNode,
-				id:      node.Id,
-				name:    node.Name,
-				enabled: node.Enabled,
-				Uri:     node.Url,
+				LndNode:  lndNode,
+				id:       node.Id,
+				name:     node.Name,
+				enabled:  node.Enabled,
+				priority: node.Priority,
+				Network:  network,
+				Uri:      node.Url,
+				Tor:      node.Tor,
+			})
+		case *sweetdb.RemoteClnNode:
+			network, err := lightning.ParseNetwork(node.Network)
+			if err != nil {
+				n.log.Errorf("unable to create node: %v", err)
+				continue
+			}
+
+			clnNode, err := lightning.NewClnNode(&lightning.ClnNodeConfig{
+				Uri:           node.Uri,
+				SettleIndex:   n.getSettleIndex(node.Id),
+				OnSettleIndex: n.saveSettleIndex(node.Id),
+				Network:       network,
+				Logger:        n.log,
+			})
+			if err != nil {
+				n.log.Errorf("unable to create node: %v", err)
+				continue
+			}
+
+			n.nodes = append(n.nodes, &RemoteClnNode{
+				ClnNode:  clnNode,
+				id:       node.Id,
+				name:     node.Name,
+				enabled:  node.Enabled,
+				priority: node.Priority,
+				Network:  network,
+				Uri:      node.Uri,
+			})
+		case *sweetdb.LnbitsNode:
+			lnbitsNode, err := lightning.NewLnbitsNode(&lightning.LnbitsNodeConfig{
+				Url:        node.Url,
+				InvoiceKey: node.InvoiceKey,
+				Logger:     n.log,
+			})
+			if err != nil {
+				n.log.Errorf("unable to create node: %v", err)
+				continue
+			}
+
+			n.nodes = append(n.nodes, &LnbitsNode{
+				LnbitsNode: lnbitsNode,
+				id:         node.Id,
+				name:       node.Name,
+				enabled:    node.Enabled,
+				priority:   node.Priority,
+				Url:        node.Url,
 			})
 		case *sweetdb.LocalNode:
+			network, err := lightning.ParseNetwork(node.Network)
+			if err != nil {
+				n.log.Errorf("unable to create node: %v", err)
+				continue
+			}
+
 			key, err := x509.ParsePKCS1PrivateKey(node.OnionKey)
 			if err != nil {
 				n.log.Errorf("unable to parse onion key: %v", err)
@@ -108,9 +202,15 @@ func (n *Nodeman) Load() {
 			})
 
 			localNode, err := lightning.NewLocalNode(&lightning.LocalNodeConfig{
-				DataDir:  filepath.Join(n.nodesDataDir, node.Id),
-				Logger:   n.logCreator(node.Id),
-				OnionSvc: onionSvc,
+				DataDir:         filepath.Join(n.nodesDataDir, node.Id),
+				Logger:          n.logCreator(node.Id),
+				OnionSvc:        onionSvc,
+				SettleIndex:     n.getSettleIndex(node.Id),
+				Network:         network,
+				Backend:         toBackend(node.Backend),
+				Tor:             n.torConfig(),
+				AutoUnlock:      n.autoUnlockPassword(node.Id),
+				OnChannelBackup: n.saveChannelBackup(node.Id),
 			})
 			if err != nil {
 				n.log.Errorf("unable to create node: %v", err)
@@ -118,10 +218,13 @@ func (n *Nodeman) Load() {
 			}
 
 			n.nodes = append(n.nodes, &LocalNode{
-				LocalNode: localNode,
-				id:        node.Id,
-				name:      node.Name,
-				enabled:   node.Enabled,
+				LocalNode:  localNode,
+				id:         node.Id,
+				name:       node.Name,
+				enabled:    node.Enabled,
+				priority:   node.Priority,
+				autoUnlock: node.AutoUnlock,
+				Network:    network,
 			})
 		default:
 			n.log.Errorf("unknown node type %T", node)
@@ -129,10 +232,215 @@ func (n *Nodeman) Load() {
 	}
 }
 
+// TestError is returned when a node isn't added because its connection
+// test failed
+type TestError struct {
+	Diagnosis *lightning.Diagnosis
+}
+
+func (e *TestError) Error() string {
+	return fmt.Sprintf("connection test failed (%s): %s", e.Diagnosis.Problem, e.Diagnosis.Error)
+}
+
+// testNode makes sure that a node is usable before it is saved and
+// returns warnings about it
+func testNode(node lightning.TestableNode, skip bool) ([]string, error) {
+	if skip {
+		return []string{"connection was not tested"}, nil
+	}
+
+	diagnosis := node.Test()
+	if !diagnosis.Ok() {
+		return nil, &TestError{Diagnosis: diagnosis}
+	}
+
+	return diagnosis.Warnings, nil
+}
+
+// TestNode tests the connection to a remote node without saving it
+func (n *Nodeman) TestNode(config NodeConfig) (*lightning.Diagnosis, error) {
+	var node lightning.TestableNode
+	var err error
+
+	switch config := config.(type) {
+	case *RemoteLndNodeConfig:
+		network, err := lightning.ParseNetwork(string(config.Network))
+		if err != nil {
+			return nil, err
+		}
+
+		node, err = lightning.NewLndNode(&lightning.LndNodeConfig{
+			Uri:           config.Uri,
+			CertBytes:     config.Cert,
+			MacaroonBytes: config.Macaroon,
+			Network:       network,
+			Logger:        n.log,
+			TorDialer:     n.torDialer(),
+			Tor:           config.Tor,
+		})
+		if err != nil {
+			return nil, errors.Errorf("unable to create: %v", err)
+		}
+	case *RemoteClnNodeConfig:
+		network, err := lightning.ParseNetwork(string(config.Network))
+		if err != nil {
+			return nil, err
+		}
+
+		node, err = lightning.NewClnNode(&lightning.ClnNodeConfig{
+			Uri:     config.Uri,
+			Network: network,
+			Logger:  n.log,
+		})
+		if err != nil {
+			return nil, errors.Errorf("unable to create: %v", err)
+		}
+	case *LnbitsNodeConfig:
+		node, err = lightning.NewLnbitsNode(&lightning.LnbitsNodeConfig{
+			Url:        config.Url,
+			InvoiceKey: config.InvoiceKey,
+			Logger:     n.log,
+		})
+		if err != nil {
+			return nil, errors.Errorf("unable to create: %v", err)
+		}
+	default:
+		return nil, errors.Errorf("unable to test config type %T", config)
+	}
+
+	return node.Test(), nil
+}
+
+// BakeMacaroon creates a macaroon of a node with the given permissions,
+// which expires after expiry unless it's zero
+func (n *Nodeman) BakeMacaroon(id string, permissions []lightning.MacaroonPermission, expiry time.Duration) ([]byte, error) {
+	node := n.GetNode(id)
+	if node == nil {
+		return nil, errors.Errorf("node with id %s not found", id)
+	}
+
+	macaroonNode, ok := node.(lightning.MacaroonNode)
+	if !ok {
+		return nil, errors.Errorf("node with id %s has no macaroons", id)
+	}
+
+	n.log.Infof("baking macaroon of node %s with permissions %v", id, permissions)
+
+	return macaroonNode.BakeMacaroon(permissions, expiry)
+}
+
+// torConfig returns how local nodes reach the Tor instance of sweetd or
+// nil if they have to connect to peers directly
+func (n *Nodeman) torConfig() *lightning.TorConfig {
+	if n.tor == nil {
+		return nil
+	}
+
+	socksAddress, err := onion.SocksAddress(n.tor)
+	if err != nil {
+		n.log.Errorf("unable to use tor for local nodes: %v", err)
+		return nil
+	}
+
+	controlAddress, err := onion.ControlAddress(n.tor)
+	if err != nil {
+		n.log.Errorf("unable to use tor for local nodes: %v", err)
+		return nil
+	}
+
+	return &lightning.TorConfig{
+		SocksAddress:   socksAddress,
+		ControlAddress: controlAddress,
+	}
+}
+
+// torDialer returns a dialer through the Tor instance of sweetd or nil
+// if there is none. The bine dialer is created on first use, as that
+// waits for Tor to enable its network.
+func (n *Nodeman) torDialer() lightning.Dialer {
+	if n.tor == nil {
+		return nil
+	}
+
+	return func(ctx context.Context, address string) (net.Conn, error) {
+		n.dialerMu.Lock()
+		if n.dialer == nil {
+			dialer, err := n.tor.Dialer(ctx, nil)
+			if err != nil {
+				n.dialerMu.Unlock()
+				return nil, errors.Errorf("unable to create tor dialer: %v", err)
+			}
+
+			n.dialer = dialer
+		}
+		dialer := n.dialer
+		n.dialerMu.Unlock()
+
+		return dialer.DialContext(ctx, "tcp", address)
+	}
+}
+
+// NetworkChanged requests new Tor circuits, as the previous ones might
+// be broken, and lets remote nodes reconnect right away
+func (n *Nodeman) NetworkChanged() {
+	if n.tor != nil {
+		err := n.tor.Control.Signal("NEWNYM")
+		if err != nil {
+			n.log.Errorf("unable to request new tor circuits: %v", err)
+		}
+	}
+
+	for _, node := range n.nodes {
+		if connectionNode, ok := node.(lightning.ConnectionNode); ok {
+			connectionNode.Reconnect()
+		}
+	}
+}
+
+// getSettleIndex returns the settle index a node resumes its invoice
+// subscription from
+func (n *Nodeman) getSettleIndex(id string) uint64 {
+	index, err := n.db.GetSettleIndex(id)
+	if err != nil {
+		n.log.Errorf("unable to get settle index of node %s: %v", id, err)
+	}
+
+	return index
+}
+
+// saveSettleIndex returns a function which saves the settle index a node
+// starts its invoice subscription from
+func (n *Nodeman) saveSettleIndex(id string) func(index uint64) {
+	return func(index uint64) {
+		err := n.db.SetSettleIndex(id, index)
+		if err != nil {
+			n.log.Errorf("unable to save settle index of node %s: %v", id, err)
+		}
+	}
+}
+
 func (n *Nodeman) GetNodes() []LightningNode {
 	return n.nodes
 }
 
+// GetActiveNodes returns the enabled and started nodes ordered by their
+// priority, so invoices can be issued by the first one that succeeds
+func (n *Nodeman) GetActiveNodes() []LightningNode {
+	active := []LightningNode{}
+
+	for _, node := range n.nodes {
+		if node.Enabled() && node.Status() == lightning.StatusStarted {
+			active = append(active, node)
+		}
+	}
+
+	sort.SliceStable(active, func(i, j int) bool {
+		return active[i].Priority() < active[j].Priority()
+	})
+
+	return active
+}
+
 func (n *Nodeman) GetNode(id string) LightningNode {
 	for _, node := range n.nodes {
 		if node.ID() == id {
@@ -149,38 +457,176 @@ func (n *Nodeman) AddNode(config NodeConfig) (LightningNode, error) {
 		return nil, errors.Errorf("unable to generate uuid: %v", err)
 	}
 
+	// new nodes are used after all existing ones
+	priority := 0
+	for _, node := range n.nodes {
+		if node.Priority() >= priority {
+			priority = node.Priority() + 1
+		}
+	}
+
 	switch config := config.(type) {
 	case *RemoteLndNodeConfig:
 		n.log.Infof("adding remote lnd node with id %s", id)
 
-		err := n.db.SaveNode(&sweetdb.RemoteLndNode{
+		network, err := lightning.ParseNetwork(string(config.Network))
+		if err != nil {
+			return nil, err
+		}
+
+		lndNode, err := lightning.NewLndNode(&lightning.LndNodeConfig{
+			Uri:             config.Uri,
+			CertBytes:       config.Cert,
+			MacaroonBytes:   config.Macaroon,
+			Network:         network,
+			Logger:          n.logCreator(id.String()),
+			OnChannelBackup: n.saveChannelBackup(id.String()),
+			TorDialer:       n.torDialer(),
+			Tor:             config.Tor,
+		})
+		if err != nil {
+			return nil, errors.Errorf("unable to create: %v", err)
+		}
+
+		warnings, err := testNode(lndNode, config.SkipTest)
+		if err != nil {
+			return nil, err
+		}
+
+		dbNode := &sweetdb.RemoteLndNode{
 			Id:       id.String(),
 			Name:     config.Name,
 			Url:      config.Uri,
 			Cert:     config.Cert,
 			Macaroon: config.Macaroon,
 			Enabled:  false,
-		})
+			Network:  string(network),
+			Tor:      config.Tor,
+		}
+		dbNode.Priority = priority
+
+		err = n.db.SaveNode(dbNode)
 		if err != nil {
 			return nil, errors.Errorf("unable to save: %v", err)
 		}
 
-		lndNode, err := lightning.NewLndNode(&lightning.LndNodeConfig{
+		node := &RemoteLndNode{
+			LndNode:  lndNode,
+			id:       id.String(),
+			name:     config.Name,
+			enabled:  false,
+			priority: priority,
+			warnings: warnings,
+			Network:  network,
+			Uri:      config.Uri,
+			Tor:      config.Tor,
+		}
+
+		for _, warning := range warnings {
+			n.log.Warnf("node %s: %s", id, warning)
+		}
+
+		n.nodes = append(n.nodes, node)
+
+		return node, nil
+	case *RemoteClnNodeConfig:
+		n.log.Infof("adding remote cln node with id %s", id)
+
+		network, err := lightning.ParseNetwork(string(config.Network))
+		if err != nil {
+			return nil, err
+		}
+
+		clnNode, err := lightning.NewClnNode(&lightning.ClnNodeConfig{
 			Uri:           config.Uri,
-			CertBytes:     config.Cert,
-			MacaroonBytes: config.Macaroon,
+			OnSettleIndex: n.saveSettleIndex(id.String()),
+			Network:       network,
 			Logger:        n.logCreator(id.String()),
 		})
 		if err != nil {
 			return nil, errors.Errorf("unable to create: %v", err)
 		}
 
-		node := &RemoteLndNode{
-			LndNode: lndNode,
-			id:      id.String(),
-			name:    config.Name,
-			enabled: false,
+		warnings, err := testNode(clnNode, config.SkipTest)
+		if err != nil {
+			return nil, err
+		}
+
+		dbNode := &sweetdb.RemoteClnNode{
+			Id:      id.String(),
+			Name:    config.Name,
 			Uri:     config.Uri,
+			Enabled: false,
+			Network: string(network),
+		}
+		dbNode.Priority = priority
+
+		err = n.db.SaveNode(dbNode)
+		if err != nil {
+			return nil, errors.Errorf("unable to save: %v", err)
+		}
+
+		node := &RemoteClnNode{
+			ClnNode:  clnNode,
+			id:       id.String(),
+			name:     config.Name,
+			enabled:  false,
+			priority: priority,
+			warnings: warnings,
+			Network:  network,
+			Uri:      config.Uri,
+		}
+
+		for _, warning := range warnings {
+			n.log.Warnf("node %s: %s", id, warning)
+		}
+
+		n.nodes = append(n.nodes, node)
+
+		return node, nil
+	case *LnbitsNodeConfig:
+		n.log.Infof("adding lnbits node with id %s", id)
+
+		lnbitsNode, err := lightning.NewLnbitsNode(&lightning.LnbitsNodeConfig{
+			Url:        config.Url,
+			InvoiceKey: config.InvoiceKey,
+			Logger:     n.logCreator(id.String()),
+		})
+		if err != nil {
+			return nil, errors.Errorf("unable to create: %v", err)
+		}
+
+		warnings, err := testNode(lnbitsNode, config.SkipTest)
+		if err != nil {
+			return nil, err
+		}
+
+		dbNode := &sweetdb.LnbitsNode{
+			Id:         id.String(),
+			Name:       config.Name,
+			Url:        config.Url,
+			InvoiceKey: config.InvoiceKey,
+			Enabled:    false,
+		}
+		dbNode.Priority = priority
+
+		err = n.db.SaveNode(dbNode)
+		if err != nil {
+			return nil, errors.Errorf("unable to save: %v", err)
+		}
+
+		node := &LnbitsNode{
+			LnbitsNode: lnbitsNode,
+			id:         id.String(),
+			name:       config.Name,
+			enabled:    false,
+			priority:   priority,
+			warnings:   warnings,
+			Url:        config.Url,
+		}
+
+		for _, warning := range warnings {
+			n.log.Warnf("node %s: %s", id, warning)
 		}
 
 		n.nodes = append(n.nodes, node)
@@ -189,6 +635,23 @@ func (n *Nodeman) AddNode(config NodeConfig) (LightningNode, error) {
 	case *LocalNodeConfig:
 		n.log.Infof("adding local node with id %s", id)
 
+		network, err := lightning.ParseNetwork(string(config.Network))
+		if err != nil {
+			return nil, err
+		}
+
+		if config.Backend != nil {
+			err := lightning.ValidateBackend(config.Backend)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err = lightning.ValidateLocalNetwork(network, config.Backend)
+		if err != nil {
+			return nil, err
+		}
+
 		onionKey, err := onion.GeneratePrivateKey(onion.V2)
 		if err != nil {
 			return nil, errors.Errorf("unable to generate onion key: %v", err)
@@ -196,12 +659,17 @@ func (n *Nodeman) AddNode(config NodeConfig) (LightningNode, error) {
 
 		payload := x509.MarshalPKCS1PrivateKey(onionKey)
 
-		err = n.db.SaveNode(&sweetdb.LocalNode{
+		dbNode := &sweetdb.LocalNode{
 			Id:       id.String(),
 			Name:     config.Name,
 			Enabled:  false,
+			Network:  string(network),
 			OnionKey: payload,
-		})
+			Backend:  fromBackend(config.Backend),
+		}
+		dbNode.Priority = priority
+
+		err = n.db.SaveNode(dbNode)
 		if err != nil {
 			return nil, errors.Errorf("unable to save: %v", err)
 		}
@@ -214,9 +682,14 @@ func (n *Nodeman) AddNode(config NodeConfig) (LightningNode, error) {
 		})
 
 		localNode, err := lightning.NewLocalNode(&lightning.LocalNodeConfig{
-			DataDir:  filepath.Join(n.nodesDataDir, id.String()),
-			Logger:   n.logCreator(id.String()),
-			OnionSvc: onionSvc,
+			DataDir:         filepath.Join(n.nodesDataDir, id.String()),
+			Logger:          n.logCreator(id.String()),
+			OnionSvc:        onionSvc,
+			Network:         network,
+			Backend:         config.Backend,
+			Tor:             n.torConfig(),
+			AutoUnlock:      n.autoUnlockPassword(id.String()),
+			OnChannelBackup: n.saveChannelBackup(id.String()),
 		})
 		if err != nil {
 			return nil, errors.Errorf("unable to create: %v", err)
@@ -227,6 +700,8 @@ func (n *Nodeman) AddNode(config NodeConfig) (LightningNode, error) {
 			id:        id.String(),
 			name:      config.Name,
 			enabled:   false,
+			priority:  priority,
+			Network:   network,
 		}
 
 		n.nodes = append(n.nodes, node)
@@ -268,9 +743,13 @@ func (n *Nodeman) EnableNode(id string) error {
 	}
 
 	switch node := node.(type) {
-	case sweetdb.RemoteLndNode:
+	case *sweetdb.RemoteLndNode:
+		node.Enabled = true
+	case *sweetdb.RemoteClnNode:
 		node.Enabled = true
-	case sweetdb.LocalNode:
+	case *sweetdb.LnbitsNode:
+		node.Enabled = true
+	case *sweetdb.LocalNode:
 		node.Enabled = true
 	}
 
@@ -297,9 +776,13 @@ func (n *Nodeman) DisableNode(id string) error {
 	}
 
 	switch node := node.(type) {
-	case sweetdb.RemoteLndNode:
+	case *sweetdb.RemoteLndNode:
+		node.Enabled = false
+	case *sweetdb.RemoteClnNode:
 		node.Enabled = false
-	case sweetdb.LocalNode:
+	case *sweetdb.LnbitsNode:
+		node.Enabled = false
+	case *sweetdb.LocalNode:
 		node.Enabled = false
 	}
 
@@ -326,9 +809,13 @@ func (n *Nodeman) RenameNode(id string, name string) error {
 	}
 
 	switch node := node.(type) {
-	case sweetdb.RemoteLndNode:
+	case *sweetdb.RemoteLndNode:
+		node.Name = name
+	case *sweetdb.RemoteClnNode:
+		node.Name = name
+	case *sweetdb.LnbitsNode:
 		node.Name = name
-	case sweetdb.LocalNode:
+	case *sweetdb.LocalNode:
 		node.Name = name
 	}
 
@@ -340,8 +827,253 @@ func (n *Nodeman) RenameNode(id string, name string) error {
 	for _, node := range n.nodes {
 		if node.ID() == id {
 			node.setName(name)
+
+			return nil
 		}
 	}
 
 	return errors.Errorf("node with id %s not found", id)
 }
+
+func (n *Nodeman) SetNodePriority(id string, priority int) error {
+	node, err := n.db.GetNode(id)
+	if err != nil {
+		return errors.Errorf("unable to get node: %v", err)
+	}
+
+	switch node := node.(type) {
+	case *sweetdb.RemoteLndNode:
+		node.Priority = priority
+	case *sweetdb.RemoteClnNode:
+		node.Priority = priority
+	case *sweetdb.LnbitsNode:
+		node.Priority = priority
+	case *sweetdb.LocalNode:
+		node.Priority = priority
+	default:
+		return errors.Errorf("node with id %s not found", id)
+	}
+
+	err = n.db.SaveNode(node)
+	if err != nil {
+		return errors.Errorf("unable to save node: %v", err)
+	}
+
+	for _, node := range n.nodes {
+		if node.ID() == id {
+			node.setPriority(priority)
+
+			return nil
+		}
+	}
+
+	return errors.Errorf("node with id %s not found", id)
+}
+
+// SetNodeBackend changes the chain backend of a local node, which is used
+// once the node is started again
+func (n *Nodeman) SetNodeBackend(id string, backend *lightning.Backend) error {
+	node, err := n.db.GetNode(id)
+	if err != nil {
+		return errors.Errorf("unable to get node: %v", err)
+	}
+
+	dbNode, ok := node.(*sweetdb.LocalNode)
+	if !ok {
+		return errors.Errorf("node with id %s is not a local node", id)
+	}
+
+	// the rpc password is never returned, so clients that send back the
+	// backend they got keep the saved one
+	if backend != nil && backend.RpcPass == "" && dbNode.Backend != nil {
+		withPass := *backend
+		withPass.RpcPass = dbNode.Backend.RpcPass
+		backend = &withPass
+	}
+
+	err = lightning.ValidateBackend(backend)
+	if err != nil {
+		return err
+	}
+
+	network, err := lightning.ParseNetwork(dbNode.Network)
+	if err != nil {
+		return err
+	}
+
+	err = lightning.ValidateLocalNetwork(network, backend)
+	if err != nil {
+		return err
+	}
+
+	dbNode.Backend = fromBackend(backend)
+
+	err = n.db.SaveNode(dbNode)
+	if err != nil {
+		return errors.Errorf("unable to save node: %v", err)
+	}
+
+	localNode, ok := n.GetNode(id).(*LocalNode)
+	if !ok {
+		return errors.Errorf("node with id %s not found", id)
+	}
+
+	localNode.SetBackend(backend)
+
+	return nil
+}
+
+func toBackend(backend *sweetdb.LocalNodeBackend) *lightning.Backend {
+	if backend == nil {
+		return nil
+	}
+
+	return &lightning.Backend{
+		Kind:           lightning.BackendKind(backend.Kind),
+		Peers:          backend.Peers,
+		RpcHost:        backend.RpcHost,
+		RpcUser:        backend.RpcUser,
+		RpcPass:        backend.RpcPass,
+		ZmqPubRawBlock: backend.ZmqPubRawBlock,
+		ZmqPubRawTx:    backend.ZmqPubRawTx,
+	}
+}
+
+func fromBackend(backend *lightning.Backend) *sweetdb.LocalNodeBackend {
+	if backend == nil {
+		return nil
+	}
+
+	return &sweetdb.LocalNodeBackend{
+		Kind:           string(backend.Kind),
+		Peers:          backend.Peers,
+		RpcHost:        backend.RpcHost,
+		RpcUser:        backend.RpcUser,
+		RpcPass:        backend.RpcPass,
+		ZmqPubRawBlock: backend.ZmqPubRawBlock,
+		ZmqPubRawTx:    backend.ZmqPubRawTx,
+	}
+}
+
+// autoUnlockPassword returns a function which unseals the saved wallet
+// password of a local node, if automatic unlocking is enabled
+func (n *Nodeman) autoUnlockPassword(id string) func() (string, error) {
+	return func() (string, error) {
+		node, err := n.db.GetNode(id)
+		if err != nil {
+			return "", errors.Errorf("unable to get node: %v", err)
+		}
+
+		dbNode, ok := node.(*sweetdb.LocalNode)
+		if !ok || !dbNode.AutoUnlock {
+			return "", nil
+		}
+
+		if n.sealer == nil {
+			return "", errors.Errorf("no sealer available")
+		}
+
+		password, err := n.sealer.Open(dbNode.SealedPassword)
+		if err != nil {
+			return "", err
+		}
+
+		return string(password), nil
+	}
+}
+
+// saveChannelBackup returns a function which saves the latest channel
+// backup of a node. The backup is encrypted by lnd with the wallet seed,
+// it is not bound to the device so it outlives the SD card.
+func (n *Nodeman) saveChannelBackup(id string) func(backup []byte) {
+	return func(backup []byte) {
+		err := n.db.SetChannelBackup(id, &sweetdb.ChannelBackup{
+			Time:            time.Now(),
+			MultiChanBackup: backup,
+		})
+		if err != nil {
+			n.log.Errorf("unable to save channel backup of node %s: %v", id, err)
+			return
+		}
+
+		n.log.Infof("saved channel backup of node %s", id)
+	}
+}
+
+// GetChannelBackup returns the latest channel backup of a node and when
+// it was saved
+func (n *Nodeman) GetChannelBackup(id string) ([]byte, time.Time, error) {
+	backup, err := n.db.GetChannelBackup(id)
+	if err != nil {
+		return nil, time.Time{}, errors.Errorf("unable to get channel backup: %v", err)
+	}
+
+	if backup == nil {
+		return nil, time.Time{}, nil
+	}
+
+	if len(backup.MultiChanBackup) > 0 {
+		return backup.MultiChanBackup, backup.Time, nil
+	}
+
+	// backups saved by earlier versions were sealed with the device key
+	if n.sealer == nil {
+		return nil, time.Time{}, errors.Errorf("no sealer available")
+	}
+
+	multiChanBackup, err := n.sealer.Open(backup.Sealed)
+	if err != nil {
+		return nil, time.Time{}, errors.Errorf("unable to open channel backup: %v", err)
+	}
+
+	return multiChanBackup, backup.Time, nil
+}
+
+// SetAutoUnlock saves the sealed wallet password of a local node for
+// unlocking it on start or removes it again
+func (n *Nodeman) SetAutoUnlock(id string, enabled bool, password string) error {
+	node, err := n.db.GetNode(id)
+	if err != nil {
+		return errors.Errorf("unable to get node: %v", err)
+	}
+
+	dbNode, ok := node.(*sweetdb.LocalNode)
+	if !ok {
+		return errors.Errorf("node with id %s is not a local node", id)
+	}
+
+	localNode, ok := n.GetNode(id).(*LocalNode)
+	if !ok {
+		return errors.Errorf("node with id %s not found", id)
+	}
+
+	if enabled {
+		if n.sealer == nil {
+			return errors.Errorf("automatic unlocking is not available")
+		}
+
+		if password == "" {
+			return errors.Errorf("password must not be empty")
+		}
+
+		sealedPassword, err := n.sealer.Seal([]byte(password))
+		if err != nil {
+			return errors.Errorf("unable to seal password: %v", err)
+		}
+
+		dbNode.AutoUnlock = true
+		dbNode.SealedPassword = sealedPassword
+	} else {
+		dbNode.AutoUnlock = false
+		dbNode.SealedPassword = nil
+	}
+
+	err = n.db.SaveNode(dbNode)
+	if err != nil {
+		return errors.Errorf("unable to save node: %v", err)
+	}
+
+	localNode.autoUnlock = enabled
+
+	return nil
+}
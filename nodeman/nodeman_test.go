@@ -0,0 +1,194 @@
+package nodeman
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/the-lightning-land/sweetd/lightning"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// fakeTestableNode returns a fixed diagnosis
+type fakeTestableNode struct {
+	diagnosis *lightning.Diagnosis
+	tested    bool
+}
+
+func (f *fakeTestableNode) Test() *lightning.Diagnosis {
+	f.tested = true
+	return f.diagnosis
+}
+
+func TestTestNode(t *testing.T) {
+	t.Parallel()
+
+	warnings, err := testNode(&fakeTestableNode{
+		diagnosis: &lightning.Diagnosis{Reachable: true},
+	}, false)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(warnings))
+
+	warnings, err = testNode(&fakeTestableNode{
+		diagnosis: &lightning.Diagnosis{Reachable: true, Warnings: []string{"macaroon is over-privileged"}},
+	}, false)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(warnings))
+
+	_, err = testNode(&fakeTestableNode{
+		diagnosis: &lightning.Diagnosis{Problem: lightning.ProblemUnreachable, Error: "connection refused"},
+	}, false)
+	assert.NotEqual(t, nil, err)
+
+	testErr, ok := err.(*TestError)
+	assert.True(t, ok)
+	assert.Equal(t, lightning.ProblemUnreachable, testErr.Diagnosis.Problem)
+
+	// unreachable nodes can still be added when the test is skipped
+	node := &fakeTestableNode{
+		diagnosis: &lightning.Diagnosis{Problem: lightning.ProblemUnreachable, Error: "connection refused"},
+	}
+
+	warnings, err = testNode(node, true)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(warnings))
+	assert.False(t, node.tested)
+}
+
+func TestChannelBackupWithoutSealer(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "nodeman")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	db, err := sweetdb.Open(dir)
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	n := New(&Config{DB: db})
+
+	backup, _, err := n.GetChannelBackup("node")
+	assert.Equal(t, nil, err)
+	assert.Nil(t, backup)
+
+	// backups are encrypted by lnd and don't depend on the device key
+	n.saveChannelBackup("node")([]byte{1, 2, 3})
+
+	backup, savedAt, err := n.GetChannelBackup("node")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []byte{1, 2, 3}, backup)
+	assert.False(t, savedAt.IsZero())
+}
+
+func TestSetNodeBackendKeepsPassword(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "nodeman")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	db, err := sweetdb.Open(dir)
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	assert.Equal(t, nil, db.SaveNode(&sweetdb.LocalNode{
+		Id: "local",
+		Backend: &sweetdb.LocalNodeBackend{
+			Kind:    string(lightning.BackendBitcoind),
+			RpcHost: "10.0.0.2:8332",
+			RpcUser: "sweetd",
+			RpcPass: "secret",
+		},
+	}))
+
+	n := New(&Config{DB: db})
+
+	// the node isn't running, but the backend is saved anyway
+	n.SetNodeBackend("local", &lightning.Backend{
+		Kind:           lightning.BackendBitcoind,
+		RpcHost:        "10.0.0.3:8332",
+		RpcUser:        "sweetd",
+		ZmqPubRawBlock: "tcp://10.0.0.3:28332",
+		ZmqPubRawTx:    "tcp://10.0.0.3:28333",
+	})
+
+	node, err := db.GetNode("local")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "10.0.0.3:8332", node.(*sweetdb.LocalNode).Backend.RpcHost)
+	assert.Equal(t, "secret", node.(*sweetdb.LocalNode).Backend.RpcPass)
+}
+
+// savedNode returns the name and whether a saved node is enabled
+func savedNode(t *testing.T, db *sweetdb.DB, id string) (string, bool) {
+	node, err := db.GetNode(id)
+	assert.Equal(t, nil, err)
+
+	switch node := node.(type) {
+	case *sweetdb.RemoteLndNode:
+		return node.Name, node.Enabled
+	case *sweetdb.RemoteClnNode:
+		return node.Name, node.Enabled
+	case *sweetdb.LocalNode:
+		return node.Name, node.Enabled
+	}
+
+	t.Fatalf("unexpected node %T", node)
+
+	return "", false
+}
+
+func TestUpdateSavedNodes(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "nodeman")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	db, err := sweetdb.Open(dir)
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	assert.Equal(t, nil, db.SaveNode(&sweetdb.RemoteLndNode{Id: "lnd", Name: "lnd"}))
+	assert.Equal(t, nil, db.SaveNode(&sweetdb.RemoteClnNode{Id: "cln", Name: "cln"}))
+	assert.Equal(t, nil, db.SaveNode(&sweetdb.LocalNode{Id: "local", Name: "local"}))
+
+	// saved nodes are updated even though they aren't running
+	n := New(&Config{DB: db})
+
+	for _, id := range []string{"lnd", "cln", "local"} {
+		n.EnableNode(id)
+		n.RenameNode(id, "renamed")
+
+		name, enabled := savedNode(t, db, id)
+		assert.Equal(t, "renamed", name)
+		assert.True(t, enabled)
+
+		n.DisableNode(id)
+
+		_, enabled = savedNode(t, db, id)
+		assert.False(t, enabled)
+	}
+}
+
+func TestSetNodePriorityUnknownNode(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "nodeman")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	db, err := sweetdb.Open(dir)
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	n := New(&Config{DB: db})
+
+	err = n.SetNodePriority("unknown", 1)
+	assert.NotEqual(t, nil, err)
+	assert.Contains(t, err.Error(), "not found")
+
+	nodes, err := db.GetNodes()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(nodes))
+}
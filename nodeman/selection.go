@@ -0,0 +1,120 @@
+package nodeman
+
+import (
+	"github.com/the-lightning-land/sweetd/lightning"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"sort"
+	"time"
+)
+
+// Decision explains whether a node was considered when an invoice was
+// last issued
+type Decision struct {
+	Time time.Time
+	// MSat of the invoice that was issued
+	MSat int64
+	// Inbound liquidity of the node in millisatoshis or -1 if unknown
+	Inbound int64
+	// Eligible is set if the node could receive the invoice amount
+	Eligible bool
+	// Selected is set if the node was chosen first
+	Selected bool
+	// Reason why the node was skipped or an error querying it
+	Reason string
+}
+
+type candidate struct {
+	node    LightningNode
+	inbound int64
+}
+
+// orderCandidates sorts nodes which can receive the invoice amount by
+// the given strategy, nodes with unknown liquidity are ranked last when
+// choosing by liquidity
+func orderCandidates(strategy sweetdb.NodeSelection, candidates []candidate, turn int) []candidate {
+	ordered := make([]candidate, len(candidates))
+	copy(ordered, candidates)
+
+	switch strategy {
+	case sweetdb.NodeSelectionMostInbound:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].inbound > ordered[j].inbound
+		})
+	case sweetdb.NodeSelectionRoundRobin:
+		if len(ordered) > 0 {
+			offset := turn % len(ordered)
+			ordered = append(ordered[offset:], ordered[:offset]...)
+		}
+	}
+
+	return ordered
+}
+
+// SelectNodes returns the active nodes which can receive the given amount
+// in the order they should be tried in
+func (n *Nodeman) SelectNodes(strategy sweetdb.NodeSelection, msat int64) []LightningNode {
+	now := time.Now()
+	decisions := make(map[string]*Decision)
+	candidates := []candidate{}
+
+	for _, node := range n.GetActiveNodes() {
+		decision := &Decision{
+			Time:     now,
+			MSat:     msat,
+			Inbound:  -1,
+			Eligible: true,
+		}
+
+		decisions[node.ID()] = decision
+
+		if liquidityNode, ok := node.(lightning.LiquidityNode); ok {
+			inbound, err := liquidityNode.InboundLiquidity()
+			if err != nil {
+				n.log.Errorf("could not get inbound liquidity of node %s: %v", node.ID(), err)
+				decision.Reason = err.Error()
+			} else {
+				decision.Inbound = inbound
+			}
+		}
+
+		if decision.Inbound >= 0 && decision.Inbound < msat {
+			decision.Eligible = false
+			decision.Reason = "not enough inbound liquidity"
+			continue
+		}
+
+		candidates = append(candidates, candidate{
+			node:    node,
+			inbound: decision.Inbound,
+		})
+	}
+
+	n.decisionsMu.Lock()
+	ordered := orderCandidates(strategy, candidates, n.turn)
+	if strategy == sweetdb.NodeSelectionRoundRobin && len(candidates) > 0 {
+		n.turn++
+	}
+
+	if len(ordered) > 0 {
+		decisions[ordered[0].node.ID()].Selected = true
+	}
+
+	n.decisions = decisions
+	n.decisionsMu.Unlock()
+
+	nodes := make([]LightningNode, len(ordered))
+	for i, candidate := range ordered {
+		nodes[i] = candidate.node
+	}
+
+	return nodes
+}
+
+// GetDecision returns how a node was considered in the last selection
+// or nil if it wasn't active back then
+func (n *Nodeman) GetDecision(id string) *Decision {
+	n.decisionsMu.Lock()
+	defer n.decisionsMu.Unlock()
+
+	return n.decisions[id]
+}
@@ -0,0 +1,48 @@
+package nodeman
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"testing"
+)
+
+func inbounds(candidates []candidate) []int64 {
+	result := []int64{}
+	for _, candidate := range candidates {
+		result = append(result, candidate.inbound)
+	}
+
+	return result
+}
+
+func TestOrderCandidatesPriority(t *testing.T) {
+	t.Parallel()
+
+	candidates := []candidate{{inbound: 1000}, {inbound: -1}, {inbound: 5000}}
+
+	ordered := orderCandidates(sweetdb.NodeSelectionPriority, candidates, 1)
+	assert.Equal(t, []int64{1000, -1, 5000}, inbounds(ordered))
+}
+
+func TestOrderCandidatesMostInbound(t *testing.T) {
+	t.Parallel()
+
+	candidates := []candidate{{inbound: 1000}, {inbound: -1}, {inbound: 5000}}
+
+	ordered := orderCandidates(sweetdb.NodeSelectionMostInbound, candidates, 0)
+	assert.Equal(t, []int64{5000, 1000, -1}, inbounds(ordered))
+
+	// the original order is left untouched
+	assert.Equal(t, []int64{1000, -1, 5000}, inbounds(candidates))
+}
+
+func TestOrderCandidatesRoundRobin(t *testing.T) {
+	t.Parallel()
+
+	candidates := []candidate{{inbound: 1}, {inbound: 2}, {inbound: 3}}
+
+	assert.Equal(t, []int64{1, 2, 3}, inbounds(orderCandidates(sweetdb.NodeSelectionRoundRobin, candidates, 0)))
+	assert.Equal(t, []int64{2, 3, 1}, inbounds(orderCandidates(sweetdb.NodeSelectionRoundRobin, candidates, 1)))
+	assert.Equal(t, []int64{1, 2, 3}, inbounds(orderCandidates(sweetdb.NodeSelectionRoundRobin, candidates, 3)))
+	assert.Equal(t, []candidate{}, orderCandidates(sweetdb.NodeSelectionRoundRobin, []candidate{}, 2))
+}
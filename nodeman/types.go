@@ -6,13 +6,37 @@ type NodeConfig interface{}
 
 type RemoteLndNodeConfig struct {
 	Name     string
+	Network  lightning.Network
 	Uri      string
 	Cert     []byte
 	Macaroon []byte
+	// Tor routes connections through Tor, which .onion hosts always are
+	Tor bool
+	// SkipTest saves the node without testing its connection first
+	SkipTest bool
+}
+
+type RemoteClnNodeConfig struct {
+	Name    string
+	Network lightning.Network
+	Uri     string
+	// SkipTest saves the node without testing its connection first
+	SkipTest bool
+}
+
+type LnbitsNodeConfig struct {
+	Name       string
+	Url        string
+	InvoiceKey string
+	// SkipTest saves the node without testing its connection first
+	SkipTest bool
 }
 
 type LocalNodeConfig struct {
-	Name string
+	Name    string
+	Network lightning.Network
+	// Backend is optional and defaults to neutrino
+	Backend *lightning.Backend
 }
 
 type LightningNode interface {
@@ -20,33 +44,95 @@ type LightningNode interface {
 	ID() string
 	Name() string
 	Enabled() bool
+	Priority() int
 	setEnabled(enabled bool)
 	setName(name string)
+	setPriority(priority int)
 }
 
 type RemoteLndNode struct {
 	*lightning.LndNode
-	id      string
-	name    string
-	enabled bool
-	Uri     string
+	id       string
+	name     string
+	enabled  bool
+	priority int
+	warnings []string
+	Network  lightning.Network
+	Uri      string
+	Tor      bool
+}
+
+func (n *RemoteLndNode) ID() string               { return n.id }
+func (n *RemoteLndNode) Name() string             { return n.name }
+func (n *RemoteLndNode) setName(name string)      { n.name = name }
+func (n *RemoteLndNode) Enabled() bool            { return n.enabled }
+func (n *RemoteLndNode) setEnabled(enabled bool)  { n.enabled = enabled }
+func (n *RemoteLndNode) Priority() int            { return n.priority }
+func (n *RemoteLndNode) setPriority(priority int) { n.priority = priority }
+
+// Warnings about the node found when it was added
+func (n *RemoteLndNode) Warnings() []string { return n.warnings }
+
+type RemoteClnNode struct {
+	*lightning.ClnNode
+	id       string
+	name     string
+	enabled  bool
+	priority int
+	warnings []string
+	Network  lightning.Network
+	Uri      string
 }
 
-func (n *RemoteLndNode) ID() string              { return n.id }
-func (n *RemoteLndNode) Name() string            { return n.name }
-func (n *RemoteLndNode) setName(name string)     { n.name = name }
-func (n *RemoteLndNode) Enabled() bool           { return n.enabled }
-func (n *RemoteLndNode) setEnabled(enabled bool) { n.enabled = enabled }
+func (n *RemoteClnNode) ID() string               { return n.id }
+func (n *RemoteClnNode) Name() string             { return n.name }
+func (n *RemoteClnNode) setName(name string)      { n.name = name }
+func (n *RemoteClnNode) Enabled() bool            { return n.enabled }
+func (n *RemoteClnNode) setEnabled(enabled bool)  { n.enabled = enabled }
+func (n *RemoteClnNode) Priority() int            { return n.priority }
+func (n *RemoteClnNode) setPriority(priority int) { n.priority = priority }
+
+// Warnings about the node found when it was added
+func (n *RemoteClnNode) Warnings() []string { return n.warnings }
+
+type LnbitsNode struct {
+	*lightning.LnbitsNode
+	id       string
+	name     string
+	enabled  bool
+	priority int
+	warnings []string
+	Url      string
+}
+
+func (n *LnbitsNode) ID() string               { return n.id }
+func (n *LnbitsNode) Name() string             { return n.name }
+func (n *LnbitsNode) setName(name string)      { n.name = name }
+func (n *LnbitsNode) Enabled() bool            { return n.enabled }
+func (n *LnbitsNode) setEnabled(enabled bool)  { n.enabled = enabled }
+func (n *LnbitsNode) Priority() int            { return n.priority }
+func (n *LnbitsNode) setPriority(priority int) { n.priority = priority }
+
+// Warnings about the node found when it was added
+func (n *LnbitsNode) Warnings() []string { return n.warnings }
 
 type LocalNode struct {
 	*lightning.LocalNode
-	id      string
-	name    string
-	enabled bool
+	id         string
+	name       string
+	enabled    bool
+	priority   int
+	autoUnlock bool
+	Network    lightning.Network
 }
 
-func (n *LocalNode) ID() string              { return n.id }
-func (n *LocalNode) Name() string            { return n.name }
-func (n *LocalNode) setName(name string)     { n.name = name }
-func (n *LocalNode) Enabled() bool           { return n.enabled }
-func (n *LocalNode) setEnabled(enabled bool) { n.enabled = enabled }
+// AutoUnlock tells whether the wallet is unlocked on start
+func (n *LocalNode) AutoUnlock() bool { return n.autoUnlock }
+
+func (n *LocalNode) ID() string               { return n.id }
+func (n *LocalNode) Name() string             { return n.name }
+func (n *LocalNode) setName(name string)      { n.name = name }
+func (n *LocalNode) Enabled() bool            { return n.enabled }
+func (n *LocalNode) setEnabled(enabled bool)  { n.enabled = enabled }
+func (n *LocalNode) Priority() int            { return n.priority }
+func (n *LocalNode) setPriority(priority int) { n.priority = priority }
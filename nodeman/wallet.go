@@ -0,0 +1,61 @@
+package nodeman
+
+import (
+	"github.com/go-errors/errors"
+	"github.com/the-lightning-land/sweetd/lightning"
+)
+
+// walletNode returns the node with the given id if it holds its own funds
+func (n *Nodeman) walletNode(id string) (lightning.WalletNode, error) {
+	node := n.GetNode(id)
+	if node == nil {
+		return nil, errors.Errorf("node with id %s not found", id)
+	}
+
+	walletNode, ok := node.(lightning.WalletNode)
+	if !ok {
+		return nil, errors.Errorf("node with id %s has no wallet", id)
+	}
+
+	return walletNode, nil
+}
+
+func (n *Nodeman) GetWalletBalance(id string) (*lightning.WalletBalance, error) {
+	node, err := n.walletNode(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.WalletBalance()
+}
+
+func (n *Nodeman) NewAddress(id string) (string, error) {
+	node, err := n.walletNode(id)
+	if err != nil {
+		return "", err
+	}
+
+	return node.NewAddress()
+}
+
+func (n *Nodeman) SendCoins(id string, req *lightning.SendCoinsRequest) (string, error) {
+	node, err := n.walletNode(id)
+	if err != nil {
+		return "", err
+	}
+
+	n.log.Infof("sending %d sat from node %s to %s, send all %v", req.Amount, id, req.Address, req.SendAll)
+
+	return node.SendCoins(req)
+}
+
+func (n *Nodeman) PayInvoice(id string, paymentRequest string, msat int64, maxFeeSat int64) (string, error) {
+	node, err := n.walletNode(id)
+	if err != nil {
+		return "", err
+	}
+
+	n.log.Infof("paying %d msat from node %s", msat, id)
+
+	return node.PayInvoice(paymentRequest, msat, maxFeeSat)
+}
@@ -0,0 +1,30 @@
+package onion
+
+import (
+	"fmt"
+	"github.com/cretz/bine/tor"
+	"github.com/go-errors/errors"
+)
+
+// SocksAddress returns the address of the SOCKS proxy Tor listens on
+func SocksAddress(t *tor.Tor) (string, error) {
+	info, err := t.Control.GetInfo("net/listeners/socks")
+	if err != nil {
+		return "", errors.Errorf("unable to get socks listener: %v", err)
+	}
+
+	if len(info) != 1 || info[0].Key != "net/listeners/socks" || info[0].Val == "" {
+		return "", errors.Errorf("unable to get socks listener")
+	}
+
+	return info[0].Val, nil
+}
+
+// ControlAddress returns the address of the Tor control port
+func ControlAddress(t *tor.Tor) (string, error) {
+	if t.ControlPort == 0 {
+		return "", errors.Errorf("no control port")
+	}
+
+	return fmt.Sprintf("127.0.0.1:%d", t.ControlPort), nil
+}
@@ -2,11 +2,16 @@ package pos
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/gobuffalo/packr/v2"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/the-lightning-land/sweetd/lightning"
 	"github.com/the-lightning-land/sweetd/nodeman"
+	"github.com/the-lightning-land/sweetd/pricing"
+	"github.com/the-lightning-land/sweetd/state"
+	"github.com/the-lightning-land/sweetd/sweetdb"
+	"io"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -19,6 +24,15 @@ var localhostOriginPattern = regexp.MustCompile(`^https?://localhost(:\d+)?$`)
 type Dispenser interface {
 	GetNodes() []nodeman.LightningNode
 	GetNode(id string) nodeman.LightningNode
+	GetProducts() ([]*sweetdb.Product, error)
+	GetProduct(id string) (*sweetdb.Product, error)
+	GetQuote(productId string) (*pricing.Quote, error)
+	GetActiveNodes() []nodeman.LightningNode
+	SelectNodes(msat int64) []nodeman.LightningNode
+	GetInvoiceNode(rHash string) nodeman.LightningNode
+	RegisterInvoice(invoice *lightning.Invoice, productId string, nodeId string) error
+	GetSlotStock(slot int) state.Stock
+	GetStock() state.Stock
 }
 
 type Config struct {
@@ -26,14 +40,6 @@ type Config struct {
 	Dispenser Dispenser
 }
 
-type Node struct {
-	lightning.Node
-	Id          string
-	Name        string
-	Description string
-	Order       int
-}
-
 type Handler struct {
 	http.Handler
 	log       Logger
@@ -60,6 +66,7 @@ func NewHandler(config *Config) *Handler {
 	api.Handle("/invoices/{rHash}/status", pos.handleStreamInvoiceStatus()).Methods(http.MethodGet, http.MethodOptions)
 	api.Handle("/invoices/{rHash}", pos.handleGetInvoice()).Methods(http.MethodGet, http.MethodOptions)
 	api.Handle("/invoices", pos.handleAddInvoice()).Methods(http.MethodPost, http.MethodOptions)
+	api.Handle("/products", pos.handleGetProducts()).Methods(http.MethodGet, http.MethodOptions)
 	api.Use(mux.CORSMethodMiddleware(api))
 
 	box := packr.New("web", "./out")
@@ -92,8 +99,9 @@ func (p *Handler) localhostMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// getActiveNode returns the started node with the highest priority
 func (p *Handler) getActiveNode() nodeman.LightningNode {
-	nodes := p.dispenser.GetNodes()
+	nodes := p.dispenser.GetActiveNodes()
 
 	if len(nodes) > 0 {
 		return nodes[0]
@@ -102,6 +110,16 @@ func (p *Handler) getActiveNode() nodeman.LightningNode {
 	return nil
 }
 
+// getInvoiceNode returns the node which issued an invoice, falling back
+// to the active node for invoices not issued by the point of sale
+func (p *Handler) getInvoiceNode(rHash string) nodeman.LightningNode {
+	if node := p.dispenser.GetInvoiceNode(rHash); node != nil {
+		return node
+	}
+
+	return p.getActiveNode()
+}
+
 func (p *Handler) availabilityMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if p.getActiveNode() == nil {
@@ -110,6 +128,13 @@ func (p *Handler) availabilityMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// only new invoices are refused, so paid invoices can still be followed
+		if r.Method == http.MethodPost && p.dispenser.GetStock() == state.StockEmpty {
+			p.log.Errorf("PoS request failed due to an empty dispenser")
+			p.jsonError(w, "Sold out, please come back after the dispenser was refilled", http.StatusServiceUnavailable)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -180,7 +205,7 @@ func (p *Handler) handleStreamInvoiceStatus() http.HandlerFunc {
 			ticker := time.NewTicker(54 * time.Second)
 			defer ticker.Stop()
 
-			client, err := p.getActiveNode().SubscribeInvoices()
+			client, err := p.getInvoiceNode(rHash).SubscribeInvoices()
 			if err != nil {
 				p.log.Errorf("Could not subscribe to invoices: %v", err)
 				return
@@ -203,7 +228,9 @@ func (p *Handler) handleStreamInvoiceStatus() http.HandlerFunc {
 					}
 
 					err := c.WriteJSON(&invoiceStatusMessage{
-						Settled: invoice.Settled,
+						Settled:  invoice.Settled,
+						Accepted: invoice.Accepted,
+						Canceled: invoice.Canceled,
 					})
 					if err != nil {
 						return
@@ -224,7 +251,7 @@ func (p *Handler) handleGetInvoice() http.HandlerFunc {
 		vars := mux.Vars(r)
 		rHash := vars["rHash"]
 
-		invoice, err := p.getActiveNode().GetInvoice(rHash)
+		invoice, err := p.getInvoiceNode(rHash).GetInvoice(rHash)
 		if err != nil {
 			p.jsonError(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -233,8 +260,11 @@ func (p *Handler) handleGetInvoice() http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 		err = json.NewEncoder(w).Encode(&invoiceMessage{
 			Settled:        invoice.Settled,
+			Accepted:       invoice.Accepted,
+			Canceled:       invoice.Canceled,
 			RHash:          invoice.RHash,
 			PaymentRequest: invoice.PaymentRequest,
+			MSat:           invoice.MSat,
 		})
 		if err != nil {
 			p.jsonError(w, err.Error(), http.StatusInternalServerError)
@@ -245,9 +275,79 @@ func (p *Handler) handleGetInvoice() http.HandlerFunc {
 
 func (p *Handler) handleAddInvoice() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		invoice, err := p.getActiveNode().AddInvoice(&lightning.InvoiceRequest{
-		})
+		req := addInvoiceRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil && err != io.EOF {
+			p.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		name := "Candy"
+		slot := 0
+
+		if req.Product != "" {
+			product, err := p.dispenser.GetProduct(req.Product)
+			if err != nil {
+				p.log.Errorf("Could not get product: %v", err)
+				p.jsonError(w, "Could not get the product", http.StatusInternalServerError)
+				return
+			}
+
+			if product == nil {
+				p.jsonError(w, fmt.Sprintf("No product with id %s found", req.Product), http.StatusNotFound)
+				return
+			}
+
+			name = product.Name
+			slot = product.Slot
+		}
+
+		quote, err := p.dispenser.GetQuote(req.Product)
+		if err != nil {
+			p.log.Errorf("Could not get quote: %v", err)
+			p.jsonError(w, "Could not determine the price at the moment", http.StatusServiceUnavailable)
+			return
+		}
+
+		if p.dispenser.GetSlotStock(slot) == state.StockEmpty {
+			p.jsonError(w, fmt.Sprintf("%s is sold out", name), http.StatusServiceUnavailable)
+			return
+		}
+
+		var invoice *lightning.Invoice
+		var node nodeman.LightningNode
+
+		// fail over to the next node if a node can't issue invoices
+		for _, candidate := range p.dispenser.SelectNodes(quote.MSat) {
+			// held payments are refunded if dispensing fails
+			holdNode, ok := candidate.(lightning.HoldInvoiceNode)
+
+			invoice, err = candidate.AddInvoice(&lightning.InvoiceRequest{
+				MSat: quote.MSat,
+				Memo: invoiceMemo(name, quote),
+				Hold: ok && holdNode.SupportsHoldInvoices(),
+			})
+			if err == nil {
+				node = candidate
+				break
+			}
+
+			p.log.Errorf("Could not add invoice on node %s: %v", candidate.ID(), err)
+		}
+
+		if node == nil {
+			p.jsonError(w, "No node could create an invoice at the moment", http.StatusServiceUnavailable)
+			return
+		}
+
+		err = p.dispenser.RegisterInvoice(invoice, req.Product, node.ID())
 		if err != nil {
+			// an unregistered invoice would be paid without dispensing
+			cancelErr := node.CancelInvoice(invoice.RHash)
+			if cancelErr != nil {
+				p.log.Errorf("Could not cancel unregistered invoice %s: %v", invoice.RHash, cancelErr)
+			}
+
 			p.jsonError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -257,6 +357,8 @@ func (p *Handler) handleAddInvoice() http.HandlerFunc {
 			Settled:        invoice.Settled,
 			RHash:          invoice.RHash,
 			PaymentRequest: invoice.PaymentRequest,
+			MSat:           invoice.MSat,
+			Fiat:           toFiatMessage(quote),
 		})
 		if err != nil {
 			p.jsonError(w, err.Error(), http.StatusInternalServerError)
@@ -265,12 +367,101 @@ func (p *Handler) handleAddInvoice() http.HandlerFunc {
 	}
 }
 
+func (p *Handler) handleGetProducts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		products, err := p.dispenser.GetProducts()
+		if err != nil {
+			p.jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		results := []*productMessage{}
+
+		for _, product := range products {
+			message := &productMessage{
+				Id:       product.Id,
+				Name:     product.Name,
+				ImageUrl: product.ImageUrl,
+				SoldOut:  p.dispenser.GetSlotStock(product.Slot) == state.StockEmpty,
+			}
+
+			quote, err := p.dispenser.GetQuote(product.Id)
+			if err != nil {
+				p.log.Errorf("Could not get quote for product %s: %v", product.Id, err)
+			} else {
+				message.MSat = quote.MSat
+				message.Fiat = toFiatMessage(quote)
+			}
+
+			results = append(results, message)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(&results)
+		if err != nil {
+			p.jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// invoiceMemo describes what a customer pays for
+func invoiceMemo(name string, quote *pricing.Quote) string {
+	if quote.Currency.Fiat() {
+		return fmt.Sprintf("%s for %s %s", name, formatMinorUnits(quote.Amount), quote.Currency)
+	}
+
+	return fmt.Sprintf("%s for %d satoshis", name, quote.MSat/1000)
+}
+
+// formatMinorUnits formats an amount in cents as a decimal string
+func formatMinorUnits(amount int64) string {
+	return fmt.Sprintf("%d.%02d", amount/100, amount%100)
+}
+
+func toFiatMessage(quote *pricing.Quote) *fiatMessage {
+	if !quote.Currency.Fiat() {
+		return nil
+	}
+
+	return &fiatMessage{
+		Currency: string(quote.Currency),
+		Amount:   formatMinorUnits(quote.Amount),
+		Rate:     quote.Rate,
+	}
+}
+
+type fiatMessage struct {
+	Currency string  `json:"currency"`
+	Amount   string  `json:"amount"`
+	Rate     float64 `json:"rate"`
+}
+
+type addInvoiceRequest struct {
+	Product string `json:"product"`
+}
+
+type productMessage struct {
+	Id       string       `json:"id"`
+	Name     string       `json:"name"`
+	ImageUrl string       `json:"image_url"`
+	MSat     int64        `json:"msat"`
+	Fiat     *fiatMessage `json:"fiat,omitempty"`
+	SoldOut  bool         `json:"sold_out"`
+}
+
 type invoiceMessage struct {
-	RHash          string `json:"r_hash"`
-	PaymentRequest string `json:"payment_request"`
-	Settled        bool   `json:"settled"`
+	RHash          string       `json:"r_hash"`
+	PaymentRequest string       `json:"payment_request"`
+	Settled        bool         `json:"settled"`
+	Accepted       bool         `json:"accepted"`
+	Canceled       bool         `json:"canceled"`
+	MSat           int64        `json:"msat"`
+	Fiat           *fiatMessage `json:"fiat,omitempty"`
 }
 
 type invoiceStatusMessage struct {
-	Settled bool `json:"settled"`
+	Settled  bool `json:"settled"`
+	Accepted bool `json:"accepted"`
+	Canceled bool `json:"canceled"`
 }
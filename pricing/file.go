@@ -0,0 +1,43 @@
+package pricing
+
+import (
+	"encoding/json"
+	"github.com/go-errors/errors"
+	"io/ioutil"
+)
+
+// FileRateProvider reads exchange rates from a JSON file which maps
+// currency codes to the price of one bitcoin, e.g. {"EUR": 6500.5}.
+// The file is read on every request, so it can be changed at runtime.
+type FileRateProvider struct {
+	path string
+}
+
+// Compile time check for protocol compatibility
+var _ RateProvider = (*FileRateProvider)(nil)
+
+func NewFileRateProvider(path string) *FileRateProvider {
+	return &FileRateProvider{
+		path: path,
+	}
+}
+
+func (p *FileRateProvider) GetRate(currency Currency) (float64, error) {
+	payload, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return 0, errors.Errorf("unable to read rates file: %v", err)
+	}
+
+	rates := map[Currency]float64{}
+	err = json.Unmarshal(payload, &rates)
+	if err != nil {
+		return 0, errors.Errorf("unable to parse rates file: %v", err)
+	}
+
+	rate, ok := rates[currency]
+	if !ok {
+		return 0, errors.Errorf("no rate for %s available", currency)
+	}
+
+	return rate, nil
+}
@@ -0,0 +1,119 @@
+package pricing
+
+import (
+	"encoding/json"
+	"github.com/go-errors/errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRatesUrl points to the CoinGecko simple price api
+	DefaultRatesUrl = "https://api.coingecko.com/api/v3/simple/price?ids=bitcoin&vs_currencies=eur,usd,chf"
+
+	defaultRatesMaxAge = time.Minute
+)
+
+// HttpRateProvider fetches exchange rates from an api that responds
+// in the format of the CoinGecko simple price api, e.g.
+// {"bitcoin": {"eur": 6500.5, "usd": 7200}}
+type HttpRateProvider struct {
+	url    string
+	client *http.Client
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	rates   map[Currency]float64
+	fetched time.Time
+}
+
+type HttpRateProviderConfig struct {
+	// Url of the rates api, uses CoinGecko by default
+	Url string
+
+	// Client used for requests, uses a client with a timeout by default
+	Client *http.Client
+
+	// MaxAge of cached rates before they are fetched again
+	MaxAge time.Duration
+}
+
+// Compile time check for protocol compatibility
+var _ RateProvider = (*HttpRateProvider)(nil)
+
+func NewHttpRateProvider(config *HttpRateProviderConfig) *HttpRateProvider {
+	provider := &HttpRateProvider{
+		url:    config.Url,
+		client: config.Client,
+		maxAge: config.MaxAge,
+	}
+
+	if provider.url == "" {
+		provider.url = DefaultRatesUrl
+	}
+
+	if provider.client == nil {
+		provider.client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if provider.maxAge == 0 {
+		provider.maxAge = defaultRatesMaxAge
+	}
+
+	return provider
+}
+
+func (p *HttpRateProvider) GetRate(currency Currency) (float64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.rates == nil || time.Since(p.fetched) > p.maxAge {
+		rates, err := p.fetch()
+		if err != nil {
+			return 0, err
+		}
+
+		p.rates = rates
+		p.fetched = time.Now()
+	}
+
+	rate, ok := p.rates[currency]
+	if !ok {
+		return 0, errors.Errorf("no rate for %s available", currency)
+	}
+
+	return rate, nil
+}
+
+func (p *HttpRateProvider) fetch() (map[Currency]float64, error) {
+	res, err := p.client.Get(p.url)
+	if err != nil {
+		return nil, errors.Errorf("unable to fetch rates: %v", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unable to fetch rates: %s", res.Status)
+	}
+
+	body := map[string]map[string]float64{}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		return nil, errors.Errorf("unable to decode rates: %v", err)
+	}
+
+	bitcoin, ok := body["bitcoin"]
+	if !ok {
+		return nil, errors.Errorf("no bitcoin rates in response")
+	}
+
+	rates := map[Currency]float64{}
+	for code, rate := range bitcoin {
+		rates[Currency(strings.ToUpper(code))] = rate
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,93 @@
+package pricing
+
+import (
+	"github.com/go-errors/errors"
+	"math"
+	"strings"
+)
+
+type Currency string
+
+const (
+	CurrencySat Currency = "SAT"
+	CurrencyEur Currency = "EUR"
+	CurrencyUsd Currency = "USD"
+	CurrencyChf Currency = "CHF"
+)
+
+// ParseCurrency returns the currency for the given code, regardless of
+// its case
+func ParseCurrency(code string) (Currency, error) {
+	switch currency := Currency(strings.ToUpper(code)); currency {
+	case CurrencySat, CurrencyEur, CurrencyUsd, CurrencyChf:
+		return currency, nil
+	default:
+		return "", errors.Errorf("unsupported currency %s", code)
+	}
+}
+
+// Fiat returns true if the currency is a fiat currency which needs
+// to be converted at invoice time
+func (c Currency) Fiat() bool {
+	return c != CurrencySat
+}
+
+// Price is either a fixed amount of satoshis or an amount of a fiat
+// currency in its minor unit, e.g. cents
+type Price struct {
+	Currency Currency
+	Amount   int64
+}
+
+// Quote is a price converted into millisatoshis
+type Quote struct {
+	MSat     int64
+	Currency Currency
+	Amount   int64
+	Rate     float64
+}
+
+// RateProvider returns the current exchange rate of one bitcoin in the
+// given fiat currency
+type RateProvider interface {
+	GetRate(currency Currency) (float64, error)
+}
+
+// NewQuote converts the price into millisatoshis, using the given rate
+// provider for fiat prices. Fiat prices are rounded up to full satoshis.
+func NewQuote(price *Price, rates RateProvider) (*Quote, error) {
+	if price.Amount <= 0 {
+		return nil, errors.Errorf("price must be positive")
+	}
+
+	if !price.Currency.Fiat() {
+		return &Quote{
+			MSat:     price.Amount * 1000,
+			Currency: CurrencySat,
+			Amount:   price.Amount,
+		}, nil
+	}
+
+	if rates == nil {
+		return nil, errors.Errorf("no exchange rate provider available")
+	}
+
+	rate, err := rates.GetRate(price.Currency)
+	if err != nil {
+		return nil, errors.Errorf("unable to get %s rate: %v", price.Currency, err)
+	}
+
+	if rate <= 0 {
+		return nil, errors.Errorf("invalid %s rate %v", price.Currency, rate)
+	}
+
+	// minor unit to bitcoin to satoshis
+	sat := math.Ceil(float64(price.Amount) / 100 / rate * 1e8)
+
+	return &Quote{
+		MSat:     int64(sat) * 1000,
+		Currency: price.Currency,
+		Amount:   price.Amount,
+		Rate:     rate,
+	}, nil
+}
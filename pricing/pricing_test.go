@@ -0,0 +1,82 @@
+package pricing
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestQuoteSat(t *testing.T) {
+	t.Parallel()
+
+	quote, err := NewQuote(&Price{Currency: CurrencySat, Amount: 8}, nil)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(8000), quote.MSat)
+}
+
+func TestQuoteFiatFromFile(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "rates")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rates.json")
+	err = ioutil.WriteFile(path, []byte(`{"EUR": 8000}`), 0600)
+	assert.Equal(t, nil, err)
+
+	// 0.50 EUR at 8000 EUR per bitcoin are 6250 satoshis
+	quote, err := NewQuote(&Price{Currency: CurrencyEur, Amount: 50}, NewFileRateProvider(path))
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(6250000), quote.MSat)
+	assert.Equal(t, float64(8000), quote.Rate)
+}
+
+func TestQuoteFiatFromHttp(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"bitcoin":{"eur":8000,"usd":10000,"chf":9000}}`))
+	}))
+	defer server.Close()
+
+	provider := NewHttpRateProvider(&HttpRateProviderConfig{
+		Url: server.URL,
+	})
+
+	quote, err := NewQuote(&Price{Currency: CurrencyUsd, Amount: 100}, provider)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(10000000), quote.MSat)
+
+	// rates are cached in between requests
+	quote, err = NewQuote(&Price{Currency: CurrencyChf, Amount: 1}, provider)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(112000), quote.MSat)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestQuoteUnknownRate(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"bitcoin":{"eur":8000}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewQuote(&Price{Currency: CurrencyChf, Amount: 100}, NewHttpRateProvider(&HttpRateProviderConfig{
+		Url: server.URL,
+	}))
+
+	assert.NotEqual(t, nil, err)
+}
@@ -0,0 +1,341 @@
+package qrcode
+
+import (
+	"bytes"
+	"github.com/go-errors/errors"
+	"github.com/stretchr/testify/assert"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomonRemainder(t *testing.T) {
+	t.Parallel()
+
+	// codewords of HELLO WORLD at version 1-M
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	ecc := reedSolomonRemainder(data, reedSolomonDivisor(10))
+
+	assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, ecc)
+}
+
+func TestFormatBits(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 0x77c4, formatBits(LevelL, 0))
+	assert.Equal(t, 0x5412, formatBits(LevelM, 0))
+	assert.Equal(t, 0x355f, formatBits(LevelQ, 0))
+	assert.Equal(t, 0x1689, formatBits(LevelH, 0))
+}
+
+func TestCapacities(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 19, numDataCodewords(1, LevelL))
+	assert.Equal(t, 274, numDataCodewords(10, LevelL))
+	assert.Equal(t, 2956, numDataCodewords(40, LevelL))
+	assert.Equal(t, 2334, numDataCodewords(40, LevelM))
+	assert.Equal(t, []int{6, 34, 60, 86, 112, 138}, alignmentPatternPositions(32))
+}
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	code, err := Encode([]byte("Hello, world!"), LevelL)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 21, code.Size)
+
+	// finder pattern corners and the always dark module
+	assert.True(t, code.Dark(0, 0))
+	assert.True(t, code.Dark(20, 0))
+	assert.True(t, code.Dark(0, 20))
+	assert.False(t, code.Dark(7, 7))
+	assert.True(t, code.Dark(8, 13))
+
+	code, err = Encode(bytes.Repeat([]byte{'a'}, 2000), LevelM)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 38*4+17, code.Size)
+
+	_, err = Encode(bytes.Repeat([]byte{'a'}, 3000), LevelL)
+	assert.NotEqual(t, nil, err)
+}
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	code, err := Encode([]byte("lndconnect://localhost:10009"), LevelM)
+	assert.Equal(t, nil, err)
+
+	assert.True(t, strings.HasPrefix(string(code.SVG()), "<svg"))
+
+	data, err := code.PNG(4)
+	assert.Equal(t, nil, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, (code.Size+8)*4, img.Bounds().Dx())
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	// lndconnect URIs carry a base64 certificate and macaroon and easily
+	// exceed one kilobyte
+	uri := "lndconnect://sweetd.local:10009?cert=" + strings.Repeat("MIICJzCCAc2gAwIBAgIRA", 45) +
+		"&macaroon=" + strings.Repeat("AgEDbG5kAoQBAwoQ", 15)
+
+	tests := []struct {
+		data    string
+		level   Level
+		version int
+	}{
+		{"sweetd", LevelM, 1},
+		{strings.Repeat("x", 250), LevelL, 10},
+		{uri, LevelL, 25},
+		{strings.Repeat("x", 2300), LevelM, 40},
+	}
+
+	for _, test := range tests {
+		code, err := Encode([]byte(test.data), test.level)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, test.version*4+17, code.Size)
+
+		data, err := decode(code)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, test.data, string(data))
+	}
+}
+
+// alignment pattern centers of the versions decoded above
+var testAlignmentPositions = map[int][]int{
+	1:  {},
+	10: {6, 28, 50},
+	25: {6, 32, 58, 84, 110},
+	40: {6, 30, 58, 86, 114, 142, 170},
+}
+
+// decode reads the byte mode payload of a code the way a scanner would,
+// relying only on the modules and the tables of the specification
+func decode(code *Code) ([]byte, error) {
+	size := code.Size
+	version := (size - 17) / 4
+
+	// format bits, once around the top left finder and once split
+	// between the other two
+	first, second := 0, 0
+	for i := 0; i < 15; i++ {
+		var x, y int
+		switch {
+		case i <= 5:
+			x, y = 8, i
+		case i <= 7:
+			x, y = 8, i+1
+		case i == 8:
+			x, y = 7, 8
+		default:
+			x, y = 14-i, 8
+		}
+		if code.Dark(x, y) {
+			first |= 1 << uint(i)
+		}
+
+		if i < 8 {
+			x, y = size-1-i, 8
+		} else {
+			x, y = 8, size-15+i
+		}
+		if code.Dark(x, y) {
+			second |= 1 << uint(i)
+		}
+	}
+
+	if first != second {
+		return nil, errors.Errorf("format copies differ: %x != %x", first, second)
+	}
+
+	if bchRemainder(first^0x5412, 0x537, 10) != 0 {
+		return nil, errors.Errorf("invalid format bits %x", first)
+	}
+
+	level := []Level{LevelM, LevelL, LevelH, LevelQ}[(first^0x5412)>>13]
+	mask := (first ^ 0x5412) >> 10 & 7
+
+	if version >= 7 {
+		bits := 0
+		for i := 0; i < 18; i++ {
+			if code.Dark(size-11+i%3, i/3) {
+				bits |= 1 << uint(i)
+			}
+		}
+
+		if bits>>12 != version || bchRemainder(bits, 0x1F25, 12) != 0 {
+			return nil, errors.Errorf("invalid version bits %x", bits)
+		}
+	}
+
+	positions, ok := testAlignmentPositions[version]
+	if !ok {
+		return nil, errors.Errorf("unknown alignment of version %d", version)
+	}
+
+	function := func(x int, y int) bool {
+		switch {
+		case x < 9 && y < 9, x >= size-8 && y < 9, x < 9 && y >= size-8:
+			// finders, separators, format bits and the dark module
+			return true
+		case x == 6 || y == 6:
+			return true
+		case version >= 7 && (x >= size-11 && x < size-8 && y < 6 || y >= size-11 && y < size-8 && x < 6):
+			return true
+		}
+
+		last := len(positions) - 1
+		for i, ax := range positions {
+			for j, ay := range positions {
+				corner := i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0
+				if !corner && abs(x-ax) <= 2 && abs(y-ay) <= 2 {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	masked := func(x int, y int) bool {
+		switch mask {
+		case 0:
+			return (x+y)%2 == 0
+		case 1:
+			return y%2 == 0
+		case 2:
+			return x%3 == 0
+		case 3:
+			return (x+y)%3 == 0
+		case 4:
+			return (x/3+y/2)%2 == 0
+		case 5:
+			return x*y%2+x*y%3 == 0
+		case 6:
+			return (x*y%2+x*y%3)%2 == 0
+		default:
+			return ((x+y)%2+x*y%3)%2 == 0
+		}
+	}
+
+	var bits []bool
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+
+		upward := (right+1)&2 == 0
+
+		for vert := 0; vert < size; vert++ {
+			y := vert
+			if upward {
+				y = size - 1 - vert
+			}
+
+			for x := right; x > right-2; x-- {
+				if !function(x, y) {
+					bits = append(bits, code.Dark(x, y) != masked(x, y))
+				}
+			}
+		}
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i := range codewords {
+		for _, set := range bits[i*8 : i*8+8] {
+			codewords[i] <<= 1
+			if set {
+				codewords[i] |= 1
+			}
+		}
+	}
+
+	numBlocks := numErrorCorrectionBlocks[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	numShortBlocks := numBlocks - len(codewords)%numBlocks
+	shortDataLen := len(codewords)/numBlocks - eccLen
+
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= shortDataLen; i++ {
+		for j := range blocks {
+			if i < shortDataLen || j >= numShortBlocks {
+				blocks[j] = append(blocks[j], codewords[k])
+				k++
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], codewords[k])
+			k++
+		}
+	}
+
+	var data []byte
+	for j, block := range blocks {
+		// a valid block evaluates to zero at every root of the generator
+		root := byte(1)
+		for i := 0; i < eccLen; i++ {
+			syndrome := byte(0)
+			for _, c := range block {
+				syndrome = reedSolomonMultiply(syndrome, root) ^ c
+			}
+
+			if syndrome != 0 {
+				return nil, errors.Errorf("block %d fails syndrome %d", j, i)
+			}
+
+			root = reedSolomonMultiply(root, 2)
+		}
+
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+
+	offset := 0
+	read := func(length int) int {
+		value := 0
+		for i := 0; i < length; i++ {
+			value = value<<1 | int(data[offset/8]>>uint(7-offset%8)&1)
+			offset++
+		}
+		return value
+	}
+
+	if mode := read(4); mode != 0x4 {
+		return nil, errors.Errorf("unexpected mode %x", mode)
+	}
+
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+
+	count := read(countBits)
+	if offset+count*8 > len(data)*8 {
+		return nil, errors.Errorf("count %d exceeds the data", count)
+	}
+
+	result := make([]byte, count)
+	for i := range result {
+		result[i] = byte(read(8))
+	}
+
+	return result, nil
+}
+
+// bchRemainder divides value by the generator polynomial of the given degree
+func bchRemainder(value int, generator int, degree int) int {
+	for i := 17; i >= degree; i-- {
+		if value>>uint(i)&1 != 0 {
+			value ^= generator << uint(i-degree)
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,66 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// quietZone is the number of light modules around the code
+const quietZone = 4
+
+// SVG renders the code as a scalable image with one unit per module
+func (c *Code) SVG() []byte {
+	var buf bytes.Buffer
+
+	dimension := c.Size + quietZone*2
+
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" viewBox="0 0 %d %d" stroke="none">`, dimension, dimension)
+	buf.WriteString(`<rect width="100%" height="100%" fill="#FFFFFF"/>`)
+	buf.WriteString(`<path d="`)
+
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				fmt.Fprintf(&buf, "M%d,%dh1v1h-1z", x+quietZone, y+quietZone)
+			}
+		}
+	}
+
+	buf.WriteString(`" fill="#000000"/></svg>`)
+
+	return buf.Bytes()
+}
+
+// PNG renders the code as an image with scale pixels per module
+func (c *Code) PNG(scale int) ([]byte, error) {
+	if scale < 1 {
+		scale = 1
+	}
+
+	dimension := (c.Size + quietZone*2) * scale
+	img := image.NewGray(image.Rect(0, 0, dimension, dimension))
+
+	for y := 0; y < dimension; y++ {
+		for x := 0; x < dimension; x++ {
+			value := uint8(0xff)
+
+			if c.Dark(x/scale-quietZone, y/scale-quietZone) {
+				value = 0
+			}
+
+			img.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+
+	var buf bytes.Buffer
+
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,112 @@
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"github.com/go-errors/errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const keySize = 32
+
+type Config struct {
+	// KeyFile holds a random key which is created if it doesn't exist
+	KeyFile string
+	// DeviceId binds sealed secrets to the device, so copying the key file
+	// and database to another device doesn't reveal them
+	DeviceId string
+}
+
+// Sealer encrypts secrets with a key derived from a key file and the
+// identity of the device
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+func New(config *Config) (*Sealer, error) {
+	fileKey, err := readOrCreateKey(config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, fileKey)
+	mac.Write([]byte(config.DeviceId))
+	key := mac.Sum(nil)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Errorf("unable to create cipher: %v", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Errorf("unable to create gcm: %v", err)
+	}
+
+	return &Sealer{
+		aead: aead,
+	}, nil
+}
+
+func readOrCreateKey(path string) ([]byte, error) {
+	key, err := ioutil.ReadFile(path)
+	if err == nil {
+		if len(key) != keySize {
+			return nil, errors.Errorf("key file %s has %d instead of %d bytes", path, len(key), keySize)
+		}
+
+		return key, nil
+	}
+
+	if !os.IsNotExist(err) {
+		return nil, errors.Errorf("unable to read key file: %v", err)
+	}
+
+	key = make([]byte, keySize)
+
+	_, err = io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return nil, errors.Errorf("unable to generate key: %v", err)
+	}
+
+	err = ioutil.WriteFile(path, key, 0600)
+	if err != nil {
+		return nil, errors.Errorf("unable to write key file: %v", err)
+	}
+
+	return key, nil
+}
+
+// Seal encrypts and authenticates a secret, the random nonce is prepended
+// to the result
+func (s *Sealer) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, errors.Errorf("unable to generate nonce: %v", err)
+	}
+
+	return s.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts a secret sealed on the same device with the same key file
+func (s *Sealer) Open(sealed []byte) ([]byte, error) {
+	nonceSize := s.aead.NonceSize()
+
+	if len(sealed) < nonceSize {
+		return nil, errors.Errorf("sealed secret too short")
+	}
+
+	plaintext, err := s.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return nil, errors.Errorf("unable to open sealed secret: %v", err)
+	}
+
+	return plaintext, nil
+}
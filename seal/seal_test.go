@@ -0,0 +1,41 @@
+package seal
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "seal")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "seal.key")
+
+	sealer, err := New(&Config{KeyFile: keyFile, DeviceId: "00000000a1b2c3d4"})
+	assert.Equal(t, nil, err)
+
+	sealed, err := sealer.Seal([]byte("password"))
+	assert.Equal(t, nil, err)
+	assert.NotContains(t, string(sealed), "password")
+
+	// the key file is reused once created
+	sealer, err = New(&Config{KeyFile: keyFile, DeviceId: "00000000a1b2c3d4"})
+	assert.Equal(t, nil, err)
+
+	plaintext, err := sealer.Open(sealed)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "password", string(plaintext))
+
+	// another device can't open it with the same key file
+	other, err := New(&Config{KeyFile: keyFile, DeviceId: "00000000e5f6a7b8"})
+	assert.Equal(t, nil, err)
+
+	_, err = other.Open(sealed)
+	assert.NotEqual(t, nil, err)
+}
@@ -12,3 +12,12 @@ const (
 func String(state State) string {
 	return string(state)
 }
+
+// Stock is the estimated fill level of the dispenser
+type Stock string
+
+const (
+	StockOk    Stock = "ok"
+	StockLow   Stock = "low"
+	StockEmpty Stock = "empty"
+)
@@ -0,0 +1,41 @@
+package sweetdb
+
+import (
+	"time"
+)
+
+var (
+	// channelBackupBucket holds the latest static channel backup per
+	// node, keyed by node id
+	channelBackupBucket = []byte("channelBackup")
+)
+
+// ChannelBackup is a multi channel backup of a node. It is stored as lnd
+// exports it, encrypted with a key derived from the wallet seed, so it
+// can be restored on any device that knows the seed.
+type ChannelBackup struct {
+	Time            time.Time `json:"time"`
+	MultiChanBackup []byte    `json:"multiChanBackup,omitempty"`
+	// Sealed is a backup which was saved bound to the device key by
+	// earlier versions
+	Sealed []byte `json:"sealed,omitempty"`
+}
+
+// SetChannelBackup replaces the saved channel backup of a node. Backups
+// are kept when a node is removed, as they might be the only way to
+// recover its channel funds.
+func (db *DB) SetChannelBackup(nodeId string, backup *ChannelBackup) error {
+	return db.setJSON(channelBackupBucket, []byte(nodeId), backup)
+}
+
+// GetChannelBackup returns the saved channel backup of a node or nil if
+// none was saved yet
+func (db *DB) GetChannelBackup(nodeId string) (*ChannelBackup, error) {
+	var backup *ChannelBackup
+
+	if err := db.getJSON(channelBackupBucket, []byte(nodeId), &backup); err != nil {
+		return nil, err
+	}
+
+	return backup, nil
+}
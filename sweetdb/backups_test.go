@@ -0,0 +1,34 @@
+package sweetdb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestChannelBackupKeptAfterRemoval(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "sweetdb")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(dir)
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	backup, err := db.GetChannelBackup("node")
+	assert.Equal(t, nil, err)
+	assert.Nil(t, backup)
+
+	savedAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Equal(t, nil, db.SetChannelBackup("node", &ChannelBackup{Time: savedAt, MultiChanBackup: []byte{1, 2, 3}}))
+	assert.Equal(t, nil, db.RemoveNode("node"))
+
+	backup, err = db.GetChannelBackup("node")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []byte{1, 2, 3}, backup.MultiChanBackup)
+	assert.True(t, savedAt.Equal(backup.Time))
+}
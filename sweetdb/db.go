@@ -46,6 +46,11 @@ func Open(dbPath string) (*DB, error) {
 		dbPath: dbPath,
 	}
 
+	if err := sweetDB.indexDispenseQueue(); err != nil {
+		bdb.Close()
+		return nil, err
+	}
+
 	return sweetDB, nil
 }
 
@@ -0,0 +1,92 @@
+package sweetdb
+
+import (
+	"encoding/json"
+	"github.com/go-errors/errors"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var (
+	// inventoryBucket holds the fill level of every motor slot keyed by
+	// the big endian slot number
+	inventoryBucket = []byte("inventory")
+)
+
+// Inventory tracks how much was dispensed from a slot since it was
+// last refilled
+type Inventory struct {
+	Slot int `json:"slot"`
+	// Capacity is the amount of grams in the slot when it is refilled
+	Capacity float64 `json:"capacity"`
+	// GramsPerSecond is the calibrated rate at which the motor dispenses
+	GramsPerSecond float64 `json:"gramsPerSecond"`
+	// LowThreshold is the amount of grams below which stock is low
+	LowThreshold float64 `json:"lowThreshold"`
+	// RunTime is the accumulated motor run time since the last refill
+	RunTime  time.Duration `json:"runTime"`
+	Refilled time.Time     `json:"refilled"`
+}
+
+func slotKey(slot int) []byte {
+	key := make([]byte, 8)
+	byteOrder.PutUint64(key, uint64(slot))
+	return key
+}
+
+// GetInventory returns the inventory of a slot or nil if it was never
+// tracked
+func (db *DB) GetInventory(slot int) (*Inventory, error) {
+	var inventory *Inventory
+
+	if err := db.getJSON(inventoryBucket, slotKey(slot), &inventory); err != nil {
+		return nil, err
+	}
+
+	return inventory, nil
+}
+
+// AddInventoryRunTime adds motor run time to the inventory of a slot,
+// starting to track the slot if it wasn't tracked before
+func (db *DB) AddInventoryRunTime(slot int, runTime time.Duration) error {
+	_, err := db.UpdateInventory(slot, func(inventory *Inventory) {
+		inventory.RunTime += runTime
+	})
+
+	return err
+}
+
+// UpdateInventory changes the inventory of a slot within a single
+// transaction, so concurrent updates of the same slot aren't lost, and
+// returns the saved inventory
+func (db *DB) UpdateInventory(slot int, update func(inventory *Inventory)) (*Inventory, error) {
+	inventory := &Inventory{Slot: slot}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(inventoryBucket)
+		if err != nil {
+			return err
+		}
+
+		if payload := bucket.Get(slotKey(slot)); payload != nil {
+			err := json.Unmarshal(payload, inventory)
+			if err != nil {
+				return errors.Errorf("unable to unmarshal inventory: %v", err)
+			}
+		}
+
+		update(inventory)
+
+		payload, err := json.Marshal(inventory)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(slotKey(slot), payload)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return inventory, nil
+}
@@ -0,0 +1,94 @@
+package sweetdb
+
+import (
+	"encoding/json"
+	"github.com/go-errors/errors"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var (
+	invoicesBucket = []byte("invoices")
+)
+
+// Invoice keeps track of what an invoice created by the point of sale
+// was issued for
+type Invoice struct {
+	RHash     string `json:"rHash"`
+	ProductId string `json:"productId"`
+	// NodeId of the node which issued the invoice
+	NodeId string `json:"nodeId"`
+	Slot   int    `json:"slot"`
+	MSat   int64  `json:"msat"`
+	// Preimage is set for hold invoices, which are settled only after
+	// a successful dispense
+	Preimage string    `json:"preimage"`
+	Created  time.Time `json:"created"`
+}
+
+func (db *DB) SaveInvoice(invoice *Invoice) error {
+	return db.setJSON(invoicesBucket, []byte(invoice.RHash), invoice)
+}
+
+// GetInvoice returns the invoice with the given payment hash or nil if
+// it wasn't issued by the point of sale
+func (db *DB) GetInvoice(rHash string) (*Invoice, error) {
+	var invoice *Invoice
+
+	if err := db.getJSON(invoicesBucket, []byte(rHash), &invoice); err != nil {
+		return nil, err
+	}
+
+	return invoice, nil
+}
+
+// PruneInvoices removes the invoices which were created before the given
+// time, paid or not, so the invoices of the point of sale don't grow
+// without bound
+func (db *DB) PruneInvoices(before time.Time) (int, error) {
+	pruned := 0
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(invoicesBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		// the bucket must not be changed while iterating over it
+		var expired [][]byte
+
+		err := bucket.ForEach(func(k, v []byte) error {
+			invoice := &Invoice{}
+
+			err := json.Unmarshal(v, invoice)
+			if err != nil {
+				return errors.Errorf("unable to unmarshal invoice: %v", err)
+			}
+
+			if invoice.Created.Before(before) {
+				expired = append(expired, append([]byte{}, k...))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			err := bucket.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+
+		pruned = len(expired)
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return pruned, nil
+}
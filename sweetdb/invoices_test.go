@@ -0,0 +1,51 @@
+package sweetdb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestInvoices(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "sweetdb")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(dir)
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	invoice, err := db.GetInvoice("unknown")
+	assert.Equal(t, nil, err)
+	assert.Nil(t, invoice)
+
+	now := time.Now()
+
+	old := &Invoice{RHash: "old", ProductId: "candy", NodeId: "node", Slot: 2, MSat: 1000, Created: now.Add(-48 * time.Hour)}
+	recent := &Invoice{RHash: "recent", NodeId: "node", Preimage: "preimage", Created: now}
+
+	assert.Equal(t, nil, db.SaveInvoice(old))
+	assert.Equal(t, nil, db.SaveInvoice(recent))
+
+	invoice, err = db.GetInvoice("old")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "candy", invoice.ProductId)
+	assert.Equal(t, 2, invoice.Slot)
+	assert.Equal(t, int64(1000), invoice.MSat)
+
+	pruned, err := db.PruneInvoices(now.Add(-24 * time.Hour))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, pruned)
+
+	invoice, err = db.GetInvoice("old")
+	assert.Equal(t, nil, err)
+	assert.Nil(t, invoice)
+
+	invoice, err = db.GetInvoice("recent")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "preimage", invoice.Preimage)
+}
@@ -12,25 +12,51 @@ var (
 type lightningNodeKind string
 
 const (
-	lightningNodeKindLocal  lightningNodeKind = "local"
-	lightningNodeKindRemote                   = "remote"
+	lightningNodeKindLocal     lightningNodeKind = "local"
+	lightningNodeKindRemote                      = "remote"
+	lightningNodeKindRemoteCln                   = "remote-cln"
+	lightningNodeKindLnbits                      = "lnbits"
 )
 
 type lightningNode struct {
 	Kind lightningNodeKind `json:"kind"`
+	// Priority orders nodes for issuing invoices, lowest first
+	Priority int `json:"priority"`
 }
 
 type LightningNode interface{}
 
+// RemoteLndNode is a node reachable over gRPC, nodes saved before their
+// network could be chosen have none and are on mainnet
 type RemoteLndNode struct {
 	lightningNode
 	Id       string `json:"id"`
 	Name     string `json:"name"`
 	Enabled  bool   `json:"enabled"`
-	Mainnet  bool   `json:"mainnet"`
+	Network  string `json:"network"`
 	Url      string `json:"url"`
 	Cert     []byte `json:"cert"`
 	Macaroon []byte `json:"macaroon"`
+	// Tor routes connections through Tor, which .onion urls always are
+	Tor bool `json:"tor"`
+}
+
+type RemoteClnNode struct {
+	lightningNode
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Network string `json:"network"`
+	Uri     string `json:"uri"`
+}
+
+type LnbitsNode struct {
+	lightningNode
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	Enabled    bool   `json:"enabled"`
+	Url        string `json:"url"`
+	InvoiceKey string `json:"invoiceKey"`
 }
 
 type LocalNode struct {
@@ -38,8 +64,24 @@ type LocalNode struct {
 	Id       string `json:"id"`
 	Name     string `json:"name"`
 	Enabled  bool   `json:"enabled"`
-	Mainnet  bool   `json:"mainnet"`
+	Network  string `json:"network"`
 	OnionKey []byte `json:"onionkey"`
+	// Backend is nil for nodes which use the default neutrino peers
+	Backend *LocalNodeBackend `json:"backend"`
+	// AutoUnlock unlocks the wallet on start with the sealed password
+	AutoUnlock     bool   `json:"autoUnlock"`
+	SealedPassword []byte `json:"sealedPassword"`
+}
+
+// LocalNodeBackend is the chain backend of a local node
+type LocalNodeBackend struct {
+	Kind           string   `json:"kind"`
+	Peers          []string `json:"peers"`
+	RpcHost        string   `json:"rpcHost"`
+	RpcUser        string   `json:"rpcUser"`
+	RpcPass        string   `json:"rpcPass"`
+	ZmqPubRawBlock string   `json:"zmqPubRawBlock"`
+	ZmqPubRawTx    string   `json:"zmqPubRawTx"`
 }
 
 func (db *DB) GetNodes() ([]LightningNode, error) {
@@ -72,6 +114,12 @@ func (db *DB) SaveNode(node LightningNode) error {
 	case *RemoteLndNode:
 		n.Kind = lightningNodeKindRemote
 		return db.setJSON(nodesBucket, []byte(n.Id), n)
+	case *RemoteClnNode:
+		n.Kind = lightningNodeKindRemoteCln
+		return db.setJSON(nodesBucket, []byte(n.Id), n)
+	case *LnbitsNode:
+		n.Kind = lightningNodeKindLnbits
+		return db.setJSON(nodesBucket, []byte(n.Id), n)
 	case *LocalNode:
 		n.Kind = lightningNodeKindLocal
 		return db.setJSON(nodesBucket, []byte(n.Id), n)
@@ -91,6 +139,12 @@ func (db *DB) RemoveNode(id string) error {
 			return err
 		}
 
+		if settleIndex := tx.Bucket(settleIndexBucket); settleIndex != nil {
+			if err := settleIndex.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	})
 }
@@ -113,6 +167,18 @@ func (db *DB) GetNode(id string) (LightningNode, error) {
 			return nil, err
 		}
 		return node, nil
+	case lightningNodeKindRemoteCln:
+		var node *RemoteClnNode
+		if err := db.getJSON(nodesBucket, []byte(id), &node); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case lightningNodeKindLnbits:
+		var node *LnbitsNode
+		if err := db.getJSON(nodesBucket, []byte(id), &node); err != nil {
+			return nil, err
+		}
+		return node, nil
 	case lightningNodeKindLocal:
 		var node *LocalNode
 		if err := db.getJSON(nodesBucket, []byte(id), &node); err != nil {
@@ -0,0 +1,45 @@
+package sweetdb
+
+import (
+	"time"
+)
+
+var (
+	dispensePolicyKey = []byte("dispensePolicy")
+)
+
+// Rounding describes how a computed dispense duration is aligned to
+// the rounding step of a dispense policy
+type Rounding string
+
+const (
+	RoundingNone    Rounding = "none"
+	RoundingUp      Rounding = "up"
+	RoundingDown    Rounding = "down"
+	RoundingNearest Rounding = "nearest"
+)
+
+// DispensePolicy turns the amount of a payment into a motor run time
+type DispensePolicy struct {
+	SatsPerSecond float64       `json:"satsPerSecond"`
+	MinDuration   time.Duration `json:"minDuration"`
+	MaxDuration   time.Duration `json:"maxDuration"`
+	Rounding      Rounding      `json:"rounding"`
+	RoundingStep  time.Duration `json:"roundingStep"`
+}
+
+func (db *DB) SetDispensePolicy(policy *DispensePolicy) error {
+	return db.setJSON(settingsBucket, dispensePolicyKey, policy)
+}
+
+// GetDispensePolicy returns the saved dispense policy or nil if none was
+// saved yet
+func (db *DB) GetDispensePolicy() (*DispensePolicy, error) {
+	var policy *DispensePolicy
+
+	if err := db.getJSON(settingsBucket, dispensePolicyKey, &policy); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,27 @@
+package sweetdb
+
+var (
+	priceKey = []byte("price")
+)
+
+// Price is either a fixed amount of satoshis, with currency SAT, or an
+// amount in the minor unit of a fiat currency like EUR, USD or CHF
+type Price struct {
+	Currency string `json:"currency"`
+	Amount   int64  `json:"amount"`
+}
+
+func (db *DB) SetPrice(price *Price) error {
+	return db.setJSON(settingsBucket, priceKey, price)
+}
+
+// GetPrice returns the saved price or nil if none was saved yet
+func (db *DB) GetPrice() (*Price, error) {
+	var price *Price
+
+	if err := db.getJSON(settingsBucket, priceKey, &price); err != nil {
+		return nil, err
+	}
+
+	return price, nil
+}
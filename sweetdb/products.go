@@ -0,0 +1,74 @@
+package sweetdb
+
+import (
+	"github.com/go-errors/errors"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	productsBucket = []byte("products")
+)
+
+// Product is an item of the product catalog which is dispensed
+// from a motor slot of the machine
+type Product struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Price    *Price `json:"price"`
+	ImageUrl string `json:"imageUrl"`
+	Slot     int    `json:"slot"`
+}
+
+func (db *DB) GetProducts() ([]*Product, error) {
+	keys, err := db.getKeys(productsBucket)
+	if err != nil {
+		return nil, errors.Errorf("unable to get keys: %v", err)
+	}
+
+	products := []*Product{}
+
+	for _, k := range keys {
+		id := string(k)
+		product, err := db.GetProduct(id)
+		if err != nil {
+			return nil, errors.Errorf("unable to get product with id %s: %v", id, err)
+		}
+
+		if product == nil {
+			return nil, errors.Errorf("unable to find product with id %s", id)
+		}
+
+		products = append(products, product)
+	}
+
+	return products, nil
+}
+
+func (db *DB) GetProduct(id string) (*Product, error) {
+	var product *Product
+
+	if err := db.getJSON(productsBucket, []byte(id), &product); err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+func (db *DB) SaveProduct(product *Product) error {
+	return db.setJSON(productsBucket, []byte(product.Id), product)
+}
+
+func (db *DB) RemoveProduct(id string) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(productsBucket)
+		if err != nil {
+			return err
+		}
+
+		if err := bucket.Delete([]byte(id)); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
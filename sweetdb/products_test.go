@@ -0,0 +1,51 @@
+package sweetdb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestProducts(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "sweetdb")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(dir)
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	products, err := db.GetProducts()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(products))
+
+	candy := &Product{Id: "candy", Name: "Candy", Slot: 1, Price: &Price{Currency: "SAT", Amount: 100}}
+	gum := &Product{Id: "gum", Name: "Gum", Slot: 2}
+
+	assert.Equal(t, nil, db.SaveProduct(candy))
+	assert.Equal(t, nil, db.SaveProduct(gum))
+
+	product, err := db.GetProduct("candy")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, candy, product)
+
+	gum.Slot = 3
+	assert.Equal(t, nil, db.SaveProduct(gum))
+
+	product, err = db.GetProduct("gum")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, product.Slot)
+
+	assert.Equal(t, nil, db.RemoveProduct("candy"))
+
+	product, err = db.GetProduct("candy")
+	assert.Equal(t, nil, err)
+	assert.Nil(t, product)
+
+	products, err = db.GetProducts()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*Product{gum}, products)
+}
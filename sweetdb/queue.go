@@ -0,0 +1,336 @@
+package sweetdb
+
+import (
+	"encoding/json"
+	"github.com/go-errors/errors"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var (
+	// dispenseQueueBucket holds all dispenses in the order they were
+	// enqueued, keyed by a big endian sequence number
+	dispenseQueueBucket = []byte("dispenseQueue")
+
+	// dispensedBucket is the set of invoice payment hashes which were
+	// enqueued for dispensing already, with the unix time they were
+	// enqueued at
+	dispensedBucket = []byte("dispensed")
+
+	// dispenseRHashBucket indexes the id of the latest dispense of an
+	// invoice by its payment hash
+	dispenseRHashBucket = []byte("dispenseRHash")
+)
+
+type DispenseState string
+
+const (
+	DispenseStatePending    DispenseState = "pending"
+	DispenseStateDispensing DispenseState = "dispensing"
+	DispenseStateDone       DispenseState = "done"
+	DispenseStateFailed     DispenseState = "failed"
+)
+
+// Dispense is an entry of the dispense queue
+type Dispense struct {
+	Id        uint64      `json:"id"`
+	Trigger   SaleTrigger `json:"trigger"`
+	RHash     string      `json:"rHash"`
+	MSat      int64       `json:"msat"`
+	NodeId    string      `json:"nodeId"`
+	ProductId string      `json:"productId"`
+	Slot      int         `json:"slot"`
+	// Duration overrides the duration derived from the amount if set
+	Duration time.Duration `json:"duration"`
+	State    DispenseState `json:"state"`
+	Created  time.Time     `json:"created"`
+	Updated  time.Time     `json:"updated"`
+}
+
+func dispenseKey(id uint64) []byte {
+	key := make([]byte, 8)
+	byteOrder.PutUint64(key, id)
+	return key
+}
+
+// EnqueueDispense appends a pending dispense to the end of the queue and
+// assigns its id. Dispenses of an invoice are only enqueued once, which is
+// reported by returning false.
+func (db *DB) EnqueueDispense(dispense *Dispense) (bool, error) {
+	enqueued := false
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(dispenseQueueBucket)
+		if err != nil {
+			return err
+		}
+
+		if dispense.RHash != "" {
+			dispensed, err := tx.CreateBucketIfNotExists(dispensedBucket)
+			if err != nil {
+				return err
+			}
+
+			if dispensed.Get([]byte(dispense.RHash)) != nil {
+				return nil
+			}
+
+			err = dispensed.Put([]byte(dispense.RHash), dispensedValue(time.Now()))
+			if err != nil {
+				return err
+			}
+		}
+
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return errors.Errorf("unable to get next sequence: %v", err)
+		}
+
+		dispense.Id = id
+		dispense.State = DispenseStatePending
+		dispense.Created = time.Now()
+		dispense.Updated = dispense.Created
+
+		payload, err := json.Marshal(dispense)
+		if err != nil {
+			return err
+		}
+
+		enqueued = true
+
+		if dispense.RHash != "" {
+			index, err := tx.CreateBucketIfNotExists(dispenseRHashBucket)
+			if err != nil {
+				return err
+			}
+
+			err = index.Put([]byte(dispense.RHash), dispenseKey(id))
+			if err != nil {
+				return err
+			}
+		}
+
+		return bucket.Put(dispenseKey(id), payload)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return enqueued, nil
+}
+
+func dispensedValue(t time.Time) []byte {
+	value := make([]byte, 8)
+	byteOrder.PutUint64(value, uint64(t.Unix()))
+	return value
+}
+
+// PruneDispensed forgets the invoices which were enqueued before the given
+// time, so the set of dispensed invoices doesn't grow without bound. It
+// has to reach back further than invoices can be replayed. Invoices saved
+// without a time are kept for as long from now on.
+func (db *DB) PruneDispensed(before time.Time) (int, error) {
+	pruned := 0
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(dispensedBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		// the bucket must not be changed while iterating over it
+		var expired, untimed [][]byte
+
+		err := bucket.ForEach(func(k, v []byte) error {
+			switch {
+			case len(v) != 8:
+				untimed = append(untimed, append([]byte{}, k...))
+			case int64(byteOrder.Uint64(v)) < before.Unix():
+				expired = append(expired, append([]byte{}, k...))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			err := bucket.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+
+		now := dispensedValue(time.Now())
+
+		for _, k := range untimed {
+			err := bucket.Put(k, now)
+			if err != nil {
+				return err
+			}
+		}
+
+		pruned = len(expired)
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return pruned, nil
+}
+
+// SetDispenseState updates the state of a queued dispense
+func (db *DB) SetDispenseState(dispense *Dispense, state DispenseState) error {
+	dispense.State = state
+	dispense.Updated = time.Now()
+
+	return db.setJSON(dispenseQueueBucket, dispenseKey(dispense.Id), dispense)
+}
+
+// GetDispenses returns all queued dispenses in the given state, in the
+// order they were enqueued
+func (db *DB) GetDispenses(state DispenseState) ([]*Dispense, error) {
+	dispenses := []*Dispense{}
+
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(dispenseQueueBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			dispense := &Dispense{}
+
+			err := json.Unmarshal(v, dispense)
+			if err != nil {
+				return errors.Errorf("unable to unmarshal dispense: %v", err)
+			}
+
+			if dispense.State == state {
+				dispenses = append(dispenses, dispense)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dispenses, nil
+}
+
+// NextPendingDispense returns the oldest pending dispense with an id
+// greater than after or nil if no such dispense is pending. As dispenses
+// are processed in order, passing the id of the last processed dispense
+// skips the history of the queue.
+func (db *DB) NextPendingDispense(after uint64) (*Dispense, error) {
+	var next *Dispense
+
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(dispenseQueueBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+
+		for k, v := c.Seek(dispenseKey(after + 1)); k != nil; k, v = c.Next() {
+			dispense := &Dispense{}
+
+			err := json.Unmarshal(v, dispense)
+			if err != nil {
+				return errors.Errorf("unable to unmarshal dispense: %v", err)
+			}
+
+			if dispense.State == DispenseStatePending {
+				next = dispense
+				return nil
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// GetDispenseByRHash returns the latest queued dispense of an invoice or
+// nil if the invoice was never queued
+func (db *DB) GetDispenseByRHash(rHash string) (*Dispense, error) {
+	var found *Dispense
+
+	err := db.View(func(tx *bolt.Tx) error {
+		index := tx.Bucket(dispenseRHashBucket)
+		bucket := tx.Bucket(dispenseQueueBucket)
+		if index == nil || bucket == nil {
+			return nil
+		}
+
+		key := index.Get([]byte(rHash))
+		if key == nil {
+			return nil
+		}
+
+		payload := bucket.Get(key)
+		if payload == nil {
+			return nil
+		}
+
+		found = &Dispense{}
+
+		err := json.Unmarshal(payload, found)
+		if err != nil {
+			return errors.Errorf("unable to unmarshal dispense: %v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// indexDispenseQueue indexes the queued dispenses by payment hash once, for
+// databases which were created before the index existed
+func (db *DB) indexDispenseQueue() error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(dispenseRHashBucket) != nil {
+			return nil
+		}
+
+		index, err := tx.CreateBucket(dispenseRHashBucket)
+		if err != nil {
+			return err
+		}
+
+		bucket := tx.Bucket(dispenseQueueBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			dispense := &Dispense{}
+
+			err := json.Unmarshal(v, dispense)
+			if err != nil {
+				return errors.Errorf("unable to unmarshal dispense: %v", err)
+			}
+
+			if dispense.RHash == "" {
+				return nil
+			}
+
+			// later dispenses of an invoice overwrite earlier ones
+			return index.Put([]byte(dispense.RHash), append([]byte{}, k...))
+		})
+	})
+}
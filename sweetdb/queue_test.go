@@ -0,0 +1,160 @@
+package sweetdb
+
+import (
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDispenseQueueOrder(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "sweetdb")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(dir)
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	first := &Dispense{RHash: "first", MSat: 1000}
+	second := &Dispense{RHash: "second", MSat: 2000}
+
+	_, err = db.EnqueueDispense(first)
+	assert.Equal(t, nil, err)
+	_, err = db.EnqueueDispense(second)
+	assert.Equal(t, nil, err)
+
+	next, err := db.NextPendingDispense(0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "first", next.RHash)
+
+	assert.Equal(t, nil, db.SetDispenseState(next, DispenseStateDone))
+
+	next, err = db.NextPendingDispense(0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "second", next.RHash)
+
+	// dispenses up to the cursor are skipped
+	skipped, err := db.NextPendingDispense(next.Id)
+	assert.Equal(t, nil, err)
+	assert.Nil(t, skipped)
+
+	assert.Equal(t, nil, db.SetDispenseState(next, DispenseStateDispensing))
+
+	next, err = db.NextPendingDispense(first.Id)
+	assert.Equal(t, nil, err)
+	assert.Nil(t, next)
+
+	dispensing, err := db.GetDispenses(DispenseStateDispensing)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(dispensing))
+	assert.Equal(t, "second", dispensing[0].RHash)
+}
+
+func TestDispenseQueueOncePerInvoice(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "sweetdb")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(dir)
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	enqueued, err := db.EnqueueDispense(&Dispense{RHash: "paid", MSat: 1000})
+	assert.Equal(t, nil, err)
+	assert.True(t, enqueued)
+
+	enqueued, err = db.EnqueueDispense(&Dispense{RHash: "paid", MSat: 1000})
+	assert.Equal(t, nil, err)
+	assert.False(t, enqueued)
+
+	pending, err := db.GetDispenses(DispenseStatePending)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(pending))
+}
+
+func TestPruneDispensed(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "sweetdb")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(dir)
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	_, err = db.EnqueueDispense(&Dispense{RHash: "paid", MSat: 1000})
+	assert.Equal(t, nil, err)
+
+	pruned, err := db.PruneDispensed(time.Now().Add(-time.Hour))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, pruned)
+
+	enqueued, err := db.EnqueueDispense(&Dispense{RHash: "paid", MSat: 1000})
+	assert.Equal(t, nil, err)
+	assert.False(t, enqueued)
+
+	pruned, err = db.PruneDispensed(time.Now().Add(time.Minute))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, pruned)
+
+	// a pruned invoice isn't known to be dispensed anymore
+	enqueued, err = db.EnqueueDispense(&Dispense{RHash: "paid", MSat: 1000})
+	assert.Equal(t, nil, err)
+	assert.True(t, enqueued)
+}
+
+func TestGetDispenseByRHash(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "sweetdb")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(dir)
+	assert.Equal(t, nil, err)
+
+	missing, err := db.GetDispenseByRHash("first")
+	assert.Equal(t, nil, err)
+	assert.Nil(t, missing)
+
+	first := &Dispense{RHash: "first", MSat: 1000}
+	second := &Dispense{RHash: "second", MSat: 2000}
+
+	_, err = db.EnqueueDispense(first)
+	assert.Equal(t, nil, err)
+	_, err = db.EnqueueDispense(second)
+	assert.Equal(t, nil, err)
+	_, err = db.EnqueueDispense(&Dispense{Trigger: SaleTriggerAdmin})
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, nil, db.SetDispenseState(first, DispenseStateDone))
+
+	found, err := db.GetDispenseByRHash("first")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, first.Id, found.Id)
+	assert.Equal(t, DispenseStateDone, found.State)
+
+	// databases from before the index are indexed when opened
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket(dispenseRHashBucket)
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, db.Close())
+
+	db, err = Open(dir)
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	found, err = db.GetDispenseByRHash("second")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, second.Id, found.Id)
+	assert.Equal(t, int64(2000), found.MSat)
+}
@@ -0,0 +1,133 @@
+package sweetdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/go-errors/errors"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var (
+	// salesBucket is the ledger of all dispenses, keyed by the big endian
+	// unix time in nanoseconds followed by a sequence number, so records
+	// can be scanned by time ranges
+	salesBucket = []byte("sales")
+)
+
+type SaleTrigger string
+
+const (
+	SaleTriggerPayment SaleTrigger = "payment"
+	SaleTriggerTouch   SaleTrigger = "touch"
+	SaleTriggerAdmin   SaleTrigger = "admin"
+)
+
+// Sale is a record of a single dispense
+type Sale struct {
+	Time      time.Time     `json:"time"`
+	Trigger   SaleTrigger   `json:"trigger"`
+	RHash     string        `json:"rHash"`
+	MSat      int64         `json:"msat"`
+	NodeId    string        `json:"nodeId"`
+	ProductId string        `json:"productId"`
+	Slot      int           `json:"slot"`
+	RunTime   time.Duration `json:"runTime"`
+	// Failed is set if the dispense was interrupted, a paid sale that
+	// failed may have to be refunded by the operator
+	Failed bool `json:"failed"`
+}
+
+// SalesQuery filters sales by time and paginates the results
+type SalesQuery struct {
+	// From is the inclusive start time, zero for no lower bound
+	From time.Time
+	// To is the exclusive end time, zero for no upper bound
+	To time.Time
+	// Offset of the first sale to return
+	Offset int
+	// Limit of sales to return, zero for no limit
+	Limit int
+}
+
+func saleTimeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	byteOrder.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+func (db *DB) AddSale(sale *Sale) error {
+	payload, err := json.Marshal(sale)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(salesBucket)
+		if err != nil {
+			return err
+		}
+
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return errors.Errorf("unable to get next sequence: %v", err)
+		}
+
+		key := make([]byte, 16)
+		copy(key, saleTimeKey(sale.Time))
+		byteOrder.PutUint64(key[8:], seq)
+
+		return bucket.Put(key, payload)
+	})
+}
+
+// GetSales returns the sales matching the query in chronological order,
+// together with the total number of sales in the queried time range
+func (db *DB) GetSales(query *SalesQuery) ([]*Sale, int, error) {
+	sales := []*Sale{}
+	total := 0
+
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(salesBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+
+		var k, v []byte
+		if query.From.IsZero() {
+			k, v = c.First()
+		} else {
+			k, v = c.Seek(saleTimeKey(query.From))
+		}
+
+		for ; k != nil; k, v = c.Next() {
+			if !query.To.IsZero() && bytes.Compare(k[:8], saleTimeKey(query.To)) >= 0 {
+				break
+			}
+
+			total++
+
+			if total <= query.Offset || (query.Limit > 0 && len(sales) >= query.Limit) {
+				continue
+			}
+
+			sale := &Sale{}
+
+			err := json.Unmarshal(v, sale)
+			if err != nil {
+				return errors.Errorf("unable to unmarshal sale: %v", err)
+			}
+
+			sales = append(sales, sale)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return sales, total, nil
+}
@@ -0,0 +1,51 @@
+package sweetdb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGetSalesTimeRange(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "sweetdb")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	db, err := Open(dir)
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		err := db.AddSale(&Sale{
+			Time:    start.Add(time.Duration(i) * time.Hour),
+			Trigger: SaleTriggerPayment,
+			MSat:    int64(i) * 1000,
+		})
+		assert.Equal(t, nil, err)
+	}
+
+	sales, total, err := db.GetSales(&SalesQuery{
+		From: start.Add(time.Hour),
+		To:   start.Add(4 * time.Hour),
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 3, len(sales))
+	assert.Equal(t, int64(1000), sales[0].MSat)
+
+	sales, total, err = db.GetSales(&SalesQuery{
+		Offset: 1,
+		Limit:  2,
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 5, total)
+	assert.Equal(t, 2, len(sales))
+	assert.Equal(t, int64(1000), sales[0].MSat)
+	assert.Equal(t, int64(2000), sales[1].MSat)
+}
@@ -0,0 +1,34 @@
+package sweetdb
+
+var (
+	nodeSelectionKey = []byte("nodeSelection")
+)
+
+// NodeSelection is the strategy by which one of several enabled nodes
+// is chosen for issuing an invoice
+type NodeSelection string
+
+const (
+	// NodeSelectionPriority uses the node with the highest priority
+	NodeSelectionPriority NodeSelection = "priority"
+	// NodeSelectionMostInbound uses the node which can receive the most
+	NodeSelectionMostInbound NodeSelection = "most-inbound"
+	// NodeSelectionRoundRobin takes turns between all suitable nodes
+	NodeSelectionRoundRobin NodeSelection = "round-robin"
+)
+
+func (db *DB) SetNodeSelection(selection NodeSelection) error {
+	return db.setJSON(settingsBucket, nodeSelectionKey, selection)
+}
+
+// GetNodeSelection returns the saved node selection strategy or an empty
+// one if none was saved yet
+func (db *DB) GetNodeSelection() (NodeSelection, error) {
+	var selection NodeSelection
+
+	if err := db.getJSON(settingsBucket, nodeSelectionKey, &selection); err != nil {
+		return "", err
+	}
+
+	return selection, nil
+}
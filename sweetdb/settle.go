@@ -0,0 +1,58 @@
+package sweetdb
+
+import (
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// settleIndexBucket holds the settle index of the last processed
+	// invoice per node, keyed by node id
+	settleIndexBucket = []byte("settleIndex")
+)
+
+// SetSettleIndex saves the index up to which all settled invoices of a
+// node were processed, invoices settled later are replayed on start.
+// Callers have to make sure that no lower invoice is still unprocessed,
+// a lower index than the saved one is ignored.
+func (db *DB) SetSettleIndex(nodeId string, index uint64) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(settleIndexBucket)
+		if err != nil {
+			return err
+		}
+
+		if current := bucket.Get([]byte(nodeId)); len(current) == 8 && byteOrder.Uint64(current) >= index {
+			return nil
+		}
+
+		value := make([]byte, 8)
+		byteOrder.PutUint64(value, index)
+
+		return bucket.Put([]byte(nodeId), value)
+	})
+}
+
+// GetSettleIndex returns the last processed settle index of a node or
+// zero if no settled invoice was processed yet
+func (db *DB) GetSettleIndex(nodeId string) (uint64, error) {
+	var index uint64
+
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(settleIndexBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		value := bucket.Get([]byte(nodeId))
+		if len(value) == 8 {
+			index = byteOrder.Uint64(value)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return index, nil
+}
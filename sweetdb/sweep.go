@@ -0,0 +1,129 @@
+package sweetdb
+
+import (
+	"encoding/json"
+	"github.com/go-errors/errors"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var (
+	sweepConfigKey = []byte("sweepConfig")
+
+	// sweepsBucket is the log of all sweeps, keyed by a big endian
+	// sequence number
+	sweepsBucket = []byte("sweeps")
+)
+
+// SweepConfig moves the earnings of a node which exceed the threshold
+// to either an on-chain address or a Lightning address. On-chain funds
+// are swept to an address and channel funds to a Lightning address.
+type SweepConfig struct {
+	Enabled bool   `json:"enabled"`
+	NodeId  string `json:"nodeId"`
+	// Interval between sweeps
+	Interval time.Duration `json:"interval"`
+	// Threshold in satoshis which is kept on the node
+	Threshold        int64  `json:"threshold"`
+	Address          string `json:"address"`
+	LightningAddress string `json:"lightningAddress"`
+	// SatPerByte of on-chain sweeps, estimated by the node if zero
+	SatPerByte int64 `json:"satPerByte"`
+	// MaxFee in satoshis of Lightning sweeps
+	MaxFee int64 `json:"maxFee"`
+}
+
+// Sweep is a record of a single sweep attempt
+type Sweep struct {
+	Id     uint64    `json:"id"`
+	Time   time.Time `json:"time"`
+	NodeId string    `json:"nodeId"`
+	// Amount in satoshis
+	Amount           int64  `json:"amount"`
+	Address          string `json:"address"`
+	LightningAddress string `json:"lightningAddress"`
+	Txid             string `json:"txid"`
+	PaymentHash      string `json:"paymentHash"`
+	// Error if the sweep failed
+	Error string `json:"error"`
+}
+
+func (db *DB) SetSweepConfig(config *SweepConfig) error {
+	return db.setJSON(settingsBucket, sweepConfigKey, config)
+}
+
+// GetSweepConfig returns the saved sweep config or nil if none was saved
+// yet
+func (db *DB) GetSweepConfig() (*SweepConfig, error) {
+	var config *SweepConfig
+
+	if err := db.getJSON(settingsBucket, sweepConfigKey, &config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// AddSweep logs a sweep and assigns its id
+func (db *DB) AddSweep(sweep *Sweep) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(sweepsBucket)
+		if err != nil {
+			return err
+		}
+
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return errors.Errorf("unable to get next sequence: %v", err)
+		}
+
+		sweep.Id = seq
+
+		payload, err := json.Marshal(sweep)
+		if err != nil {
+			return err
+		}
+
+		key := make([]byte, 8)
+		byteOrder.PutUint64(key, seq)
+
+		return bucket.Put(key, payload)
+	})
+}
+
+// GetSweeps returns the latest sweeps, newest first, up to limit or all
+// if limit is zero
+func (db *DB) GetSweeps(limit int) ([]*Sweep, error) {
+	sweeps := []*Sweep{}
+
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(sweepsBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if limit > 0 && len(sweeps) >= limit {
+				break
+			}
+
+			sweep := &Sweep{}
+
+			err := json.Unmarshal(v, sweep)
+			if err != nil {
+				return errors.Errorf("unable to unmarshal sweep: %v", err)
+			}
+
+			sweeps = append(sweeps, sweep)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sweeps, nil
+}